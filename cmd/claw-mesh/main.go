@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -8,9 +9,13 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"text/tabwriter"
@@ -21,6 +26,7 @@ import (
 	"github.com/SallyKAN/claw-mesh/internal/node"
 	"github.com/SallyKAN/claw-mesh/internal/types"
 	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
 )
 
 var version = "dev"
@@ -45,8 +51,16 @@ func NewRootCmd() *cobra.Command {
 	rootCmd.AddCommand(newJoinCmd())
 	rootCmd.AddCommand(newStatusCmd())
 	rootCmd.AddCommand(newNodesCmd())
+	rootCmd.AddCommand(newNodeCmd())
 	rootCmd.AddCommand(newSendCmd())
+	rootCmd.AddCommand(newJobCmd())
+	rootCmd.AddCommand(newTaskCmd())
+	rootCmd.AddCommand(newScheduleCmd())
+	rootCmd.AddCommand(newPlanCmd())
 	rootCmd.AddCommand(newRouteCmd())
+	rootCmd.AddCommand(newGroupCmd())
+	rootCmd.AddCommand(newEventsCmd())
+	rootCmd.AddCommand(newWebhookCmd())
 
 	return rootCmd
 }
@@ -127,6 +141,10 @@ func newUpCmd() *cobra.Command {
 				cfg.Coordinator.DataDir = dd
 			}
 
+			if rf, _ := cmd.Flags().GetString("rules-file"); rf != "" {
+				cfg.Coordinator.RulesFile = rf
+			}
+
 			// Always allow private IPs so the local node can register as 127.0.0.1.
 			cfg.Coordinator.AllowPrivate = true
 
@@ -166,6 +184,7 @@ func newUpCmd() *cobra.Command {
 	cmd.Flags().Int("port", 0, "coordinator listen port (default: 9180)")
 	cmd.Flags().Bool("allow-private", false, "allow private/loopback IPs for node endpoints")
 	cmd.Flags().String("data-dir", "", "data directory for persistent state (default: ~/.claw-mesh)")
+	cmd.Flags().String("rules-file", "", "load routing rules from this YAML file and reload it on change")
 	cmd.Flags().Bool("no-local", false, "do not auto-register the local machine as a node")
 	return cmd
 }
@@ -199,10 +218,12 @@ func startLocalNode(cfg *config.Config, cmd *cobra.Command) *node.Agent {
 		Token:           token,
 		Name:            name,
 		Endpoint:        "127.0.0.1:9121",
+		MaxConcurrency:  cfg.Node.MaxConcurrency,
 		ListenAddr:      ":9121",
 		GatewayEndpoint: gwEndpoint,
 		GatewayToken:    gwToken,
 		GatewayTimeout:  120,
+		CredentialPath:  defaultCredentialPath("local"),
 	})
 
 	if err := agent.StartHandler(); err != nil {
@@ -234,6 +255,7 @@ func newJoinCmd() *cobra.Command {
 			coordinatorURL := args[0]
 			name, _ := cmd.Flags().GetString("name")
 			tags, _ := cmd.Flags().GetStringSlice("tags")
+			labels, _ := cmd.Flags().GetStringToString("labels")
 			token := resolveToken(cmd, cfg)
 			listen, _ := cmd.Flags().GetString("listen")
 
@@ -243,6 +265,13 @@ func newJoinCmd() *cobra.Command {
 			if len(tags) == 0 {
 				tags = cfg.Node.Tags
 			}
+			if len(labels) == 0 {
+				labels = cfg.Node.Labels
+			}
+			maxConcurrency := cfg.Node.MaxConcurrency
+			if cmd.Flags().Changed("max-concurrency") {
+				maxConcurrency, _ = cmd.Flags().GetInt("max-concurrency")
+			}
 
 			// Use --endpoint if provided; otherwise derive from listen address.
 			endpoint, _ := cmd.Flags().GetString("endpoint")
@@ -339,16 +368,24 @@ func newJoinCmd() *cobra.Command {
 				}
 			}
 
+			credPath, _ := cmd.Flags().GetString("credential-file")
+			if credPath == "" {
+				credPath = defaultCredentialPath(name)
+			}
+
 			agent := node.NewAgent(node.AgentConfig{
 				CoordinatorURL:  coordinatorURL,
 				Token:           token,
 				Name:            name,
 				Endpoint:        endpoint,
 				Tags:            tags,
+				Labels:          labels,
+				MaxConcurrency:  maxConcurrency,
 				ListenAddr:      listen,
 				GatewayEndpoint: resolveGatewayEndpoint(cmd, cfg),
 				GatewayToken:    resolveGatewayTokenFlag(cmd, cfg),
 				GatewayTimeout:  resolveGatewayTimeout(cmd, cfg),
+				CredentialPath:  credPath,
 			})
 
 			if err := agent.StartHandler(); err != nil {
@@ -371,6 +408,8 @@ func newJoinCmd() *cobra.Command {
 	}
 	cmd.Flags().String("name", "", "node display name")
 	cmd.Flags().StringSlice("tags", nil, "capability tags")
+	cmd.Flags().StringToString("labels", nil, "key=value node labels for label-selector routing (e.g. zone=home,tier=fast)")
+	cmd.Flags().Int("max-concurrency", 0, "messages this node handles at once; more wait on the coordinator (default: unlimited)")
 	cmd.Flags().String("listen", ":9121", "local handler listen address")
 	cmd.Flags().String("endpoint", "", "advertised endpoint address (default: auto-detect outbound IP + listen port)")
 	cmd.Flags().String("gateway-endpoint", "", "OpenClaw Gateway endpoint (default: auto-discover)")
@@ -384,6 +423,7 @@ func newJoinCmd() *cobra.Command {
 	cmd.Flags().String("api-base", "", "AI provider base URL (for custom providers)")
 	cmd.Flags().String("api-model", "", "AI model ID (for custom providers)")
 	cmd.Flags().String("api-provider", "", "AI provider: anthropic, openai, custom (auto-detect from env if empty)")
+	cmd.Flags().String("credential-file", "", "file storing this node's mesh identity (default: ~/.claw-mesh/node-<name>.json)")
 	return cmd
 }
 
@@ -443,6 +483,146 @@ func newNodesCmd() *cobra.Command {
 	}
 }
 
+func newNodeCmd() *cobra.Command {
+	nodeCmd := &cobra.Command{
+		Use:   "node",
+		Short: "Manage a single node",
+	}
+	nodeCmd.AddCommand(newNodeMaintenanceCmd("cordon", "Stop auto-routing new messages to a node"))
+	nodeCmd.AddCommand(newNodeDrainCmd())
+	nodeCmd.AddCommand(newNodeMaintenanceCmd("uncordon", "Return a node to auto-routing"))
+	nodeCmd.AddCommand(newNodeLabelCmd())
+	nodeCmd.AddCommand(newNodeConcurrencyCmd())
+	return nodeCmd
+}
+
+func newNodeConcurrencyCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "concurrency <node> <limit|default>",
+		Short: "Override how many messages a node is sent at once",
+		Long: "Override the concurrency limit a node advertises. 0 lifts the limit; " +
+			"'default' reverts to the node's own. Messages over the limit wait on the coordinator.",
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			var limit *int
+			if args[1] != "default" {
+				n, err := strconv.Atoi(args[1])
+				if err != nil || n < 0 {
+					return fmt.Errorf("invalid limit %q: expected a number >= 0 or 'default'", args[1])
+				}
+				limit = &n
+			}
+			id, err := resolveNodeID(base, token, args[0])
+			if err != nil {
+				return err
+			}
+			var n types.Node
+			in := map[string]*int{"max_concurrency": limit}
+			if err := apiRequest(http.MethodPut, base+"/api/v1/nodes/"+id+"/concurrency", token, in, http.StatusOK, &n); err != nil {
+				return err
+			}
+			desc := "unlimited"
+			if l := n.ConcurrencyLimit(); l > 0 {
+				desc = strconv.Itoa(l)
+			}
+			fmt.Printf("Node %s (%s) concurrency limit: %s\n", n.Name, n.ID, desc)
+			return nil
+		},
+	}
+}
+
+func newNodeLabelCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "label <node> key=value... [key-...]",
+		Short: "Set or remove node labels",
+		Long:  "Set labels with key=value and remove them with a trailing dash (key-).",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			patch := make(map[string]*string)
+			for _, arg := range args[1:] {
+				if k, v, ok := strings.Cut(arg, "="); ok {
+					patch[k] = &v
+				} else if k, ok := strings.CutSuffix(arg, "-"); ok {
+					patch[k] = nil
+				} else {
+					return fmt.Errorf("invalid label %q: expected key=value or key-", arg)
+				}
+			}
+
+			id, err := resolveNodeID(base, token, args[0])
+			if err != nil {
+				return err
+			}
+			var n types.Node
+			if err := apiRequest(http.MethodPatch, base+"/api/v1/nodes/"+id+"/labels", token, patch, http.StatusOK, &n); err != nil {
+				return err
+			}
+			fmt.Printf("Node %s (%s) labels: %s\n", n.Name, n.ID, formatNodeLabels(n.Labels))
+			return nil
+		},
+	}
+}
+
+// newNodeMaintenanceCmd builds a "node <action> <name-or-id>" command that
+// posts to /api/v1/nodes/{id}/<action>.
+func newNodeMaintenanceCmd(action, short string) *cobra.Command {
+	return &cobra.Command{
+		Use:   action + " <node>",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			n, err := setNodeMaintenance(base, token, args[0], action)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Node %s (%s): %s\n", n.Name, n.ID, describeMaintenance(n))
+			return nil
+		},
+	}
+}
+
+func newNodeDrainCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "drain <node>",
+		Short: "Cordon a node and wait for in-flight messages to finish",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			wait, _ := cmd.Flags().GetBool("wait")
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+
+			n, err := setNodeMaintenance(base, token, args[0], "drain")
+			if err != nil {
+				return err
+			}
+			if !wait || n.Maintenance == types.MaintenanceDrained {
+				fmt.Printf("Node %s (%s): %s\n", n.Name, n.ID, describeMaintenance(n))
+				return nil
+			}
+
+			fmt.Printf("Draining %s (%d in flight)...\n", n.Name, n.InFlight)
+			deadline := time.Now().Add(timeout)
+			for n.Maintenance == types.MaintenanceDraining {
+				if time.Now().After(deadline) {
+					return fmt.Errorf("node %s still has %d messages in flight after %s", n.Name, n.InFlight, timeout)
+				}
+				time.Sleep(time.Second)
+				if err := apiRequest(http.MethodGet, base+"/api/v1/nodes/"+n.ID, token, nil, http.StatusOK, &n); err != nil {
+					return err
+				}
+			}
+			fmt.Printf("Node %s (%s): %s\n", n.Name, n.ID, describeMaintenance(n))
+			return nil
+		},
+	}
+	cmd.Flags().Bool("wait", false, "block until the node is drained")
+	cmd.Flags().Duration("timeout", 5*time.Minute, "how long --wait waits for the drain")
+	return cmd
+}
+
 func newSendCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "send <message>",
@@ -452,15 +632,26 @@ func newSendCmd() *cobra.Command {
 			base, token := coordFlags(cmd)
 			targetNode, _ := cmd.Flags().GetString("node")
 			auto, _ := cmd.Flags().GetBool("auto")
+			session, _ := cmd.Flags().GetString("session")
+			broadcast, _ := cmd.Flags().GetBool("broadcast")
+			async, _ := cmd.Flags().GetBool("async")
+			plan, _ := cmd.Flags().GetBool("plan")
 
+			if broadcast {
+				return sendBroadcast(cmd, base, token, args[0])
+			}
 			if targetNode == "" && !auto {
-				return fmt.Errorf("specify --node <name> or --auto")
+				return fmt.Errorf("specify --node <name>, --auto or --broadcast")
+			}
+			if plan && !auto {
+				return fmt.Errorf("--plan needs --auto")
 			}
 
 			content := args[0]
 			payload, _ := json.Marshal(map[string]string{
-				"content": content,
-				"source":  "cli",
+				"content":    content,
+				"source":     "cli",
+				"session_id": session,
 			})
 
 			var url string
@@ -474,8 +665,26 @@ func newSendCmd() *cobra.Command {
 				}
 				url = base + "/api/v1/route/" + nodeID
 			}
+			if async || plan {
+				query := "?async=true"
+				if plan {
+					query = "?plan=true"
+				}
+				var job types.Job
+				in := map[string]string{"content": content, "source": "cli", "session_id": session}
+				if err := apiRequest(http.MethodPost, url+query, token, in, http.StatusAccepted, &job); err != nil {
+					return err
+				}
+				fmt.Printf("Job %s submitted (message %s)\n", job.ID, job.MessageID)
+				fmt.Printf("Get the result with: claw-mesh job get %s --wait 5m\n", job.ID)
+				return nil
+			}
 
-			req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+			// Ctrl-C closes the request, which cancels the message on the
+			// coordinator and the node handling it.
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
 			if err != nil {
 				return err
 			}
@@ -487,6 +696,9 @@ func newSendCmd() *cobra.Command {
 			client := &http.Client{Timeout: 30 * time.Second}
 			resp, err := client.Do(req)
 			if err != nil {
+				if ctx.Err() != nil {
+					return fmt.Errorf("message cancelled")
+				}
 				return fmt.Errorf("sending message: %w", err)
 			}
 			defer resp.Body.Close()
@@ -501,53 +713,98 @@ func newSendCmd() *cobra.Command {
 				return fmt.Errorf("decoding response: %w", err)
 			}
 			fmt.Printf("Message %s routed to node %s\n", msgResp.MessageID, msgResp.NodeID)
+			if len(msgResp.Attempts) > 1 {
+				for _, a := range msgResp.Attempts[:len(msgResp.Attempts)-1] {
+					reason := a.Error
+					if a.GatewayError {
+						reason = "gateway error"
+					}
+					fmt.Printf("  failed over from %s: %s\n", a.NodeName, reason)
+				}
+			}
 			fmt.Printf("Response: %s\n", msgResp.Response)
 			return nil
 		},
 	}
 	cmd.Flags().String("node", "", "target node name or ID")
 	cmd.Flags().Bool("auto", false, "auto-route based on rules")
+	cmd.Flags().String("session", "", "session ID; rules with session affinity keep a session on one node")
+	cmd.Flags().Bool("async", false, "return a job ID at once instead of waiting for the response")
+	cmd.Flags().Bool("plan", false, "with --auto: let the coordinator's planner split the message into a task plan (implies --async)")
+	cmd.Flags().Bool("broadcast", false, "send to every matching node and collect the answers")
+	cmd.Flags().String("match", "", "with --broadcast: node criteria, as in 'route add --match' (default: all nodes)")
+	cmd.Flags().String("group", "", "with --broadcast: only nodes in this group")
+	cmd.Flags().String("mode", "all", "with --broadcast: all, first-success or quorum")
+	cmd.Flags().Int("quorum", 0, "with --mode quorum: answers needed (default: a majority)")
+	cmd.Flags().Duration("timeout", 60*time.Second, "with --broadcast: overall deadline")
 	return cmd
 }
 
-func newRouteCmd() *cobra.Command {
-	routeCmd := &cobra.Command{
-		Use:   "route",
-		Short: "Manage routing rules",
+func newJobCmd() *cobra.Command {
+	jobCmd := &cobra.Command{
+		Use:   "job",
+		Short: "Follow messages sent with 'send --async'",
 	}
-	routeCmd.AddCommand(newRouteListCmd())
-	routeCmd.AddCommand(newRouteAddCmd())
-	return routeCmd
+	jobCmd.AddCommand(newJobGetCmd())
+	jobCmd.AddCommand(newJobListCmd())
+	return jobCmd
 }
 
-func newRouteListCmd() *cobra.Command {
+func newJobGetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get <job-id>",
+		Short: "Show a job's status and result",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			wait, _ := cmd.Flags().GetDuration("wait")
+
+			// The coordinator holds each poll for at most 25s; keep polling
+			// until the job is done or our own wait is over.
+			deadline := time.Now().Add(wait)
+			var job types.Job
+			for {
+				secs := int(time.Until(deadline).Seconds())
+				url := fmt.Sprintf("%s/api/v1/jobs/%s?wait=%d", base, args[0], max(secs, 0))
+				if err := apiRequest(http.MethodGet, url, token, nil, http.StatusOK, &job); err != nil {
+					return err
+				}
+				if job.Status != types.JobRunning || secs <= 0 {
+					break
+				}
+			}
+			printJob(&job)
+			return nil
+		},
+	}
+	cmd.Flags().Duration("wait", 0, "wait up to this long for the job to finish (e.g. 5m)")
+	return cmd
+}
+
+func newJobListCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "list",
-		Short: "List routing rules",
+		Short: "List recent jobs",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			base, token := coordFlags(cmd)
-			rules, err := fetchRules(base, token)
-			if err != nil {
+			var jobs []types.Job
+			if err := apiRequest(http.MethodGet, base+"/api/v1/jobs", token, nil, http.StatusOK, &jobs); err != nil {
 				return err
 			}
-			if len(rules) == 0 {
-				fmt.Println("No routing rules configured.")
+			if len(jobs) == 0 {
+				fmt.Println("No jobs.")
 				return nil
 			}
-
 			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
-			fmt.Fprintln(w, "ID\tMATCH\tTARGET\tSTRATEGY")
-			for _, r := range rules {
-				match := describeMatch(&r.Match)
-				target := r.Target
-				if target == "" {
-					target = "-"
+			fmt.Fprintln(w, "ID\tSTATUS\tMESSAGE\tNODE\tCREATED")
+			for _, j := range jobs {
+				node := "-"
+				if j.Result != nil {
+					node = j.Result.NodeID
+				} else if j.PlanID != "" {
+					node = j.PlanID
 				}
-				strategy := r.Strategy
-				if strategy == "" {
-					strategy = "least-busy"
-				}
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.ID, match, target, strategy)
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", j.ID, j.Status, j.MessageID, node, j.CreatedAt.Local().Format(time.RFC3339))
 			}
 			w.Flush()
 			return nil
@@ -555,99 +812,1557 @@ func newRouteListCmd() *cobra.Command {
 	}
 }
 
-func newRouteAddCmd() *cobra.Command {
+func newTaskCmd() *cobra.Command {
+	taskCmd := &cobra.Command{
+		Use:   "task",
+		Short: "Queue messages that are retried until a node answers",
+	}
+	taskCmd.AddCommand(newTaskAddCmd())
+	taskCmd.AddCommand(newTaskListCmd())
+	taskCmd.AddCommand(newTaskGetCmd())
+	taskCmd.AddCommand(newTaskRequeueCmd())
+	taskCmd.AddCommand(newTaskDeleteCmd())
+	taskCmd.AddCommand(newTaskPurgeCmd())
+	return taskCmd
+}
+
+func newTaskAddCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "add",
-		Short: "Add a routing rule",
+		Use:   "add <message>",
+		Short: "Queue a message as a task",
+		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			base, token := coordFlags(cmd)
-			matchStr, _ := cmd.Flags().GetString("match")
-			target, _ := cmd.Flags().GetString("target")
-
-			rule := buildRuleFromMatch(matchStr, target)
-
-			payload, _ := json.Marshal(rule)
-			req, err := http.NewRequest(http.MethodPost, base+"/api/v1/rules", bytes.NewReader(payload))
-			if err != nil {
-				return err
+			targetNode, _ := cmd.Flags().GetString("node")
+			session, _ := cmd.Flags().GetString("session")
+			attempts, _ := cmd.Flags().GetInt("max-attempts")
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+
+			req := types.TaskRequest{
+				Content:        args[0],
+				Source:         "cli",
+				SessionID:      session,
+				MaxAttempts:    attempts,
+				TimeoutSeconds: int(timeout.Seconds()),
 			}
-			req.Header.Set("Content-Type", "application/json")
-			if token != "" {
-				req.Header.Set("Authorization", "Bearer "+token)
+			if targetNode != "" {
+				nodeID, err := resolveNodeID(base, token, targetNode)
+				if err != nil {
+					return err
+				}
+				req.TargetNode = nodeID
 			}
-
-			client := &http.Client{Timeout: 10 * time.Second}
-			resp, err := client.Do(req)
-			if err != nil {
-				return fmt.Errorf("adding rule: %w", err)
+			if cmd.Flags().Changed("backoff") || cmd.Flags().Changed("max-backoff") {
+				initial, _ := cmd.Flags().GetDuration("backoff")
+				maxDelay, _ := cmd.Flags().GetDuration("max-backoff")
+				req.Backoff = &types.BackoffPolicy{Initial: initial.Seconds(), Multiplier: 2, Max: maxDelay.Seconds()}
 			}
-			defer resp.Body.Close()
 
-			body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-			if resp.StatusCode != http.StatusCreated {
-				return fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
+			var task types.Task
+			if err := apiRequest(http.MethodPost, base+"/api/v1/tasks", token, req, http.StatusCreated, &task); err != nil {
+				return err
 			}
+			fmt.Printf("Task queued: %s (%d attempts, deadline %s)\n", task.ID, task.MaxAttempts, task.Deadline.Local().Format(time.RFC3339))
+			return nil
+		},
+	}
+	cmd.Flags().String("node", "", "send to this node (name or ID) instead of routing each attempt")
+	cmd.Flags().String("session", "", "session ID for session affinity")
+	cmd.Flags().Int("max-attempts", 0, "attempts before the task is dead-lettered (default 5)")
+	cmd.Flags().Duration("timeout", 0, "deadline for the task, from now (default 1h)")
+	cmd.Flags().Duration("backoff", 2*time.Second, "delay before the first retry, doubled after each")
+	cmd.Flags().Duration("max-backoff", 5*time.Minute, "longest delay between retries")
+	return cmd
+}
 
-			var created types.RoutingRule
-			if err := json.Unmarshal(body, &created); err != nil {
-				return fmt.Errorf("decoding response: %w", err)
+func newTaskListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List tasks",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			status, _ := cmd.Flags().GetString("status")
+			var tasks []*types.Task
+			if err := apiRequest(http.MethodGet, base+"/api/v1/tasks?status="+url.QueryEscape(status), token, nil, http.StatusOK, &tasks); err != nil {
+				return err
 			}
-			fmt.Printf("Rule added: %s\n", created.ID)
+			if len(tasks) == 0 {
+				fmt.Println("No tasks.")
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tSTATUS\tATTEMPTS\tNEXT ATTEMPT\tLAST ERROR")
+			for _, t := range tasks {
+				next := "-"
+				if t.Status == types.TaskPending {
+					next = t.NextAttemptAt.Local().Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%d/%d\t%s\t%s\n", t.ID, t.Status, t.Attempts, t.MaxAttempts, next, t.LastError)
+			}
+			w.Flush()
 			return nil
 		},
 	}
-	cmd.Flags().String("match", "", "match criteria (e.g. 'gpu:true', 'os:linux', 'skill:docker')")
-	cmd.Flags().String("target", "", "target node name")
-	_ = cmd.MarkFlagRequired("match")
+	cmd.Flags().String("status", "", "only list tasks with this status (pending, running, succeeded, dead)")
 	return cmd
 }
 
-// --- helpers ---
-
-func loadConfig(cmd *cobra.Command) (*config.Config, error) {
-	cfgFile, _ := cmd.Flags().GetString("config")
-	return config.Load(cfgFile)
+func newTaskGetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <task-id>",
+		Short: "Show a task",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			var t types.Task
+			if err := apiRequest(http.MethodGet, base+"/api/v1/tasks/"+args[0], token, nil, http.StatusOK, &t); err != nil {
+				return err
+			}
+			fmt.Printf("Task %s: %s (%d/%d attempts)\n", t.ID, t.Status, t.Attempts, t.MaxAttempts)
+			fmt.Printf("Deadline: %s\n", t.Deadline.Local().Format(time.RFC3339))
+			if t.Status == types.TaskPending {
+				fmt.Printf("Next attempt: %s\n", t.NextAttemptAt.Local().Format(time.RFC3339))
+			}
+			if t.LastError != "" {
+				fmt.Printf("Last error: %s\n", t.LastError)
+			}
+			if t.Result != nil {
+				fmt.Printf("Node: %s\n", t.Result.NodeID)
+				fmt.Printf("Response: %s\n", t.Result.Response)
+			}
+			return nil
+		},
+	}
 }
 
-func coordFlags(cmd *cobra.Command) (string, string) {
-	base, _ := cmd.Flags().GetString("coordinator")
-	token, _ := cmd.Flags().GetString("token")
-	if base == "" {
-		base = "http://127.0.0.1:9180"
-	}
-	// Token precedence: flag -> config file -> CLAW_MESH_TOKEN env var.
-	if token == "" {
-		cfg, err := loadConfig(cmd)
-		if err == nil && cfg.Coordinator.Token != "" {
-			token = cfg.Coordinator.Token
-		}
-	}
-	if token == "" {
-		token = os.Getenv("CLAW_MESH_TOKEN")
+func newTaskRequeueCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "requeue <task-id>",
+		Short: "Retry a dead-lettered task",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			var t types.Task
+			if err := apiRequest(http.MethodPost, base+"/api/v1/tasks/"+args[0]+"/requeue", token, nil, http.StatusOK, &t); err != nil {
+				return err
+			}
+			fmt.Printf("Task requeued: %s\n", t.ID)
+			return nil
+		},
 	}
-	return base, token
 }
 
-// resolveToken returns the auth token using precedence: flag -> config -> env.
-func resolveToken(cmd *cobra.Command, cfg *config.Config) string {
-	token, _ := cmd.Flags().GetString("token")
-	if token != "" {
-		return token
-	}
-	if cfg != nil && cfg.Coordinator.Token != "" {
-		return cfg.Coordinator.Token
+func newTaskDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <task-id>",
+		Short: "Delete a task that is not running",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			if err := apiRequest(http.MethodDelete, base+"/api/v1/tasks/"+args[0], token, nil, http.StatusNoContent, nil); err != nil {
+				return err
+			}
+			fmt.Printf("Task deleted: %s\n", args[0])
+			return nil
+		},
 	}
-	if env := os.Getenv("CLAW_MESH_TOKEN"); env != "" {
-		return env
+}
+
+func newTaskPurgeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Delete all tasks with a status (default: the dead-letter queue)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			status, _ := cmd.Flags().GetString("status")
+			var out struct {
+				Purged int `json:"purged"`
+			}
+			if err := apiRequest(http.MethodDelete, base+"/api/v1/tasks?status="+url.QueryEscape(status), token, nil, http.StatusOK, &out); err != nil {
+				return err
+			}
+			fmt.Printf("Purged %d %s tasks\n", out.Purged, status)
+			return nil
+		},
 	}
-	return ""
+	cmd.Flags().String("status", string(types.TaskDead), "status of the tasks to delete (pending, succeeded, dead)")
+	return cmd
 }
 
-// detectOutboundIP finds the preferred outbound IP by dialing a UDP socket.
-func detectOutboundIP() string {
-	conn, err := net.Dial("udp4", "8.8.8.8:80")
-	if err != nil {
-		return ""
+func newScheduleCmd() *cobra.Command {
+	schedCmd := &cobra.Command{
+		Use:   "schedule",
+		Short: "Manage recurring messages run by the coordinator",
+	}
+	schedCmd.AddCommand(newScheduleAddCmd())
+	schedCmd.AddCommand(newScheduleListCmd())
+	schedCmd.AddCommand(newScheduleGetCmd())
+	schedCmd.AddCommand(newScheduleDeleteCmd())
+	schedCmd.AddCommand(newScheduleRunCmd())
+	schedCmd.AddCommand(newScheduleEnableCmd("enable", false))
+	schedCmd.AddCommand(newScheduleEnableCmd("disable", true))
+	return schedCmd
+}
+
+func newScheduleAddCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add <name> <message>",
+		Short: "Add a schedule (the message may use {{.Date}}, {{.Time}}, {{.Run}}, ...)",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			cron, _ := cmd.Flags().GetString("cron")
+			every, _ := cmd.Flags().GetDuration("every")
+			tz, _ := cmd.Flags().GetString("tz")
+			targetNode, _ := cmd.Flags().GetString("node")
+			ruleRef, _ := cmd.Flags().GetString("rule")
+			session, _ := cmd.Flags().GetString("session")
+			overlap, _ := cmd.Flags().GetString("overlap")
+			keep, _ := cmd.Flags().GetInt("keep")
+
+			if (cron == "") == (every == 0) {
+				return fmt.Errorf("specify exactly one of --cron and --every")
+			}
+			spec := types.ScheduleSpec{
+				Name:            args[0],
+				Cron:            cron,
+				IntervalSeconds: int(every.Seconds()),
+				Timezone:        tz,
+				Content:         args[1],
+				SessionID:       session,
+				Overlap:         types.OverlapPolicy(overlap),
+				KeepRuns:        keep,
+			}
+			if targetNode != "" {
+				nodeID, err := resolveNodeID(base, token, targetNode)
+				if err != nil {
+					return err
+				}
+				spec.TargetNode = nodeID
+			}
+			if ruleRef != "" {
+				rules, _, err := fetchRules(base, token)
+				if err != nil {
+					return err
+				}
+				rule, _, err := findRule(rules, ruleRef)
+				if err != nil {
+					return err
+				}
+				spec.TargetRule = rule.ID
+			}
+
+			var sc types.Schedule
+			if err := apiRequest(http.MethodPost, base+"/api/v1/schedules", token, spec, http.StatusCreated, &sc); err != nil {
+				return err
+			}
+			fmt.Printf("Schedule added: %s (%s)\n", sc.Name, sc.ID)
+			if sc.NextRunAt != nil {
+				fmt.Printf("Next run: %s\n", sc.NextRunAt.Local().Format(time.RFC3339))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().String("cron", "", "cron expression, e.g. '0 7 * * mon-fri' or '@daily'")
+	cmd.Flags().Duration("every", 0, "run at a fixed interval instead, e.g. 30m")
+	cmd.Flags().String("tz", "", "time zone for --cron, e.g. Europe/Berlin (default: the coordinator's)")
+	cmd.Flags().String("node", "", "send to this node (name or ID)")
+	cmd.Flags().String("rule", "", "route with this rule (ID or # from 'route list')")
+	cmd.Flags().String("session", "", "session ID for session affinity")
+	cmd.Flags().String("overlap", "", "if the previous run is still going: skip (default), allow or replace")
+	cmd.Flags().Int("keep", 0, "number of recent runs to keep (default 10)")
+	return cmd
+}
+
+func newScheduleListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List schedules",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			var schedules []*types.Schedule
+			if err := apiRequest(http.MethodGet, base+"/api/v1/schedules", token, nil, http.StatusOK, &schedules); err != nil {
+				return err
+			}
+			if len(schedules) == 0 {
+				fmt.Println("No schedules.")
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tWHEN\tTARGET\tNEXT RUN\tLAST RUN")
+			for _, sc := range schedules {
+				next := "-"
+				if sc.NextRunAt != nil {
+					next = sc.NextRunAt.Local().Format(time.RFC3339)
+				} else if sc.Disabled {
+					next = "(disabled)"
+				}
+				last := "-"
+				if len(sc.Runs) > 0 {
+					last = string(sc.Runs[0].Status)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", sc.Name, scheduleTiming(&sc.ScheduleSpec), scheduleTarget(&sc.ScheduleSpec), next, last)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
+
+func newScheduleGetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <name>",
+		Short: "Show a schedule and its recent runs",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			var sc types.Schedule
+			if err := apiRequest(http.MethodGet, base+"/api/v1/schedules/"+args[0], token, nil, http.StatusOK, &sc); err != nil {
+				return err
+			}
+			fmt.Printf("Schedule %s (%s)\n", sc.Name, sc.ID)
+			fmt.Printf("When: %s\n", scheduleTiming(&sc.ScheduleSpec))
+			fmt.Printf("Target: %s\n", scheduleTarget(&sc.ScheduleSpec))
+			fmt.Printf("Message: %s\n", sc.Content)
+			if sc.NextRunAt != nil {
+				fmt.Printf("Next run: %s\n", sc.NextRunAt.Local().Format(time.RFC3339))
+			}
+			if len(sc.Runs) == 0 {
+				fmt.Println("No runs yet.")
+				return nil
+			}
+			fmt.Println()
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "STARTED\tSTATUS\tNODE\tRESULT")
+			for _, r := range sc.Runs {
+				status := string(r.Status)
+				if r.Manual {
+					status += " (manual)"
+				}
+				result := r.Error
+				if result == "" {
+					result = strings.Join(strings.Fields(r.Response), " ")
+					if len(result) > 60 {
+						result = result[:57] + "..."
+					}
+				}
+				node := r.NodeID
+				if node == "" {
+					node = "-"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.StartedAt.Local().Format(time.RFC3339), status, node, result)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
+
+func newScheduleDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a schedule",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			if err := apiRequest(http.MethodDelete, base+"/api/v1/schedules/"+args[0], token, nil, http.StatusNoContent, nil); err != nil {
+				return err
+			}
+			fmt.Printf("Schedule deleted: %s\n", args[0])
+			return nil
+		},
+	}
+}
+
+func newScheduleRunCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "run <name>",
+		Short: "Run a schedule now",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			var run types.ScheduleRun
+			if err := apiRequest(http.MethodPost, base+"/api/v1/schedules/"+args[0]+"/run", token, nil, http.StatusAccepted, &run); err != nil {
+				return err
+			}
+			if run.Status != types.RunRunning {
+				fmt.Printf("Run %s: %s\n", run.Status, run.Error)
+				return nil
+			}
+			fmt.Printf("Run started: message %s\n", run.MessageID)
+			fmt.Printf("See the result with: claw-mesh schedule get %s\n", args[0])
+			return nil
+		},
+	}
+}
+
+// newScheduleEnableCmd builds 'schedule enable' and 'schedule disable'.
+func newScheduleEnableCmd(use string, disable bool) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " <name>",
+		Short: strings.ToUpper(use[:1]) + use[1:] + " a schedule",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			var sc types.Schedule
+			if err := apiRequest(http.MethodGet, base+"/api/v1/schedules/"+args[0], token, nil, http.StatusOK, &sc); err != nil {
+				return err
+			}
+			spec := sc.ScheduleSpec
+			spec.Disabled = disable
+			if err := apiRequest(http.MethodPut, base+"/api/v1/schedules/"+sc.ID, token, spec, http.StatusOK, &sc); err != nil {
+				return err
+			}
+			fmt.Printf("Schedule %sd: %s\n", use, sc.Name)
+			return nil
+		},
+	}
+}
+
+// scheduleTiming describes when a schedule fires.
+func scheduleTiming(spec *types.ScheduleSpec) string {
+	if spec.Cron == "" {
+		return "every " + (time.Duration(spec.IntervalSeconds) * time.Second).String()
+	}
+	if spec.Timezone != "" {
+		return spec.Cron + " (" + spec.Timezone + ")"
+	}
+	return spec.Cron
+}
+
+// scheduleTarget describes where a schedule's messages go.
+func scheduleTarget(spec *types.ScheduleSpec) string {
+	switch {
+	case spec.TargetNode != "":
+		return "node " + spec.TargetNode
+	case spec.TargetRule != "":
+		return "rule " + spec.TargetRule
+	}
+	return "auto"
+}
+
+func newPlanCmd() *cobra.Command {
+	planCmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Run multi-step tasks whose steps go to different nodes",
+	}
+	planCmd.AddCommand(newPlanSubmitCmd())
+	planCmd.AddCommand(newPlanStatusCmd())
+	planCmd.AddCommand(newPlanListCmd())
+	return planCmd
+}
+
+func newPlanSubmitCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "submit <file>",
+		Short: "Submit a task plan from a YAML or JSON file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			// JSON is valid YAML; the coordinator checks the fields.
+			var plan map[string]any
+			if err := yaml.Unmarshal(data, &plan); err != nil {
+				return fmt.Errorf("parsing %s: %w", args[0], err)
+			}
+			var p types.TaskPlan
+			if err := apiRequest(http.MethodPost, base+"/api/v1/plans", token, plan, http.StatusAccepted, &p); err != nil {
+				return err
+			}
+			printPlanCreated(p.ID, len(p.Steps))
+			return nil
+		},
+	}
+}
+
+func newPlanStatusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status <plan-id>",
+		Short: "Show a plan's steps and result",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			watch, _ := cmd.Flags().GetBool("watch")
+			var last string
+			for {
+				var p types.TaskPlan
+				if err := apiRequest(http.MethodGet, base+"/api/v1/plans/"+args[0], token, nil, http.StatusOK, &p); err != nil {
+					return err
+				}
+				done := p.Status == types.PlanCompleted || p.Status == types.PlanFailed
+				if !watch || done {
+					printPlan(&p)
+					return nil
+				}
+				// Reprint only when a step has moved on.
+				var state strings.Builder
+				for _, st := range p.Steps {
+					state.WriteString(string(st.Status) + " ")
+				}
+				if state.String() != last {
+					printPlan(&p)
+					fmt.Println()
+					last = state.String()
+				}
+				time.Sleep(2 * time.Second)
+			}
+		},
+	}
+	cmd.Flags().Bool("watch", false, "keep polling until the plan finishes")
+	return cmd
+}
+
+func newPlanListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List task plans",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			var plans []types.TaskPlan
+			if err := apiRequest(http.MethodGet, base+"/api/v1/plans", token, nil, http.StatusOK, &plans); err != nil {
+				return err
+			}
+			if len(plans) == 0 {
+				fmt.Println("No plans.")
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tSTATUS\tSTEPS\tCREATED")
+			for _, p := range plans {
+				completed := 0
+				for _, st := range p.Steps {
+					if st.Status == types.StepCompleted {
+						completed++
+					}
+				}
+				fmt.Fprintf(w, "%s\t%s\t%d/%d\t%s\n", p.ID, p.Status, completed, len(p.Steps), p.CreatedAt.Local().Format(time.RFC3339))
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
+
+func printPlanCreated(id string, steps int) {
+	fmt.Printf("Plan %s created (%d steps), executing...\n", id, steps)
+	fmt.Printf("Follow it with: claw-mesh plan status %s --watch\n", id)
+}
+
+func printPlan(p *types.TaskPlan) {
+	fmt.Printf("Plan %s: %s\n", p.ID, p.Status)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STEP\tSTATUS\tNODE\tRESULT")
+	for _, st := range p.Steps {
+		node := st.NodeUsed
+		if node == "" {
+			node = "-"
+		}
+		result := st.Response
+		if st.Error != "" {
+			result = st.Error
+		}
+		result = strings.Join(strings.Fields(result), " ")
+		if len(result) > 60 {
+			result = result[:57] + "..."
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", st.ID, st.Status, node, result)
+	}
+	w.Flush()
+	if p.Error != "" {
+		fmt.Printf("Error: %s\n", p.Error)
+	}
+	if p.FinalResponse != "" {
+		fmt.Printf("\n%s\n", p.FinalResponse)
+	}
+}
+
+func printJob(j *types.Job) {
+	fmt.Printf("Job %s: %s (message %s)\n", j.ID, j.Status, j.MessageID)
+	for _, a := range j.Attempts {
+		if a.Error != "" || a.GatewayError {
+			reason := a.Error
+			if a.GatewayError {
+				reason = "gateway error"
+			}
+			fmt.Printf("  attempt on %s failed: %s\n", a.NodeName, reason)
+		}
+	}
+	switch {
+	case j.Status == types.JobSucceeded && j.PlanID != "":
+		fmt.Printf("Planned as %s\n", j.PlanID)
+		fmt.Printf("Follow it with: claw-mesh plan status %s --watch\n", j.PlanID)
+	case j.Status == types.JobSucceeded:
+		fmt.Printf("Node: %s\n", j.Result.NodeID)
+		fmt.Printf("Response: %s\n", j.Result.Response)
+	case j.Status == types.JobFailed:
+		fmt.Printf("Error: %s\n", j.Error)
+	}
+}
+
+// sendBroadcast implements 'send --broadcast'.
+func sendBroadcast(cmd *cobra.Command, base, token, content string) error {
+	matchStr, _ := cmd.Flags().GetString("match")
+	group, _ := cmd.Flags().GetString("group")
+	mode, _ := cmd.Flags().GetString("mode")
+	quorum, _ := cmd.Flags().GetInt("quorum")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+
+	req := types.BroadcastRequest{
+		Content:        content,
+		Source:         "cli",
+		Group:          group,
+		Mode:           mode,
+		Quorum:         quorum,
+		TimeoutSeconds: int(timeout.Seconds()),
+	}
+	if matchStr != "" {
+		rule := buildRuleFromMatch(matchStr, "")
+		req.Match, req.Expr = rule.Match, rule.Expr
+	}
+
+	// A broadcast that misses its mode still returns every result, with 502.
+	payload, _ := json.Marshal(req)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/api/v1/broadcast", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{Timeout: timeout + 10*time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("broadcast cancelled")
+		}
+		return fmt.Errorf("sending broadcast: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusBadGateway {
+		return fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
+	}
+	var out types.BroadcastResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	for _, r := range out.Results {
+		switch {
+		case r.Cancelled:
+			fmt.Printf("--- %s: cancelled\n", r.NodeName)
+		case r.Error != "":
+			fmt.Printf("--- %s: failed after %dms: %s\n", r.NodeName, r.DurationMs, r.Error)
+		default:
+			note := ""
+			if r.GatewayError {
+				note = " (gateway error, echo)"
+			}
+			fmt.Printf("--- %s (%dms)%s\n%s\n", r.NodeName, r.DurationMs, note, r.Response)
+		}
+	}
+	fmt.Printf("Broadcast %s: %d succeeded, %d failed (mode %s)\n", out.MessageID, out.Succeeded, out.Failed, out.Mode)
+	if !out.OK {
+		return fmt.Errorf("broadcast mode %q not satisfied", out.Mode)
+	}
+	return nil
+}
+
+func newEventsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "events",
+		Short: "Stream mesh events (node, rule and message lifecycle)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			typeFilter, _ := cmd.Flags().GetString("types")
+			since, _ := cmd.Flags().GetUint64("since")
+			asJSON, _ := cmd.Flags().GetBool("json")
+
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			url := base + "/api/v1/events"
+			q := []string{}
+			if typeFilter != "" {
+				q = append(q, "types="+typeFilter)
+			}
+			if since > 0 {
+				q = append(q, fmt.Sprintf("since=%d", since))
+			}
+			if len(q) > 0 {
+				url += "?" + strings.Join(q, "&")
+			}
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Accept", "text/event-stream")
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			// No client timeout: the stream stays open until interrupted.
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("connecting to coordinator: %w", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+				return fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
+			}
+
+			scanner := bufio.NewScanner(resp.Body)
+			scanner.Buffer(make([]byte, 64*1024), 1<<20)
+			for scanner.Scan() {
+				line := scanner.Text()
+				if !strings.HasPrefix(line, "data: ") {
+					continue
+				}
+				data := strings.TrimPrefix(line, "data: ")
+				if asJSON {
+					fmt.Println(data)
+					continue
+				}
+				var ev types.Event
+				if err := json.Unmarshal([]byte(data), &ev); err != nil {
+					continue
+				}
+				fmt.Println(formatEvent(&ev))
+			}
+			if err := scanner.Err(); err != nil && ctx.Err() == nil {
+				return fmt.Errorf("reading event stream: %w", err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().String("types", "", "comma-separated event types to show (e.g. 'node.*,rule.added')")
+	cmd.Flags().Uint64("since", 0, "resume after this event ID")
+	cmd.Flags().Bool("json", false, "print raw JSON events")
+	return cmd
+}
+
+func newRouteCmd() *cobra.Command {
+	routeCmd := &cobra.Command{
+		Use:   "route",
+		Short: "Manage routing rules",
+	}
+	routeCmd.AddCommand(newRouteListCmd())
+	routeCmd.AddCommand(newRouteAddCmd())
+	routeCmd.AddCommand(newRouteEditCmd())
+	routeCmd.AddCommand(newRouteMoveCmd())
+	routeCmd.AddCommand(newRouteEnableCmd("disable", "Keep a rule but skip it when routing", true))
+	routeCmd.AddCommand(newRouteEnableCmd("enable", "Route with a disabled rule again", false))
+	routeCmd.AddCommand(newRouteStrategiesCmd())
+	routeCmd.AddCommand(newRouteAffinityCmd())
+	routeCmd.AddCommand(newRouteExplainCmd())
+	routeCmd.AddCommand(newRouteExportCmd())
+	routeCmd.AddCommand(newRouteImportCmd())
+	return routeCmd
+}
+
+func newRouteExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write the routing rules as YAML",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			out, _ := cmd.Flags().GetString("output")
+			rules, _, err := fetchRules(base, token)
+			if err != nil {
+				return err
+			}
+			// IDs are local to a mesh; the importing side assigns its own.
+			for _, r := range rules {
+				r.ID = ""
+			}
+			if rules == nil {
+				rules = []*types.RoutingRule{}
+			}
+			data, err := yaml.Marshal(map[string]any{"rules": rules})
+			if err != nil {
+				return err
+			}
+			if out == "" || out == "-" {
+				_, err = os.Stdout.Write(data)
+				return err
+			}
+			if err := os.WriteFile(out, data, 0644); err != nil {
+				return err
+			}
+			fmt.Printf("Exported %d rules to %s\n", len(rules), out)
+			return nil
+		},
+	}
+	cmd.Flags().StringP("output", "o", "", "write to this file instead of stdout")
+	return cmd
+}
+
+func newRouteImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Replace the routing rules with those in a YAML file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			appendRules, _ := cmd.Flags().GetBool("append")
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			rules, err := coordinator.ParseRules(data)
+			if err != nil {
+				return fmt.Errorf("parsing %s: %w", args[0], err)
+			}
+			var etag string
+			if appendRules {
+				existing, tag, err := fetchRules(base, token)
+				if err != nil {
+					return err
+				}
+				rules = append(existing, rules...)
+				etag = tag
+			}
+			if rules == nil {
+				rules = []*types.RoutingRule{}
+			}
+			var result []*types.RoutingRule
+			if err := apiRequestIfMatch(http.MethodPut, base+"/api/v1/rules", token, etag, rules, http.StatusOK, &result); err != nil {
+				return err
+			}
+			fmt.Printf("Imported %s: %d routing rules now active\n", args[0], len(result))
+			return nil
+		},
+	}
+	cmd.Flags().Bool("append", false, "add the rules after the existing ones instead of replacing them")
+	return cmd
+}
+
+func newRouteExplainCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "explain <message>",
+		Short: "Show how a message would be routed, without sending it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			source, _ := cmd.Flags().GetString("source")
+			session, _ := cmd.Flags().GetString("session")
+			targetNode, _ := cmd.Flags().GetString("node")
+			asJSON, _ := cmd.Flags().GetBool("json")
+
+			if targetNode != "" {
+				id, err := resolveNodeID(base, token, targetNode)
+				if err != nil {
+					return err
+				}
+				targetNode = id
+			}
+			in := map[string]string{
+				"content":     args[0],
+				"source":      source,
+				"session_id":  session,
+				"target_node": targetNode,
+			}
+			var trace types.RouteTrace
+			if err := apiRequest(http.MethodPost, base+"/api/v1/explain/route", token, in, http.StatusOK, &trace); err != nil {
+				return err
+			}
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(trace)
+			}
+			printRouteTrace(&trace)
+			return nil
+		},
+	}
+	cmd.Flags().String("source", "cli", "message source")
+	cmd.Flags().String("session", "", "session ID")
+	cmd.Flags().String("node", "", "explain routing to a specific node name or ID")
+	cmd.Flags().Bool("json", false, "print the raw trace as JSON")
+	return cmd
+}
+
+func printRouteTrace(t *types.RouteTrace) {
+	for _, ex := range t.Unavailable {
+		fmt.Printf("unavailable: %s (%s): %s\n", ex.Name, ex.NodeID, ex.Reason)
+	}
+	for i, r := range t.Rules {
+		verdict := "matched"
+		if !r.Matched {
+			verdict = "skipped: " + r.Reason
+		}
+		fmt.Printf("rule %d %s: %s\n", i+1, r.RuleID, verdict)
+		if len(r.Candidates) > 0 {
+			fmt.Printf("  candidates: %s\n", strings.Join(r.Candidates, ", "))
+		}
+		for _, ex := range r.Excluded {
+			fmt.Printf("  excluded %s (%s): %s\n", ex.Name, ex.NodeID, ex.Reason)
+		}
+	}
+	switch {
+	case t.Error != "":
+		fmt.Printf("=> no route: %s\n", t.Error)
+	case t.Fallback:
+		fmt.Printf("=> %s (%s): no rule applied, fell back to least-busy\n", t.NodeName, t.NodeID)
+	case t.RuleID != "":
+		fmt.Printf("=> %s (%s): chosen by rule %s via %s\n", t.NodeName, t.NodeID, t.RuleID, t.ChosenBy)
+	default:
+		fmt.Printf("=> %s (%s): %s\n", t.NodeName, t.NodeID, t.ChosenBy)
+	}
+}
+
+func newRouteAffinityCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "affinity",
+		Short: "Show which node each source or session is pinned to",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			var entries []types.AffinityEntry
+			if err := apiRequest(http.MethodGet, base+"/api/v1/affinity", token, nil, http.StatusOK, &entries); err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				fmt.Println("No affinity entries.")
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "RULE\tKEY\tNODE\tHITS\tMOVED\tLAST USED")
+			for _, e := range entries {
+				fmt.Fprintf(w, "%s\t%s:%s\t%s\t%d\t%d\t%s\n", e.RuleID, e.Mode, e.Key, e.NodeID,
+					e.Hits, e.Reassignments, e.LastUsed.Format(time.RFC3339))
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
+
+func newRouteStrategiesCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "strategies",
+		Short: "List node selection strategies",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			strategies, err := fetchStrategies(base, token)
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tDESCRIPTION")
+			for _, st := range strategies {
+				name := st.Name
+				if st.Default {
+					name += " (default)"
+				}
+				fmt.Fprintf(w, "%s\t%s\n", name, st.Description)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
+
+func newRouteListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List routing rules",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			rules, _, err := fetchRules(base, token)
+			if err != nil {
+				return err
+			}
+			if len(rules) == 0 {
+				fmt.Println("No routing rules configured.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "#\tID\tMATCH\tTARGET\tSTRATEGY\tAFFINITY")
+			for i, r := range rules {
+				match := describeMatch(&r.Match)
+				if r.Expr != "" {
+					if match == "-" {
+						match = r.Expr
+					} else {
+						match += " AND (" + r.Expr + ")"
+					}
+				}
+				target := r.Target
+				if r.TargetGroup != "" {
+					target = "group:" + r.TargetGroup
+				}
+				if target == "" {
+					target = "-"
+				}
+				strategy := r.Strategy
+				if strategy == "" {
+					strategy = "least-busy"
+				}
+				affinity := r.Affinity
+				if affinity == "" {
+					affinity = "-"
+				}
+				id := r.ID
+				if r.Disabled {
+					id += " (disabled)"
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", i+1, id, match, target, strategy, affinity)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
+
+func newRouteAddCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "Add a routing rule",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			matchStr, _ := cmd.Flags().GetString("match")
+			target, _ := cmd.Flags().GetString("target")
+			group, _ := cmd.Flags().GetString("group")
+			strategy, _ := cmd.Flags().GetString("strategy")
+			affinity, _ := cmd.Flags().GetString("affinity")
+			maxNodes, _ := cmd.Flags().GetInt("max-nodes")
+
+			if strategy != "" {
+				strategies, err := fetchStrategies(base, token)
+				if err != nil {
+					return err
+				}
+				var names []string
+				for _, st := range strategies {
+					names = append(names, st.Name)
+				}
+				if !slices.Contains(names, strategy) {
+					return fmt.Errorf("unknown strategy %q; available: %s", strategy, strings.Join(names, ", "))
+				}
+			}
+
+			rule := buildRuleFromMatch(matchStr, target)
+			rule.TargetGroup = group
+			rule.Strategy = strategy
+			rule.Affinity = affinity
+			rule.MaxNodes = maxNodes
+
+			payload, _ := json.Marshal(rule)
+			req, err := http.NewRequest(http.MethodPost, base+"/api/v1/rules", bytes.NewReader(payload))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			client := &http.Client{Timeout: 10 * time.Second}
+			resp, err := client.Do(req)
+			if err != nil {
+				return fmt.Errorf("adding rule: %w", err)
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			if resp.StatusCode != http.StatusCreated {
+				return fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
+			}
+
+			var created types.RoutingRule
+			if err := json.Unmarshal(body, &created); err != nil {
+				return fmt.Errorf("decoding response: %w", err)
+			}
+			fmt.Printf("Rule added: %s\n", created.ID)
+			return nil
+		},
+	}
+	cmd.Flags().String("match", "", "match criteria (e.g. 'gpu:true', 'os:linux', 'skill:docker', 'label:zone=home,tier in (fast,gpu)', 'keyword:xcode', 'command:/gpu', 'source:slack', 'regex:...', 'min_length:500', '*') or an expression ('(skill:docker OR skill:kubernetes) AND NOT os:darwin AND memory_gb>=32')")
+	cmd.Flags().String("target", "", "target node name")
+	cmd.Flags().String("group", "", "target node group")
+	cmd.Flags().String("strategy", "", "node selection strategy; see 'route strategies' (default: least-busy)")
+	cmd.Flags().String("affinity", "", "pin messages to a node by 'source' or 'session'")
+	cmd.Flags().Int("max-nodes", 0, "how many candidates to try if forwarding fails (default 3; 1 disables failover)")
+	_ = cmd.MarkFlagRequired("match")
+	return cmd
+}
+
+func newRouteEditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "edit <id|#>",
+		Short: "Change a routing rule in place",
+		Long:  "Change a routing rule in place, keeping its ID and position. Only the flags given are changed.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			rules, etag, err := fetchRules(base, token)
+			if err != nil {
+				return err
+			}
+			rule, _, err := findRule(rules, args[0])
+			if err != nil {
+				return err
+			}
+
+			flags := cmd.Flags()
+			if flags.Changed("match") {
+				matchStr, _ := flags.GetString("match")
+				m := buildRuleFromMatch(matchStr, "")
+				rule.Match, rule.Expr = m.Match, m.Expr
+			}
+			if flags.Changed("target") {
+				rule.Target, _ = flags.GetString("target")
+			}
+			if flags.Changed("group") {
+				rule.TargetGroup, _ = flags.GetString("group")
+			}
+			if flags.Changed("strategy") {
+				rule.Strategy, _ = flags.GetString("strategy")
+			}
+			if flags.Changed("affinity") {
+				rule.Affinity, _ = flags.GetString("affinity")
+			}
+			if flags.Changed("max-nodes") {
+				rule.MaxNodes, _ = flags.GetInt("max-nodes")
+			}
+
+			if err := apiRequestIfMatch(http.MethodPut, base+"/api/v1/rules/"+rule.ID, token, etag, rule, http.StatusOK, nil); err != nil {
+				return err
+			}
+			fmt.Printf("Rule updated: %s\n", rule.ID)
+			return nil
+		},
+	}
+	cmd.Flags().String("match", "", "new match criteria or expression (as for 'route add')")
+	cmd.Flags().String("target", "", "target node name (empty to clear)")
+	cmd.Flags().String("group", "", "target node group (empty to clear)")
+	cmd.Flags().String("strategy", "", "node selection strategy (empty for the default)")
+	cmd.Flags().String("affinity", "", "'source', 'session' or empty to clear")
+	cmd.Flags().Int("max-nodes", 0, "how many candidates to try if forwarding fails (0 for the default)")
+	return cmd
+}
+
+func newRouteMoveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "move <id|#>",
+		Short: "Change the position at which a rule is evaluated",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			to, _ := cmd.Flags().GetInt("to")
+			before, _ := cmd.Flags().GetString("before")
+			after, _ := cmd.Flags().GetString("after")
+			set := 0
+			for _, given := range []bool{to != 0, before != "", after != ""} {
+				if given {
+					set++
+				}
+			}
+			if set != 1 {
+				return fmt.Errorf("specify exactly one of --to, --before or --after")
+			}
+
+			rules, etag, err := fetchRules(base, token)
+			if err != nil {
+				return err
+			}
+			rule, from, err := findRule(rules, args[0])
+			if err != nil {
+				return err
+			}
+			rest := slices.Delete(slices.Clone(rules), from, from+1)
+			var pos int
+			switch {
+			case to != 0:
+				if to < 1 || to > len(rules) {
+					return fmt.Errorf("--to must be between 1 and %d", len(rules))
+				}
+				pos = to - 1
+			default:
+				ref := before + after
+				anchor, _, err := findRule(rules, ref)
+				if err != nil {
+					return err
+				}
+				if anchor.ID == rule.ID {
+					return fmt.Errorf("cannot move a rule relative to itself")
+				}
+				pos = slices.IndexFunc(rest, func(r *types.RoutingRule) bool { return r.ID == anchor.ID })
+				if after != "" {
+					pos++
+				}
+			}
+			ordered := slices.Insert(rest, pos, rule)
+
+			ids := make([]string, len(ordered))
+			for i, r := range ordered {
+				ids[i] = r.ID
+			}
+			in := map[string][]string{"ids": ids}
+			if err := apiRequestIfMatch(http.MethodPost, base+"/api/v1/rules/reorder", token, etag, in, http.StatusOK, nil); err != nil {
+				return err
+			}
+			fmt.Printf("Rule %s moved to position %d\n", rule.ID, pos+1)
+			return nil
+		},
+	}
+	cmd.Flags().Int("to", 0, "new 1-based position")
+	cmd.Flags().String("before", "", "place before this rule (ID or #)")
+	cmd.Flags().String("after", "", "place after this rule (ID or #)")
+	return cmd
+}
+
+// newRouteEnableCmd builds "route disable" and "route enable".
+func newRouteEnableCmd(use, short string, disabled bool) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " <id|#>",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			rules, etag, err := fetchRules(base, token)
+			if err != nil {
+				return err
+			}
+			rule, _, err := findRule(rules, args[0])
+			if err != nil {
+				return err
+			}
+			rule.Disabled = disabled
+			if err := apiRequestIfMatch(http.MethodPut, base+"/api/v1/rules/"+rule.ID, token, etag, rule, http.StatusOK, nil); err != nil {
+				return err
+			}
+			fmt.Printf("Rule %sd: %s\n", use, rule.ID)
+			return nil
+		},
+	}
+}
+
+func newGroupCmd() *cobra.Command {
+	groupCmd := &cobra.Command{
+		Use:   "group",
+		Short: "Manage node groups",
+	}
+	groupCmd.AddCommand(newGroupListCmd())
+	groupCmd.AddCommand(newGroupSetCmd("add", "Create a node group", http.MethodPost, http.StatusCreated))
+	groupCmd.AddCommand(newGroupSetCmd("update", "Redefine a node group", http.MethodPut, http.StatusOK))
+	groupCmd.AddCommand(newGroupDeleteCmd())
+	return groupCmd
+}
+
+func newGroupListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List node groups and their current members",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			var groups []*types.Group
+			if err := apiRequest(http.MethodGet, base+"/api/v1/groups", token, nil, http.StatusOK, &groups); err != nil {
+				return err
+			}
+			if len(groups) == 0 {
+				fmt.Println("No node groups defined.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tDEFINITION\tNODES")
+			for _, g := range groups {
+				def := "selector:" + g.Selector
+				if g.Selector == "" {
+					def = "members:" + strings.Join(g.Members, ",")
+				}
+				nodes := strings.Join(g.Nodes, ",")
+				if nodes == "" {
+					nodes = "-"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", g.Name, def, nodes)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
+
+// newGroupSetCmd builds "group add" and "group update", which share flags.
+func newGroupSetCmd(use, short, method string, want int) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use + " <name>",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			members, _ := cmd.Flags().GetStringSlice("members")
+			selector, _ := cmd.Flags().GetString("selector")
+
+			req := map[string]any{"members": members, "selector": selector}
+			url := base + "/api/v1/groups/" + args[0]
+			if method == http.MethodPost {
+				req["name"] = args[0]
+				url = base + "/api/v1/groups"
+			}
+			var g types.Group
+			if err := apiRequest(method, url, token, req, want, &g); err != nil {
+				return err
+			}
+			fmt.Printf("Group %s: %d nodes\n", g.Name, len(g.Nodes))
+			return nil
+		},
+	}
+	cmd.Flags().StringSlice("members", nil, "node names or IDs")
+	cmd.Flags().String("selector", "", "label selector defining membership (e.g. 'zone=home,tier in (fast,gpu)')")
+	return cmd
+}
+
+func newGroupDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a node group",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			if err := apiRequest(http.MethodDelete, base+"/api/v1/groups/"+args[0], token, nil, http.StatusNoContent, nil); err != nil {
+				return err
+			}
+			fmt.Printf("Group deleted: %s\n", args[0])
+			return nil
+		},
+	}
+}
+
+func newWebhookCmd() *cobra.Command {
+	hookCmd := &cobra.Command{
+		Use:   "webhook",
+		Short: "Manage outbound event webhooks",
+	}
+	hookCmd.AddCommand(newWebhookListCmd())
+	hookCmd.AddCommand(newWebhookAddCmd())
+	hookCmd.AddCommand(newWebhookDeleteCmd())
+	hookCmd.AddCommand(newWebhookTestCmd())
+	hookCmd.AddCommand(newWebhookDeliveriesCmd())
+	return hookCmd
+}
+
+func newWebhookListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List webhooks",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			var hooks []*types.Webhook
+			if err := apiRequest(http.MethodGet, base+"/api/v1/webhooks", token, nil, http.StatusOK, &hooks); err != nil {
+				return err
+			}
+			if len(hooks) == 0 {
+				fmt.Println("No webhooks configured.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tURL\tEVENTS\tENABLED")
+			for _, h := range hooks {
+				events := strings.Join(h.Events, ",")
+				if events == "" {
+					events = "*"
+				}
+				enabled := "yes"
+				if h.Disabled {
+					enabled = "no"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", h.ID, h.URL, events, enabled)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
+
+func newWebhookAddCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add <url>",
+		Short: "Subscribe a URL to mesh events",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			events, _ := cmd.Flags().GetString("events")
+			secret, _ := cmd.Flags().GetString("secret")
+
+			req := map[string]any{"url": args[0]}
+			if events != "" {
+				var patterns []string
+				for _, p := range strings.Split(events, ",") {
+					if p = strings.TrimSpace(p); p != "" {
+						patterns = append(patterns, p)
+					}
+				}
+				req["events"] = patterns
+			}
+			if secret != "" {
+				req["secret"] = secret
+			}
+
+			var created types.Webhook
+			if err := apiRequest(http.MethodPost, base+"/api/v1/webhooks", token, req, http.StatusCreated, &created); err != nil {
+				return err
+			}
+			fmt.Printf("Webhook added: %s\n", created.ID)
+			fmt.Printf("Signing secret: %s (shown only once)\n", created.Secret)
+			return nil
+		},
+	}
+	cmd.Flags().String("events", "", "comma-separated event types to deliver (e.g. 'node.offline,message.failed'; default: all)")
+	cmd.Flags().String("secret", "", "HMAC signing secret (default: generated)")
+	return cmd
+}
+
+func newWebhookDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <id>",
+		Short: "Delete a webhook",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			if err := apiRequest(http.MethodDelete, base+"/api/v1/webhooks/"+args[0], token, nil, http.StatusNoContent, nil); err != nil {
+				return err
+			}
+			fmt.Printf("Webhook deleted: %s\n", args[0])
+			return nil
+		},
+	}
+}
+
+func newWebhookTestCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "test <id>",
+		Short: "Send a webhook.test event to a webhook",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			var d types.WebhookDelivery
+			if err := apiRequest(http.MethodPost, base+"/api/v1/webhooks/"+args[0]+"/test", token, nil, http.StatusAccepted, &d); err != nil {
+				return err
+			}
+			fmt.Printf("Test delivery queued: %s\n", d.ID)
+			return nil
+		},
+	}
+}
+
+func newWebhookDeliveriesCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "deliveries <id>",
+		Short: "Show recent deliveries for a webhook",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, token := coordFlags(cmd)
+			var deliveries []*types.WebhookDelivery
+			if err := apiRequest(http.MethodGet, base+"/api/v1/webhooks/"+args[0]+"/deliveries", token, nil, http.StatusOK, &deliveries); err != nil {
+				return err
+			}
+			if len(deliveries) == 0 {
+				fmt.Println("No deliveries yet.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "TIME\tEVENT\tATTEMPTS\tSTATUS\tRESULT")
+			for _, d := range deliveries {
+				status := "-"
+				if d.StatusCode != 0 {
+					status = fmt.Sprintf("%d", d.StatusCode)
+				}
+				result := "ok"
+				if !d.Success {
+					result = d.Error
+					if result == "" {
+						result = "pending"
+					}
+				}
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n",
+					d.UpdatedAt.Local().Format("15:04:05"), d.EventType, d.Attempts, status, result)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
+
+// --- helpers ---
+
+func loadConfig(cmd *cobra.Command) (*config.Config, error) {
+	cfgFile, _ := cmd.Flags().GetString("config")
+	return config.Load(cfgFile)
+}
+
+func coordFlags(cmd *cobra.Command) (string, string) {
+	base, _ := cmd.Flags().GetString("coordinator")
+	token, _ := cmd.Flags().GetString("token")
+	if base == "" {
+		base = "http://127.0.0.1:9180"
+	}
+	// Token precedence: flag -> config file -> CLAW_MESH_TOKEN env var.
+	if token == "" {
+		cfg, err := loadConfig(cmd)
+		if err == nil && cfg.Coordinator.Token != "" {
+			token = cfg.Coordinator.Token
+		}
+	}
+	if token == "" {
+		token = os.Getenv("CLAW_MESH_TOKEN")
+	}
+	return base, token
+}
+
+// resolveToken returns the auth token using precedence: flag -> config -> env.
+func resolveToken(cmd *cobra.Command, cfg *config.Config) string {
+	token, _ := cmd.Flags().GetString("token")
+	if token != "" {
+		return token
+	}
+	if cfg != nil && cfg.Coordinator.Token != "" {
+		return cfg.Coordinator.Token
+	}
+	if env := os.Getenv("CLAW_MESH_TOKEN"); env != "" {
+		return env
+	}
+	return ""
+}
+
+// defaultCredentialPath returns where a node keeps its mesh identity,
+// keyed by node name so several agents can share one machine.
+func defaultCredentialPath(name string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, name)
+	return filepath.Join(home, ".claw-mesh", "node-"+safe+".json")
+}
+
+// detectOutboundIP finds the preferred outbound IP by dialing a UDP socket.
+func detectOutboundIP() string {
+	conn, err := net.Dial("udp4", "8.8.8.8:80")
+	if err != nil {
+		return ""
 	}
 	defer conn.Close()
 	addr := conn.LocalAddr().(*net.UDPAddr)
@@ -678,10 +2393,12 @@ func fetchNodes(base, token string) ([]*types.Node, error) {
 	return nodes, nil
 }
 
-func fetchRules(base, token string) ([]*types.RoutingRule, error) {
+// fetchRules returns the routing rules in evaluation order and the ETag
+// of their revision, to pass as If-Match when changing them.
+func fetchRules(base, token string) ([]*types.RoutingRule, string, error) {
 	req, err := http.NewRequest(http.MethodGet, base+"/api/v1/rules", nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	if token != "" {
 		req.Header.Set("Authorization", "Bearer "+token)
@@ -689,17 +2406,134 @@ func fetchRules(base, token string) ([]*types.RoutingRule, error) {
 	client := &http.Client{Timeout: 10 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("connecting to coordinator: %w", err)
+		return nil, "", fmt.Errorf("connecting to coordinator: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("coordinator returned %d", resp.StatusCode)
+		return nil, "", fmt.Errorf("coordinator returned %d", resp.StatusCode)
 	}
 	var rules []*types.RoutingRule
 	if err := json.NewDecoder(resp.Body).Decode(&rules); err != nil {
-		return nil, fmt.Errorf("decoding rules: %w", err)
+		return nil, "", fmt.Errorf("decoding rules: %w", err)
+	}
+	return rules, resp.Header.Get("ETag"), nil
+}
+
+// findRule returns the rule with the given ID, or with the given 1-based
+// position as shown by "route list", and its index.
+func findRule(rules []*types.RoutingRule, ref string) (*types.RoutingRule, int, error) {
+	for i, r := range rules {
+		if r.ID == ref {
+			return r, i, nil
+		}
+	}
+	if n, err := strconv.Atoi(ref); err == nil && n >= 1 && n <= len(rules) {
+		return rules[n-1], n - 1, nil
+	}
+	return nil, 0, fmt.Errorf("rule %q not found", ref)
+}
+
+// setNodeMaintenance resolves a node by name or ID and posts a
+// cordon, drain or uncordon action for it.
+func setNodeMaintenance(base, token, nameOrID, action string) (*types.Node, error) {
+	id, err := resolveNodeID(base, token, nameOrID)
+	if err != nil {
+		return nil, err
+	}
+	var n types.Node
+	if err := apiRequest(http.MethodPost, base+"/api/v1/nodes/"+id+"/"+action, token, nil, http.StatusOK, &n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// formatNodeLabels renders labels as sorted key=value pairs.
+func formatNodeLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "-"
+	}
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// describeMaintenance summarizes a node's maintenance state for CLI output.
+func describeMaintenance(n *types.Node) string {
+	switch n.Maintenance {
+	case "":
+		return "in rotation"
+	case types.MaintenanceDraining:
+		return fmt.Sprintf("draining (%d in flight)", n.InFlight)
+	default:
+		return string(n.Maintenance)
+	}
+}
+
+// apiRequest sends a JSON request to the coordinator and decodes the
+// response into out (if non-nil). Any status other than want is an error.
+func apiRequest(method, url, token string, in any, want int, out any) error {
+	return apiRequestIfMatch(method, url, token, "", in, want, out)
+}
+
+// apiRequestIfMatch is apiRequest with an If-Match header, which makes a
+// rule change fail if the rules changed since ifMatch was read.
+func apiRequestIfMatch(method, url, token, ifMatch string, in any, want int, out any) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("connecting to coordinator: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != want {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			return fmt.Errorf("coordinator returned %d: %s", resp.StatusCode, e.Error)
+		}
+		return fmt.Errorf("coordinator returned %d", resp.StatusCode)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+	}
+	return nil
+}
+
+func fetchStrategies(base, token string) ([]types.StrategyInfo, error) {
+	var strategies []types.StrategyInfo
+	if err := apiRequest(http.MethodGet, base+"/api/v1/strategies", token, nil, http.StatusOK, &strategies); err != nil {
+		return nil, err
 	}
-	return rules, nil
+	return strategies, nil
 }
 
 func resolveNodeID(base, token, nameOrID string) (string, error) {
@@ -717,7 +2551,7 @@ func resolveNodeID(base, token, nameOrID string) (string, error) {
 
 func printNodesTable(nodes []*types.Node) {
 	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
-	fmt.Fprintln(w, "ID\tNAME\tSTATUS\tENDPOINT\tOS/ARCH\tGPU\tSKILLS")
+	fmt.Fprintln(w, "ID\tNAME\tSTATUS\tIN FLIGHT\tENDPOINT\tOS/ARCH\tGPU\tSKILLS\tLABELS")
 	for _, n := range nodes {
 		gpu := "no"
 		if n.Capabilities.GPU {
@@ -727,14 +2561,55 @@ func printNodesTable(nodes []*types.Node) {
 		if skills == "" {
 			skills = "-"
 		}
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s/%s\t%s\t%s\n",
-			n.ID, n.Name, n.Status, n.Endpoint,
+		status := string(n.Status)
+		if n.Maintenance != "" {
+			status += "," + string(n.Maintenance)
+		}
+		if n.Circuit != nil && n.Circuit.State != "closed" {
+			status += ",circuit-" + n.Circuit.State
+		}
+		inFlight := strconv.Itoa(n.InFlight)
+		if limit := n.ConcurrencyLimit(); limit > 0 {
+			inFlight += "/" + strconv.Itoa(limit)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s/%s\t%s\t%s\t%s\n",
+			n.ID, n.Name, status, inFlight, n.Endpoint,
 			n.Capabilities.OS, n.Capabilities.Arch,
-			gpu, skills)
+			gpu, skills, formatNodeLabels(n.Labels))
 	}
 	w.Flush()
 }
 
+// formatEvent renders an event as a single human-readable line.
+func formatEvent(ev *types.Event) string {
+	parts := []string{ev.Time.Local().Format("15:04:05"), fmt.Sprintf("#%d", ev.ID), string(ev.Type)}
+	if ev.NodeID != "" {
+		parts = append(parts, "node="+ev.NodeID)
+	}
+	if ev.RuleID != "" {
+		parts = append(parts, "rule="+ev.RuleID)
+	}
+	if ev.MessageID != "" {
+		parts = append(parts, "message="+ev.MessageID)
+	}
+	keys := make([]string, 0, len(ev.Data))
+	for k := range ev.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := ev.Data[k]
+		switch v.(type) {
+		case string, float64, bool:
+			parts = append(parts, fmt.Sprintf("%s=%v", k, v))
+		default:
+			b, _ := json.Marshal(v)
+			parts = append(parts, fmt.Sprintf("%s=%s", k, b))
+		}
+	}
+	return strings.Join(parts, "  ")
+}
+
 func describeMatch(mc *types.MatchCriteria) string {
 	if mc.Wildcard != nil && *mc.Wildcard {
 		return "*"
@@ -749,6 +2624,27 @@ func describeMatch(mc *types.MatchCriteria) string {
 	if mc.RequiresSkill != "" {
 		parts = append(parts, "skill:"+mc.RequiresSkill)
 	}
+	if mc.LabelSelector != "" {
+		parts = append(parts, "label:"+mc.LabelSelector)
+	}
+	for _, kw := range mc.Keywords {
+		parts = append(parts, "keyword:"+kw)
+	}
+	if mc.ContentRegex != "" {
+		parts = append(parts, "regex:"+mc.ContentRegex)
+	}
+	if mc.Command != "" {
+		parts = append(parts, "command:"+mc.Command)
+	}
+	if mc.Source != "" {
+		parts = append(parts, "source:"+mc.Source)
+	}
+	if mc.MinLength > 0 {
+		parts = append(parts, "min_length:"+strconv.Itoa(mc.MinLength))
+	}
+	if mc.MaxLength > 0 {
+		parts = append(parts, "max_length:"+strconv.Itoa(mc.MaxLength))
+	}
 	if len(parts) == 0 {
 		return "-"
 	}
@@ -809,9 +2705,24 @@ func resolveGatewayTimeout(cmd *cobra.Command, cfg *config.Config) int {
 
 func buildRuleFromMatch(matchStr, target string) types.RoutingRule {
 	rule := types.RoutingRule{Target: target}
-	for _, part := range strings.Split(matchStr, ",") {
+	if strings.TrimSpace(matchStr) == "*" {
+		wildcard := true
+		rule.Match.Wildcard = &wildcard
+		return rule
+	}
+	if isMatchExpr(matchStr) {
+		rule.Expr = strings.TrimSpace(matchStr)
+		return rule
+	}
+	var selectors []string
+	for _, part := range splitMatchParts(matchStr) {
 		kv := strings.SplitN(strings.TrimSpace(part), ":", 2)
 		if len(kv) != 2 {
+			// Bare selector terms following a label: part belong to it,
+			// e.g. "label:zone=home,tier!=slow".
+			if len(selectors) > 0 && strings.TrimSpace(part) != "" {
+				selectors = append(selectors, strings.TrimSpace(part))
+			}
 			continue
 		}
 		switch kv[0] {
@@ -822,11 +2733,84 @@ func buildRuleFromMatch(matchStr, target string) types.RoutingRule {
 			rule.Match.RequiresOS = kv[1]
 		case "skill":
 			rule.Match.RequiresSkill = kv[1]
+		case "label":
+			selectors = append(selectors, strings.TrimSpace(kv[1]))
+		case "keyword":
+			rule.Match.Keywords = append(rule.Match.Keywords, kv[1])
+		case "regex":
+			rule.Match.ContentRegex = kv[1]
+		case "command":
+			rule.Match.Command = kv[1]
+		case "source":
+			rule.Match.Source = kv[1]
+		case "min_length":
+			rule.Match.MinLength, _ = strconv.Atoi(kv[1])
+		case "max_length":
+			rule.Match.MaxLength, _ = strconv.Atoi(kv[1])
 		}
 	}
+	rule.Match.LabelSelector = strings.Join(selectors, ",")
 	return rule
 }
 
+// simpleMatchKeys are the "key:value" parts understood by buildRuleFromMatch
+// besides "label:".
+var simpleMatchKeys = []string{"gpu", "os", "skill", "keyword", "regex", "command", "source", "min_length", "max_length"}
+
+// isMatchExpr reports whether a --match string is a boolean expression
+// ("skill:docker OR os:linux", "memory_gb>=32") rather than the
+// comma-separated "key:value" form. Anything the simple form can't
+// represent is sent as an expression and validated by the coordinator.
+func isMatchExpr(matchStr string) bool {
+	s := strings.TrimSpace(matchStr)
+	if strings.HasPrefix(s, "(") || strings.HasPrefix(s, "!") ||
+		strings.Contains(s, "&&") || strings.Contains(s, "||") {
+		return true
+	}
+	for _, w := range strings.Fields(s) {
+		switch strings.ToUpper(strings.Trim(w, "()")) {
+		case "AND", "OR", "NOT":
+			return true
+		}
+	}
+	inLabel := false
+	for _, part := range splitMatchParts(s) {
+		part = strings.TrimSpace(part)
+		key, _, ok := strings.Cut(part, ":")
+		switch {
+		case part == "":
+		case ok && slices.Contains(simpleMatchKeys, key):
+			inLabel = false
+		case ok && key == "label":
+			inLabel = true
+		case !ok && inLabel:
+			// Continuation of a label selector.
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// splitMatchParts splits a --match string on commas outside parentheses,
+// so "label:zone in (home,lab),gpu:true" keeps the value list intact.
+func splitMatchParts(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
 func main() {
 	if err := NewRootCmd().Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -32,14 +32,58 @@ type CoordinatorConfig struct {
 	DataDir        string `json:"data_dir,omitempty" yaml:"data_dir,omitempty" mapstructure:"data_dir"`
 	WorkspaceDir   string `json:"workspace_dir,omitempty" yaml:"workspace_dir,omitempty" mapstructure:"workspace_dir"`
 	OpenClawConfig string `json:"openclaw_config,omitempty" yaml:"openclaw_config,omitempty" mapstructure:"openclaw_config"`
+	// RulesFile declares the routing rules in YAML. When set, the file is
+	// the source of truth: it is reloaded on change and the rules API is
+	// read-only.
+	RulesFile string `json:"rules_file,omitempty" yaml:"rules_file,omitempty" mapstructure:"rules_file"`
+	// QueueSize bounds how many messages may wait for a node below its
+	// concurrency limit, and QueueTimeout (seconds) how long each waits.
+	QueueSize    int `json:"queue_size,omitempty" yaml:"queue_size,omitempty" mapstructure:"queue_size"`
+	QueueTimeout int `json:"queue_timeout,omitempty" yaml:"queue_timeout,omitempty" mapstructure:"queue_timeout"`
+	// Planner, when its type is set, splits auto-routed messages that need
+	// several nodes into task plans.
+	Planner PlannerConfig `json:"planner,omitempty" yaml:"planner,omitempty" mapstructure:"planner"`
+}
+
+// PlannerConfig selects and configures the coordinator's planner.
+type PlannerConfig struct {
+	// Type is "rules" or "llm"; empty disables planning.
+	Type string `json:"type,omitempty" yaml:"type,omitempty" mapstructure:"type"`
+	// Node is the ID or name of the node the llm planner asks for plans.
+	Node string `json:"node,omitempty" yaml:"node,omitempty" mapstructure:"node"`
+	// TimeoutSec bounds how long planning may take before the message is
+	// routed as a single step (default 10, less than 300).
+	TimeoutSec int `json:"timeout_sec,omitempty" yaml:"timeout_sec,omitempty" mapstructure:"timeout_sec"`
+	// Rules are tried in order by the rules planner.
+	Rules []PlannerRule `json:"rules,omitempty" yaml:"rules,omitempty" mapstructure:"rules"`
+}
+
+// PlannerRule turns messages matching a regular expression into a fixed
+// sequence of steps.
+type PlannerRule struct {
+	Match string        `json:"match" yaml:"match" mapstructure:"match"`
+	Steps []PlannerStep `json:"steps" yaml:"steps" mapstructure:"steps"`
+}
+
+// PlannerStep is a step of a PlannerRule, as in a submitted task plan.
+type PlannerStep struct {
+	ID         string   `json:"id,omitempty" yaml:"id,omitempty" mapstructure:"id"`
+	Skill      string   `json:"skill" yaml:"skill" mapstructure:"skill"`
+	Prompt     string   `json:"prompt" yaml:"prompt" mapstructure:"prompt"`
+	DependsOn  []string `json:"depends_on,omitempty" yaml:"depends_on,omitempty" mapstructure:"depends_on"`
+	TimeoutSec int      `json:"timeout_sec,omitempty" yaml:"timeout_sec,omitempty" mapstructure:"timeout_sec"`
 }
 
 // NodeConfig holds node agent settings.
 type NodeConfig struct {
-	Name     string        `json:"name" yaml:"name" mapstructure:"name"`
-	Tags     []string      `json:"tags" yaml:"tags" mapstructure:"tags"`
-	Endpoint string        `json:"endpoint" yaml:"endpoint" mapstructure:"endpoint"`
-	Gateway  GatewayConfig `json:"gateway" yaml:"gateway" mapstructure:"gateway"`
+	Name   string            `json:"name" yaml:"name" mapstructure:"name"`
+	Tags   []string          `json:"tags" yaml:"tags" mapstructure:"tags"`
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty" mapstructure:"labels"`
+	// MaxConcurrency caps how many messages the coordinator sends this node
+	// at once; 0 means unlimited.
+	MaxConcurrency int           `json:"max_concurrency,omitempty" yaml:"max_concurrency,omitempty" mapstructure:"max_concurrency"`
+	Endpoint       string        `json:"endpoint" yaml:"endpoint" mapstructure:"endpoint"`
+	Gateway        GatewayConfig `json:"gateway" yaml:"gateway" mapstructure:"gateway"`
 }
 
 // GatewayConfig holds OpenClaw Gateway connection settings.
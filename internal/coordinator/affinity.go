@@ -0,0 +1,180 @@
+package coordinator
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+const (
+	// Affinity modes for RoutingRule.Affinity.
+	affinitySource  = "source"
+	affinitySession = "session"
+
+	// affinityTTL is how long an unused affinity entry is kept.
+	affinityTTL = time.Hour
+	// maxAffinityEntries bounds the table; the least recently used entries
+	// are evicted first.
+	maxAffinityEntries = 10000
+)
+
+// affinityTable pins conversation keys (a message source or session ID)
+// to nodes. New keys are placed by rendezvous hashing over the healthy
+// candidates; an existing key stays on its node while that node remains a
+// candidate. When the node drops out, only its keys move, again by
+// rendezvous hashing, so the rest of the table is undisturbed.
+type affinityTable struct {
+	mu      sync.Mutex
+	entries map[string]*types.AffinityEntry // ruleID + "/" + mode + ":" + key
+}
+
+func newAffinityTable() *affinityTable {
+	return &affinityTable{entries: make(map[string]*types.AffinityEntry)}
+}
+
+// affinityKey returns the key a message is pinned by under the given mode,
+// or "" if the message doesn't carry one.
+func affinityKey(mode string, msg *types.Message) string {
+	switch mode {
+	case affinitySource:
+		return msg.Source
+	case affinitySession:
+		return msg.SessionID
+	}
+	return ""
+}
+
+// pick returns the node for key among candidates, recording the choice.
+func (t *affinityTable) pick(ruleID, mode, key string, candidates []*types.Node) *types.Node {
+	id := ruleID + "/" + mode + ":" + key
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[id]
+	live := ok && now.Sub(e.LastUsed) <= affinityTTL
+	if live {
+		if n := nodeByID(candidates, e.NodeID); n != nil {
+			e.LastUsed = now
+			e.Hits++
+			return n
+		}
+	}
+
+	n := rendezvous(key, candidates)
+	if live {
+		log.Printf("affinity %s:%s moved from node %s to %s", mode, key, e.NodeID, n.ID)
+		e.NodeID = n.ID
+		e.LastUsed = now
+		e.Hits++
+		e.Reassignments++
+		return n
+	}
+
+	t.evictLocked(now)
+	t.entries[id] = &types.AffinityEntry{
+		RuleID:    ruleID,
+		Mode:      mode,
+		Key:       key,
+		NodeID:    n.ID,
+		CreatedAt: now,
+		LastUsed:  now,
+		Hits:      1,
+	}
+	return n
+}
+
+// lookup returns the node pick would choose, without recording anything.
+func (t *affinityTable) lookup(ruleID, mode, key string, candidates []*types.Node) *types.Node {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e, ok := t.entries[ruleID+"/"+mode+":"+key]; ok && time.Since(e.LastUsed) <= affinityTTL {
+		if n := nodeByID(candidates, e.NodeID); n != nil {
+			return n
+		}
+	}
+	return rendezvous(key, candidates)
+}
+
+func nodeByID(nodes []*types.Node, id string) *types.Node {
+	for _, n := range nodes {
+		if n.ID == id {
+			return n
+		}
+	}
+	return nil
+}
+
+// evictLocked drops expired entries and, if the table is still full, the
+// least recently used one. Callers must hold t.mu.
+func (t *affinityTable) evictLocked(now time.Time) {
+	var oldestID string
+	var oldest time.Time
+	for id, e := range t.entries {
+		if now.Sub(e.LastUsed) > affinityTTL {
+			delete(t.entries, id)
+			continue
+		}
+		if oldestID == "" || e.LastUsed.Before(oldest) {
+			oldestID, oldest = id, e.LastUsed
+		}
+	}
+	if len(t.entries) >= maxAffinityEntries && oldestID != "" {
+		delete(t.entries, oldestID)
+	}
+}
+
+// forgetRule drops all entries created by a rule.
+func (t *affinityTable) forgetRule(ruleID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, e := range t.entries {
+		if e.RuleID == ruleID {
+			delete(t.entries, id)
+		}
+	}
+}
+
+// list returns copies of the live entries, most recently used first.
+func (t *affinityTable) list() []*types.AffinityEntry {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]*types.AffinityEntry, 0, len(t.entries))
+	for _, e := range t.entries {
+		if now.Sub(e.LastUsed) > affinityTTL {
+			continue
+		}
+		cp := *e
+		out = append(out, &cp)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LastUsed.After(out[j].LastUsed) })
+	return out
+}
+
+// rendezvous returns the candidate with the highest hash of (key, node ID).
+// Removing a node only remaps the keys that node was winning.
+func rendezvous(key string, nodes []*types.Node) *types.Node {
+	var best *types.Node
+	var bestScore uint64
+	for _, n := range nodes {
+		h := sha256.Sum256([]byte(key + "\x00" + n.ID))
+		score := binary.BigEndian.Uint64(h[:8])
+		if best == nil || score > bestScore || (score == bestScore && n.ID < best.ID) {
+			best, bestScore = n, score
+		}
+	}
+	return best
+}
+
+// handleListAffinity handles GET /api/v1/affinity.
+func (s *Server) handleListAffinity(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.router.affinity.list())
+}
@@ -1,19 +1,46 @@
 package coordinator
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/SallyKAN/claw-mesh/internal/types"
 )
 
 // handleRouteAuto handles POST /api/v1/route — auto-route a message.
+// With ?async=true the message is forwarded as a job: the reply is 202
+// with the job, whose result is fetched from /api/v1/jobs/{id}. With
+// ?plan=true it is a job too, which first asks the planner to split the
+// message; if it does, the job's plan_id is set instead of its result.
+// Asking for a plan without a planner configured is an error.
 func (s *Server) handleRouteAuto(w http.ResponseWriter, r *http.Request) {
+	async, err := boolParam(r, "async")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	plan, err := boolParam(r, "plan")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if plan && s.planner == nil {
+		writeJSON(w, http.StatusNotImplemented, map[string]string{"error": "planning is unavailable: no planner is configured"})
+		return
+	}
 	var req struct {
-		Content string `json:"content"`
-		Source  string `json:"source"`
+		Content   string `json:"content"`
+		Source    string `json:"source"`
+		SessionID string `json:"session_id"`
 	}
 	if err := decodeJSON(w, r, &req); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
@@ -34,34 +61,68 @@ func (s *Server) handleRouteAuto(w http.ResponseWriter, r *http.Request) {
 		ID:        msgID,
 		Content:   req.Content,
 		Source:    req.Source,
+		SessionID: req.SessionID,
 		CreatedAt: time.Now(),
 	}
 
-	node, err := s.router.Route(msg)
+	// Messages in a session are not planned, so the session stays on one
+	// node. The planner can take a while, so it runs in the job rather
+	// than before the reply.
+	if plan && msg.SessionID == "" {
+		s.startJob(w, msg, func(ctx context.Context) (jobResult, error) {
+			if p := s.planMessage(ctx, msg); p != nil {
+				return jobResult{planID: p.ID}, nil
+			}
+			nodes, err := s.router.RouteWithFailover(msg)
+			if err != nil {
+				return jobResult{}, err
+			}
+			resp, attempts, err := s.forwardWithFailover(ctx, nodes, msg)
+			return jobResult{resp: resp, attempts: attempts}, err
+		})
+		return
+	}
+
+	nodes, err := s.router.RouteWithFailover(msg)
 	if err != nil {
 		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
 		return
 	}
 
-	log.Printf("forwarding message %s to node %s (%s)", msg.ID, node.ID, node.Name)
-	nodeToken := s.registry.GetNodeToken(node.ID)
-	fwdResp, err := s.forwarder.ForwardMessage(r.Context(), node, msg, nodeToken)
+	if async || plan {
+		s.startJob(w, msg, func(ctx context.Context) (jobResult, error) {
+			resp, attempts, err := s.forwardWithFailover(ctx, nodes, msg)
+			return jobResult{resp: resp, attempts: attempts}, err
+		})
+		return
+	}
+
+	fwdResp, attempts, err := s.forwardWithFailover(r.Context(), nodes, msg)
 	if err != nil {
-		log.Printf("forward failed for message %s: %v", msg.ID, err)
-		writeJSON(w, http.StatusBadGateway, map[string]string{"error": fmt.Sprintf("forwarding failed: %v", err)})
+		status := http.StatusBadGateway
+		if isQueueError(err) {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, map[string]any{"error": fmt.Sprintf("forwarding failed: %v", err), "attempts": attempts})
 		return
 	}
-	fwdResp.NodeID = node.ID
 	writeJSON(w, http.StatusOK, fwdResp)
 }
 
-// handleRouteToNode handles POST /api/v1/route/{nodeId} — route to a specific node.
+// handleRouteToNode handles POST /api/v1/route/{nodeId} — route to a
+// specific node. It accepts ?async=true like handleRouteAuto.
 func (s *Server) handleRouteToNode(w http.ResponseWriter, r *http.Request) {
 	nodeID := r.PathValue("nodeId")
+	async, err := boolParam(r, "async")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
 
 	var req struct {
-		Content string `json:"content"`
-		Source  string `json:"source"`
+		Content   string `json:"content"`
+		Source    string `json:"source"`
+		SessionID string `json:"session_id"`
 	}
 	if err := decodeJSON(w, r, &req); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
@@ -82,6 +143,7 @@ func (s *Server) handleRouteToNode(w http.ResponseWriter, r *http.Request) {
 		ID:         msgID,
 		Content:    req.Content,
 		Source:     req.Source,
+		SessionID:  req.SessionID,
 		TargetNode: nodeID,
 		CreatedAt:  time.Now(),
 	}
@@ -100,37 +162,255 @@ func (s *Server) handleRouteToNode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if async {
+		s.startJob(w, msg, func(ctx context.Context) (jobResult, error) {
+			resp, err := s.forward(ctx, node, msg)
+			return jobResult{resp: resp}, err
+		})
+		return
+	}
+
+	fwdResp, err := s.forward(r.Context(), node, msg)
+	if err != nil {
+		status := http.StatusBadGateway
+		if isQueueError(err) {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, map[string]string{"error": fmt.Sprintf("forwarding failed: %v", err)})
+		return
+	}
+	writeJSON(w, http.StatusOK, fwdResp)
+}
+
+// forward sends msg to node, waiting for the node to be below its
+// concurrency limit first. It can be cancelled by message ID.
+func (s *Server) forward(ctx context.Context, node *types.Node, msg *types.Message) (*types.MessageResponse, error) {
+	ctx, done := s.inflight.track(ctx, msg.ID)
+	defer done()
+	if _, err := s.queue.acquire(ctx, []*types.Node{node}); err != nil {
+		err = cancelledErr(ctx, err)
+		s.publishQueueFailure(node, msg, err)
+		return nil, err
+	}
+	defer s.queue.release(node.ID)
+	resp, err := s.send(ctx, node, msg)
+	return resp, cancelledErr(ctx, err)
+}
+
+// publishQueueFailure logs and publishes a forward that never got a slot.
+func (s *Server) publishQueueFailure(node *types.Node, msg *types.Message, err error) {
+	log.Printf("forward failed for message %s: %v", msg.ID, err)
+	s.events.Publish(types.Event{
+		Type:      types.EventMessageFailed,
+		NodeID:    node.ID,
+		MessageID: msg.ID,
+		Data:      map[string]any{"name": node.Name, "error": err.Error(), "queued": s.queue.queued()},
+	})
+}
+
+// send forwards msg to node with the node's token and publishes the
+// routing outcome on the event bus. The caller holds a slot on the node.
+func (s *Server) send(ctx context.Context, node *types.Node, msg *types.Message) (*types.MessageResponse, error) {
 	log.Printf("forwarding message %s to node %s (%s)", msg.ID, node.ID, node.Name)
+	s.events.Publish(types.Event{
+		Type:      types.EventMessageRouted,
+		NodeID:    node.ID,
+		MessageID: msg.ID,
+		Data:      map[string]any{"name": node.Name, "source": msg.Source},
+	})
+
+	start := time.Now()
 	nodeToken := s.registry.GetNodeToken(node.ID)
-	fwdResp, err := s.forwarder.ForwardMessage(r.Context(), node, msg, nodeToken)
+	fwdResp, err := s.forwarder.ForwardMessage(ctx, node, msg, nodeToken)
+	elapsed := time.Since(start)
+	if err != nil && ctx.Err() != nil {
+		// We gave up on the message (cancelled, timed out or no longer
+		// needed); make sure the node stops working on it too.
+		go s.cancelOnNode(node, msg.ID, nodeToken)
+	}
 	if err != nil {
 		log.Printf("forward failed for message %s: %v", msg.ID, err)
-		writeJSON(w, http.StatusBadGateway, map[string]string{"error": fmt.Sprintf("forwarding failed: %v", err)})
-		return
+		s.events.Publish(types.Event{
+			Type:      types.EventMessageFailed,
+			NodeID:    node.ID,
+			MessageID: msg.ID,
+			Data:      map[string]any{"name": node.Name, "error": err.Error(), "duration_ms": elapsed.Milliseconds()},
+		})
+		return nil, err
 	}
+	s.events.Publish(types.Event{
+		Type:      types.EventMessageForwarded,
+		NodeID:    node.ID,
+		MessageID: msg.ID,
+		Data:      map[string]any{"name": node.Name, "duration_ms": elapsed.Milliseconds()},
+	})
 	fwdResp.NodeID = node.ID
-	writeJSON(w, http.StatusOK, fwdResp)
+	return fwdResp, nil
 }
 
-// handleListRules handles GET /api/v1/rules.
+// cancelOnNode tells node to stop working on a message, logging failures.
+func (s *Server) cancelOnNode(node *types.Node, msgID, token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.forwarder.CancelMessage(ctx, node, msgID, token); err != nil {
+		log.Printf("WARN: could not cancel message %s on node %s: %v", msgID, node.ID, err)
+	}
+}
+
+// forwardWithFailover tries nodes until one answers, preferring them in
+// order but skipping nodes at their concurrency limit; if all are at it, it
+// waits in the queue for the first to free up. It moves on after a
+// transient error or a Gateway error reply; other errors are returned at
+// once. If no node answered properly but one gave a Gateway error, its
+// echo reply is returned. The attempts made are recorded on the response.
+// Like forward, it can be cancelled by message ID.
+func (s *Server) forwardWithFailover(ctx context.Context, nodes []*types.Node, msg *types.Message) (*types.MessageResponse, []types.ForwardAttempt, error) {
+	ctx, done := s.inflight.track(ctx, msg.ID)
+	defer done()
+	var attempts []types.ForwardAttempt
+	var echo *types.MessageResponse
+	var lastErr error
+	remaining := slices.Clone(nodes)
+	for i := 0; len(remaining) > 0; i++ {
+		node, err := s.queue.acquire(ctx, remaining)
+		if err != nil {
+			s.publishQueueFailure(remaining[0], msg, err)
+			lastErr = err
+			break
+		}
+		remaining = slices.DeleteFunc(remaining, func(n *types.Node) bool { return n.ID == node.ID })
+		if i > 0 {
+			log.Printf("failing over message %s to node %s (%s)", msg.ID, node.ID, node.Name)
+		}
+		start := time.Now()
+		resp, err := s.send(ctx, node, msg)
+		s.queue.release(node.ID)
+		attempt := types.ForwardAttempt{NodeID: node.ID, NodeName: node.Name, DurationMs: time.Since(start).Milliseconds()}
+		if err != nil {
+			attempt.Error = err.Error()
+			attempts = append(attempts, attempt)
+			lastErr = err
+			if !isTransient(err) || ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if resp.GatewayError {
+			attempt.GatewayError = true
+			attempts = append(attempts, attempt)
+			echo = resp
+			continue
+		}
+		attempts = append(attempts, attempt)
+		resp.Attempts = attempts
+		return resp, attempts, nil
+	}
+	if echo != nil {
+		echo.Attempts = attempts
+		return echo, attempts, nil
+	}
+	return nil, attempts, cancelledErr(ctx, lastErr)
+}
+
+// deliver routes and forwards a message on behalf of the coordinator
+// itself: to its target node if it has one, otherwise with failover.
+func (s *Server) deliver(ctx context.Context, msg *types.Message) (*types.MessageResponse, error) {
+	if msg.TargetNode != "" {
+		node, err := s.router.Route(msg)
+		if err != nil {
+			return nil, err
+		}
+		return s.forward(ctx, node, msg)
+	}
+	nodes, err := s.router.RouteWithFailover(msg)
+	if err != nil {
+		return nil, err
+	}
+	resp, _, err := s.forwardWithFailover(ctx, nodes, msg)
+	return resp, err
+}
+
+// handleListRules handles GET /api/v1/rules. The ETag header carries the
+// rule set's revision, for use in If-Match on later changes.
 func (s *Server) handleListRules(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, s.router.ListRules())
+	rules, rev := s.router.RuleSet()
+	setRevision(w, rev)
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// handleGetRule handles GET /api/v1/rules/{id}.
+func (s *Server) handleGetRule(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	rules, rev := s.router.RuleSet()
+	for _, rule := range rules {
+		if rule.ID == id {
+			setRevision(w, rev)
+			writeJSON(w, http.StatusOK, rule)
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"error": "rule not found"})
+}
+
+// setRevision reports the rule set's revision as an ETag.
+func setRevision(w http.ResponseWriter, rev uint64) {
+	w.Header().Set("ETag", fmt.Sprintf("%q", strconv.FormatUint(rev, 10)))
+}
+
+// ifMatchRevision parses the If-Match header of a rule change. It returns
+// 0, meaning unconditional, if the header is absent or "*".
+func ifMatchRevision(r *http.Request) (uint64, error) {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" || v == "*" {
+		return 0, nil
+	}
+	rev, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(v, "W/"), `"`), 10, 64)
+	if err != nil || rev == 0 {
+		return 0, fmt.Errorf("invalid If-Match header %q: expected a rule set revision", v)
+	}
+	return rev, nil
+}
+
+// writeRuleChangeError maps an error from a conditional rule change to a
+// response.
+func writeRuleChangeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errStaleRevision):
+		writeJSON(w, http.StatusPreconditionFailed, map[string]string{"error": err.Error() + "; fetch the rules and retry"})
+	case errors.Is(err, errRuleNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, errInvalidOrder), errors.Is(err, errUnknownGroup):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
 }
 
 // handleAddRule handles POST /api/v1/rules.
 func (s *Server) handleAddRule(w http.ResponseWriter, r *http.Request) {
+	if s.rulesManagedByFile(w) {
+		return
+	}
 	var rule types.RoutingRule
 	if err := decodeJSON(w, r, &rule); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
 		return
 	}
 
-	if err := validateRule(&rule); err != nil {
+	if err := validateRule(&rule, s.router.StrategyNames()); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
+	if rule.TargetGroup != "" && s.groups.Get(rule.TargetGroup) == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown target group %q", rule.TargetGroup)})
+		return
+	}
 
 	if err := s.router.AddRule(&rule); err != nil {
+		if errors.Is(err, errUnknownGroup) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to add rule"})
 		return
 	}
@@ -139,8 +419,123 @@ func (s *Server) handleAddRule(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusCreated, rule)
 }
 
+// handleReplaceRules handles PUT /api/v1/rules — replace the whole rule
+// set at once. Nothing changes unless every rule is valid.
+func (s *Server) handleReplaceRules(w http.ResponseWriter, r *http.Request) {
+	if s.rulesManagedByFile(w) {
+		return
+	}
+	var rules []*types.RoutingRule
+	if err := decodeJSON(w, r, &rules); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	for i, rule := range rules {
+		if rule == nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("rule %d is empty", i+1)})
+			return
+		}
+	}
+	if err := validateRuleSet(rules, s.router.StrategyNames(), s.groups); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	ifRev, err := ifMatchRevision(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	rev, err := s.router.ReplaceRules(rules, ifRev)
+	if err != nil {
+		writeRuleChangeError(w, err)
+		return
+	}
+
+	log.Printf("routing rules replaced: %d rules (revision %d)", len(rules), rev)
+	setRevision(w, rev)
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// handleUpdateRule handles PUT /api/v1/rules/{id} — edit a rule in place,
+// keeping its ID and position.
+func (s *Server) handleUpdateRule(w http.ResponseWriter, r *http.Request) {
+	if s.rulesManagedByFile(w) {
+		return
+	}
+	id := r.PathValue("id")
+	var rule types.RoutingRule
+	if err := decodeJSON(w, r, &rule); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if rule.ID != "" && rule.ID != id {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "rule ID cannot be changed"})
+		return
+	}
+	rule.ID = id
+
+	if err := validateRule(&rule, s.router.StrategyNames()); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if rule.TargetGroup != "" && s.groups.Get(rule.TargetGroup) == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown target group %q", rule.TargetGroup)})
+		return
+	}
+	ifRev, err := ifMatchRevision(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	rev, err := s.router.UpdateRule(&rule, ifRev)
+	if err != nil {
+		writeRuleChangeError(w, err)
+		return
+	}
+
+	log.Printf("routing rule updated: %s (revision %d)", id, rev)
+	setRevision(w, rev)
+	writeJSON(w, http.StatusOK, rule)
+}
+
+// handleReorderRules handles POST /api/v1/rules/reorder — set the order
+// in which rules are evaluated. The body lists every rule ID once.
+func (s *Server) handleReorderRules(w http.ResponseWriter, r *http.Request) {
+	if s.rulesManagedByFile(w) {
+		return
+	}
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	ifRev, err := ifMatchRevision(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	rev, err := s.router.ReorderRules(req.IDs, ifRev)
+	if err != nil {
+		writeRuleChangeError(w, err)
+		return
+	}
+
+	log.Printf("routing rules reordered (revision %d)", rev)
+	rules, _ := s.router.RuleSet()
+	setRevision(w, rev)
+	writeJSON(w, http.StatusOK, rules)
+}
+
 // handleDeleteRule handles DELETE /api/v1/rules/{id}.
 func (s *Server) handleDeleteRule(w http.ResponseWriter, r *http.Request) {
+	if s.rulesManagedByFile(w) {
+		return
+	}
 	id := r.PathValue("id")
 	found, err := s.router.RemoveRule(id)
 	if err != nil {
@@ -155,16 +550,12 @@ func (s *Server) handleDeleteRule(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// validStrategies lists the accepted routing strategy values.
-var validStrategies = map[string]bool{
-	"":           true,
-	"least-busy": true,
-}
-
 // validateRule checks a routing rule for invalid or contradictory fields.
-func validateRule(rule *types.RoutingRule) error {
+// strategies lists the accepted strategy names.
+func validateRule(rule *types.RoutingRule, strategies []string) error {
 	isWild := rule.Match.Wildcard != nil && *rule.Match.Wildcard
-	hasCriteria := rule.Match.RequiresGPU != nil || rule.Match.RequiresOS != "" || rule.Match.RequiresSkill != ""
+	hasCriteria := rule.Match.RequiresGPU != nil || rule.Match.RequiresOS != "" || rule.Match.RequiresSkill != "" ||
+		rule.Match.LabelSelector != "" || rule.Expr != "" || hasMessagePredicates(&rule.Match)
 
 	// Reject empty criteria (no match fields at all).
 	if !isWild && !hasCriteria {
@@ -181,10 +572,68 @@ func validateRule(rule *types.RoutingRule) error {
 		return fmt.Errorf("wildcard rule cannot specify a target node")
 	}
 
+	if rule.Target != "" && rule.TargetGroup != "" {
+		return fmt.Errorf("rule cannot specify both a target node and a target group")
+	}
+
+	if rule.Match.LabelSelector != "" {
+		if _, err := parseLabelSelector(rule.Match.LabelSelector); err != nil {
+			return fmt.Errorf("invalid label_selector: %w", err)
+		}
+	}
+
+	switch rule.Affinity {
+	case "", affinitySource, affinitySession:
+	default:
+		return fmt.Errorf("invalid affinity %q; valid values: %s, %s", rule.Affinity, affinitySource, affinitySession)
+	}
+	if rule.Affinity != "" && rule.Target != "" {
+		return fmt.Errorf("affinity cannot be combined with a target node")
+	}
+
+	if err := validateMessagePredicates(&rule.Match); err != nil {
+		return err
+	}
+
+	if rule.MaxNodes < 0 {
+		return fmt.Errorf("max_nodes must not be negative")
+	}
+
+	if rule.Expr != "" {
+		if _, err := parseExpr(rule.Expr); err != nil {
+			return fmt.Errorf("invalid expr: %w", err)
+		}
+	}
+
 	// Validate strategy value.
-	if !validStrategies[rule.Strategy] {
-		return fmt.Errorf("invalid strategy %q; valid values: least-busy", rule.Strategy)
+	if rule.Strategy != "" && !containsString(strategies, rule.Strategy) {
+		return fmt.Errorf("invalid strategy %q; valid values: %s", rule.Strategy, strings.Join(strategies, ", "))
 	}
 
 	return nil
 }
+
+// validateMessagePredicates checks the message predicates of a rule.
+func validateMessagePredicates(mc *types.MatchCriteria) error {
+	for _, kw := range mc.Keywords {
+		if strings.TrimSpace(kw) == "" {
+			return fmt.Errorf("keywords must not be empty")
+		}
+	}
+	if mc.ContentRegex != "" {
+		if _, err := regexp.Compile(mc.ContentRegex); err != nil {
+			return fmt.Errorf("invalid content_regex: %w", err)
+		}
+	}
+	if mc.Command != "" && (!strings.HasPrefix(mc.Command, "/") || len(mc.Command) < 2 ||
+		strings.ContainsFunc(mc.Command, unicode.IsSpace)) {
+		return fmt.Errorf("invalid command %q: must be a single word starting with \"/\"", mc.Command)
+	}
+	if mc.MinLength < 0 || mc.MaxLength < 0 {
+		return fmt.Errorf("min_length and max_length must not be negative")
+	}
+	if mc.MaxLength > 0 && mc.MinLength > mc.MaxLength {
+		return fmt.Errorf("min_length %d is greater than max_length %d", mc.MinLength, mc.MaxLength)
+	}
+	return nil
+}
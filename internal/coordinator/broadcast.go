@@ -0,0 +1,197 @@
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+// Broadcast aggregation modes.
+const (
+	broadcastAll          = "all"
+	broadcastFirstSuccess = "first-success"
+	broadcastQuorum       = "quorum"
+)
+
+const (
+	defaultBroadcastTimeout = 60 * time.Second
+	// maxBroadcastTimeout matches the forwarder's HTTP client timeout.
+	maxBroadcastTimeout = 180 * time.Second
+)
+
+// MatchingNodes returns the nodes eligible for auto-routing that satisfy
+// the rule's node criteria, expression and target group, sorted by ID.
+// Message predicates and the rule's target are ignored.
+func (rt *Router) MatchingNodes(rule *types.RoutingRule) []*types.Node {
+	var eligible []*types.Node
+	for _, n := range rt.registry.List() {
+		if rt.unavailableReason(n) == "" {
+			eligible = append(eligible, n)
+		}
+	}
+	nodes := eligible
+	if !isWildcard(rule) {
+		nodes = matchNodes(rule, eligible, compileRules([]*types.RoutingRule{rule}), nil)
+	}
+	if rule.TargetGroup != "" {
+		g := rt.groups.matcher(rule.TargetGroup)
+		if g == nil {
+			return nil
+		}
+		nodes = g.members(nodes)
+	}
+	return sortedByID(nodes)
+}
+
+// validateBroadcast checks a broadcast request and fills in defaults,
+// except the quorum, which depends on the number of nodes.
+func validateBroadcast(req *types.BroadcastRequest) error {
+	if req.Content == "" {
+		return fmt.Errorf("content is required")
+	}
+	switch req.Mode {
+	case "":
+		req.Mode = broadcastAll
+	case broadcastAll, broadcastFirstSuccess, broadcastQuorum:
+	default:
+		return fmt.Errorf("invalid mode %q; valid values: %s, %s, %s", req.Mode, broadcastAll, broadcastFirstSuccess, broadcastQuorum)
+	}
+	if req.Quorum < 0 || (req.Quorum > 0 && req.Mode != broadcastQuorum) {
+		return fmt.Errorf("quorum must be positive and requires mode %q", broadcastQuorum)
+	}
+	if req.TimeoutSeconds < 0 || req.TimeoutSeconds > int(maxBroadcastTimeout/time.Second) {
+		return fmt.Errorf("timeout_seconds must be between 1 and %d", int(maxBroadcastTimeout.Seconds()))
+	}
+	if hasMessagePredicates(&req.Match) {
+		return fmt.Errorf("message predicates are not supported in broadcasts")
+	}
+	if req.Match.LabelSelector != "" {
+		if _, err := parseLabelSelector(req.Match.LabelSelector); err != nil {
+			return fmt.Errorf("invalid label_selector: %w", err)
+		}
+	}
+	if req.Expr != "" {
+		if _, err := parseExpr(req.Expr); err != nil {
+			return fmt.Errorf("invalid expr: %w", err)
+		}
+	}
+	return nil
+}
+
+// handleBroadcast handles POST /api/v1/broadcast — send one message to
+// many nodes concurrently and collect their answers. The response is 200
+// if the mode was satisfied and 502 otherwise; both carry every result.
+func (s *Server) handleBroadcast(w http.ResponseWriter, r *http.Request) {
+	var req types.BroadcastRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if err := validateBroadcast(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if req.Group != "" && s.groups.Get(req.Group) == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown group %q", req.Group)})
+		return
+	}
+
+	nodes := s.router.MatchingNodes(&types.RoutingRule{Match: req.Match, Expr: req.Expr, TargetGroup: req.Group})
+	if len(nodes) == 0 {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "no online nodes match"})
+		return
+	}
+	if req.Mode == broadcastQuorum {
+		if req.Quorum == 0 {
+			req.Quorum = len(nodes)/2 + 1
+		}
+		if req.Quorum > len(nodes) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("quorum %d exceeds the %d matching nodes", req.Quorum, len(nodes))})
+			return
+		}
+	}
+
+	msgID, err := generateID()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to generate message ID"})
+		return
+	}
+	msg := &types.Message{ID: msgID, Content: req.Content, Source: req.Source, CreatedAt: time.Now()}
+
+	timeout := defaultBroadcastTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+	log.Printf("broadcasting message %s to %d nodes (mode %s)", msgID, len(nodes), req.Mode)
+	resp := s.broadcast(r.Context(), nodes, msg, req.Mode, req.Quorum, timeout)
+
+	status := http.StatusOK
+	if !resp.OK {
+		status = http.StatusBadGateway
+	}
+	writeJSON(w, status, resp)
+}
+
+// broadcast forwards msg to every node concurrently under a shared
+// deadline. It returns once every node has answered, or as soon as the
+// mode is satisfied, cancelling the forwards still running.
+func (s *Server) broadcast(ctx context.Context, nodes []*types.Node, msg *types.Message, mode string, quorum int, timeout time.Duration) *types.BroadcastResponse {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	need := len(nodes)
+	switch mode {
+	case broadcastFirstSuccess:
+		need = 1
+	case broadcastQuorum:
+		need = quorum
+	}
+
+	type indexed struct {
+		i   int
+		res types.BroadcastResult
+	}
+	done := make(chan indexed, len(nodes))
+	for i, n := range nodes {
+		go func() {
+			start := time.Now()
+			res := types.BroadcastResult{NodeID: n.ID, NodeName: n.Name}
+			fwdResp, err := s.forward(ctx, n, msg)
+			res.DurationMs = time.Since(start).Milliseconds()
+			if err != nil {
+				res.Error = err.Error()
+			} else {
+				res.Response = fwdResp.Response
+				res.GatewayError = fwdResp.GatewayError
+			}
+			done <- indexed{i, res}
+		}()
+	}
+
+	resp := &types.BroadcastResponse{MessageID: msg.ID, Mode: mode, Quorum: quorum, Results: make([]types.BroadcastResult, len(nodes))}
+	finished := make([]bool, len(nodes))
+	for received := 0; received < len(nodes); received++ {
+		d := <-done
+		resp.Results[d.i] = d.res
+		finished[d.i] = true
+		if d.res.Error == "" && !d.res.GatewayError {
+			resp.Succeeded++
+		} else {
+			resp.Failed++
+		}
+		if mode != broadcastAll && resp.Succeeded >= need {
+			break
+		}
+	}
+	for i, n := range nodes {
+		if !finished[i] {
+			resp.Results[i] = types.BroadcastResult{NodeID: n.ID, NodeName: n.Name, Cancelled: true}
+		}
+	}
+	resp.OK = resp.Succeeded >= need
+	return resp
+}
@@ -0,0 +1,82 @@
+package coordinator
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+func TestHandleBroadcast(t *testing.T) {
+	answer := func(delay time.Duration, status int, text string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(types.MessageResponse{Response: text})
+		}))
+	}
+	fast := answer(0, http.StatusOK, "fast")
+	defer fast.Close()
+	slow := answer(500*time.Millisecond, http.StatusOK, "slow")
+	defer slow.Close()
+	broken := answer(0, http.StatusInternalServerError, "")
+	defer broken.Close()
+
+	srv := newTestServer()
+	srv.forwarder = NewForwarder()
+	srv.router = NewRouter(srv.registry)
+	for id, ts := range map[string]*httptest.Server{"a-fast": fast, "b-slow": slow, "c-broken": broken} {
+		srv.registry.Add(&types.Node{ID: id, Name: id, Endpoint: strings.TrimPrefix(ts.URL, "http://"),
+			Status: types.NodeStatusOnline, Capabilities: types.Capabilities{GPU: id != "c-broken"}})
+	}
+
+	post := func(req types.BroadcastRequest) (int, types.BroadcastResponse) {
+		t.Helper()
+		body, _ := json.Marshal(req)
+		rr := httptest.NewRecorder()
+		srv.handleBroadcast(rr, httptest.NewRequest(http.MethodPost, "/api/v1/broadcast", bytes.NewReader(body)))
+		var resp types.BroadcastResponse
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		return rr.Code, resp
+	}
+
+	start := time.Now()
+	code, resp := post(types.BroadcastRequest{Content: "hi", Mode: "first-success"})
+	if code != http.StatusOK || !resp.OK || time.Since(start) > 400*time.Millisecond {
+		t.Fatalf("first-success: code %d, resp %+v after %v", code, resp, time.Since(start))
+	}
+	if r := resp.Results[0]; r.NodeID != "a-fast" || r.Response != "fast" {
+		t.Errorf("unexpected first result %+v", r)
+	}
+	if !resp.Results[1].Cancelled {
+		t.Errorf("expected the slow node to be cancelled, got %+v", resp.Results[1])
+	}
+
+	// All nodes: the broken one is a partial failure.
+	code, resp = post(types.BroadcastRequest{Content: "hi", TimeoutSeconds: 5})
+	if code != http.StatusBadGateway || resp.OK || resp.Succeeded != 2 || resp.Failed != 1 {
+		t.Fatalf("all: code %d, resp %+v", code, resp)
+	}
+	if resp.Results[2].Error == "" {
+		t.Errorf("expected an error for the broken node, got %+v", resp.Results[2])
+	}
+
+	// Only GPU nodes, default quorum of 2.
+	code, resp = post(types.BroadcastRequest{Content: "hi", Expr: "gpu", Mode: "quorum"})
+	if code != http.StatusOK || resp.Quorum != 2 || len(resp.Results) != 2 {
+		t.Fatalf("quorum: code %d, resp %+v", code, resp)
+	}
+
+	if code, _ := post(types.BroadcastRequest{Content: "hi", Mode: "quorum", Quorum: 4}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unreachable quorum, got %d", code)
+	}
+}
@@ -0,0 +1,202 @@
+package coordinator
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+// Circuit breaker states.
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+// BreakerConfig sets when a node's circuit opens and how it recovers.
+// A call counts as failed if it returned an error other than a 4xx, such
+// as a 5xx status or an undecodable reply, or took longer than SlowCall.
+// The circuit opens once at least MinRequests of the last Window calls
+// were made and the failed fraction reaches FailureRate. After OpenFor it
+// lets one probe through (half-open); the probe's outcome closes or
+// reopens it.
+type BreakerConfig struct {
+	Window      int
+	MinRequests int
+	FailureRate float64
+	SlowCall    time.Duration
+	OpenFor     time.Duration
+}
+
+// defaultBreakerConfig suits forwards that usually take seconds but can
+// legitimately run for a couple of minutes.
+var defaultBreakerConfig = BreakerConfig{
+	Window:      20,
+	MinRequests: 5,
+	FailureRate: 0.5,
+	SlowCall:    120 * time.Second,
+	OpenFor:     30 * time.Second,
+}
+
+// errCircuitOpen is returned for forwards rejected by an open circuit.
+var errCircuitOpen = errors.New("circuit open")
+
+// breaker is the circuit breaker for a single node.
+type breaker struct {
+	state    string
+	outcomes []bool // ring of recent results, true = failed
+	next     int
+	openedAt time.Time
+	probing  bool // a half-open probe is in flight
+}
+
+// breakers holds the circuit breakers for all nodes.
+type breakers struct {
+	cfg    BreakerConfig
+	events *EventBus
+
+	mu    sync.Mutex
+	nodes map[string]*breaker
+}
+
+func newBreakers(cfg BreakerConfig) *breakers {
+	return &breakers{cfg: cfg, nodes: make(map[string]*breaker)}
+}
+
+// getLocked returns the breaker for a node, creating a closed one.
+func (bs *breakers) getLocked(nodeID string) *breaker {
+	b, ok := bs.nodes[nodeID]
+	if !ok {
+		b = &breaker{state: circuitClosed}
+		bs.nodes[nodeID] = b
+	}
+	return b
+}
+
+// allow reports whether a forward to the node may proceed. An open
+// circuit whose cool-down has passed turns half-open and admits one probe.
+func (bs *breakers) allow(nodeID string) bool {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b := bs.getLocked(nodeID)
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < bs.cfg.OpenFor {
+			return false
+		}
+		b.state = circuitHalfOpen
+		b.probing = true
+		return true
+	case circuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// record feeds the outcome of a forward into the node's breaker.
+func (bs *breakers) record(nodeID string, failed bool) {
+	bs.mu.Lock()
+	b := bs.getLocked(nodeID)
+	var publish types.EventType
+	switch b.state {
+	case circuitHalfOpen:
+		b.probing = false
+		if failed {
+			b.state = circuitOpen
+			b.openedAt = time.Now()
+			publish = types.EventNodeCircuitOpen
+		} else {
+			b.state = circuitClosed
+			b.outcomes, b.next = nil, 0
+			publish = types.EventNodeCircuitClosed
+		}
+	case circuitClosed:
+		if len(b.outcomes) < bs.cfg.Window {
+			b.outcomes = append(b.outcomes, failed)
+		} else {
+			b.outcomes[b.next] = failed
+			b.next = (b.next + 1) % bs.cfg.Window
+		}
+		if n, failures := len(b.outcomes), countTrue(b.outcomes); n >= bs.cfg.MinRequests &&
+			float64(failures)/float64(n) >= bs.cfg.FailureRate {
+			b.state = circuitOpen
+			b.openedAt = time.Now()
+			publish = types.EventNodeCircuitOpen
+		}
+	}
+	bs.mu.Unlock()
+
+	switch publish {
+	case types.EventNodeCircuitOpen:
+		log.Printf("circuit opened for node %s", nodeID)
+		bs.events.Publish(types.Event{Type: publish, NodeID: nodeID})
+	case types.EventNodeCircuitClosed:
+		log.Printf("circuit closed for node %s", nodeID)
+		bs.events.Publish(types.Event{Type: publish, NodeID: nodeID})
+	}
+}
+
+// release ends a half-open probe without an outcome, e.g. when the
+// caller cancelled the forward.
+func (bs *breakers) release(nodeID string) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if b, ok := bs.nodes[nodeID]; ok {
+		b.probing = false
+	}
+}
+
+// isOpen reports whether the node's circuit is open and still cooling
+// down. Half-open circuits are not open: they accept a probe.
+func (bs *breakers) isOpen(nodeID string) bool {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b, ok := bs.nodes[nodeID]
+	return ok && b.state == circuitOpen && time.Since(b.openedAt) < bs.cfg.OpenFor
+}
+
+// state describes the node's breaker.
+func (bs *breakers) state(nodeID string) *types.CircuitState {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b, ok := bs.nodes[nodeID]
+	if !ok {
+		return &types.CircuitState{State: circuitClosed}
+	}
+	cs := &types.CircuitState{State: b.state, Requests: len(b.outcomes), Failures: countTrue(b.outcomes)}
+	if b.state == circuitOpen && time.Since(b.openedAt) >= bs.cfg.OpenFor {
+		cs.State = circuitHalfOpen
+	}
+	if cs.Requests > 0 {
+		cs.FailureRate = float64(cs.Failures) / float64(cs.Requests)
+	}
+	if b.state != circuitClosed {
+		opened := b.openedAt
+		cs.OpenedAt = &opened
+	}
+	return cs
+}
+
+// forget drops a node's breaker, e.g. when the node is deregistered.
+func (bs *breakers) forget(nodeID string) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	delete(bs.nodes, nodeID)
+}
+
+func countTrue(bs []bool) int {
+	n := 0
+	for _, b := range bs {
+		if b {
+			n++
+		}
+	}
+	return n
+}
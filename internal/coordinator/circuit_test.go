@@ -0,0 +1,115 @@
+package coordinator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+func TestBreakers_OpenHalfOpenClose(t *testing.T) {
+	bs := newBreakers(BreakerConfig{Window: 4, MinRequests: 4, FailureRate: 0.5, OpenFor: 20 * time.Millisecond})
+	bs.events = NewEventBus(0)
+	_, events, cancel := bs.events.Subscribe(nil, 0)
+	defer cancel()
+
+	for _, failed := range []bool{false, true, false} {
+		bs.record("n1", failed)
+	}
+	if bs.isOpen("n1") {
+		t.Fatal("circuit opened before MinRequests calls")
+	}
+	bs.record("n1", true) // 2 of 4 failed
+	if !bs.isOpen("n1") || bs.allow("n1") {
+		t.Fatal("expected open circuit to reject forwards")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if bs.isOpen("n1") {
+		t.Fatal("circuit should accept a probe after OpenFor")
+	}
+	if !bs.allow("n1") {
+		t.Fatal("expected half-open circuit to admit a probe")
+	}
+	if bs.allow("n1") {
+		t.Fatal("half-open circuit admitted a second concurrent probe")
+	}
+	bs.record("n1", true)
+	if st := bs.state("n1"); st.State != circuitOpen {
+		t.Fatalf("failed probe should reopen the circuit, got %s", st.State)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	bs.allow("n1")
+	bs.record("n1", false)
+	if st := bs.state("n1"); st.State != circuitClosed || st.Requests != 0 {
+		t.Fatalf("successful probe should close and reset the circuit, got %+v", st)
+	}
+
+	// Opening, reopening after the failed probe, and closing are all published.
+	want := []types.EventType{types.EventNodeCircuitOpen, types.EventNodeCircuitOpen, types.EventNodeCircuitClosed}
+	for i, typ := range want {
+		select {
+		case ev := <-events:
+			if ev.Type != typ || ev.NodeID != "n1" {
+				t.Fatalf("event %d: expected %s for n1, got %+v", i, typ, ev)
+			}
+		default:
+			t.Fatalf("event %d: expected %s, got none", i, typ)
+		}
+	}
+}
+
+func TestRoute_SkipsOpenCircuit(t *testing.T) {
+	reg := NewRegistry(nil)
+	reg.Add(&types.Node{ID: "a", Status: types.NodeStatusOnline})
+	reg.Add(&types.Node{ID: "b", Status: types.NodeStatusOnline})
+	fwd := NewForwarder()
+	rt := NewRouter(reg)
+	rt.circuits = fwd
+	for i := 0; i < defaultBreakerConfig.MinRequests; i++ {
+		fwd.breakers.record("a", true)
+	}
+	n, err := rt.Route(&types.Message{Content: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.ID != "b" {
+		t.Fatalf("expected open-circuit node a to be skipped, got %s", n.ID)
+	}
+	if tr := rt.Explain(&types.Message{Content: "hi"}); len(tr.Unavailable) != 1 || tr.Unavailable[0].Reason != "circuit open" {
+		t.Errorf("unavailable = %+v", tr.Unavailable)
+	}
+}
+
+func TestForwardMessage_RecordsNodeErrors(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		case 2:
+			w.Write([]byte("not json"))
+		default:
+			http.Error(w, "message already in progress", http.StatusConflict)
+		}
+	}))
+	defer ts.Close()
+
+	fwd := NewForwarder()
+	node := &types.Node{ID: "n1", Endpoint: strings.TrimPrefix(ts.URL, "http://")}
+	for _, what := range []string{"a 500", "an undecodable reply", "a 409"} {
+		if _, err := fwd.ForwardMessage(context.Background(), node, &types.Message{Content: "hi"}, ""); err == nil {
+			t.Fatalf("expected %s to fail the forward", what)
+		}
+	}
+	// The 409 is about the message, not the node.
+	if st := fwd.CircuitState("n1"); st.Requests != 3 || st.Failures != 2 {
+		t.Fatalf("expected two of three forwards recorded as failures, got %+v", st)
+	}
+}
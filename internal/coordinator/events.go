@@ -0,0 +1,262 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+const (
+	defaultEventHistory = 1024
+	subscriberBuffer    = 64
+	sseKeepAlive        = 15 * time.Second
+)
+
+// EventBus fans out mesh events to subscribers and keeps a bounded history
+// so that consumers can resume from the last event ID they saw.
+// A nil *EventBus is valid and drops all events.
+type EventBus struct {
+	mu      sync.Mutex
+	nextID  uint64
+	history []types.Event // ring buffer of up to size events
+	start   int           // index of the oldest event once history is full
+	size    int
+	subs    map[*subscription]struct{}
+	closed  bool
+}
+
+type subscription struct {
+	ch    chan types.Event
+	match func(types.EventType) bool // nil matches everything
+}
+
+// NewEventBus creates an event bus that remembers the last historySize events.
+func NewEventBus(historySize int) *EventBus {
+	if historySize <= 0 {
+		historySize = defaultEventHistory
+	}
+	return &EventBus{
+		size: historySize,
+		subs: make(map[*subscription]struct{}),
+	}
+}
+
+// Publish assigns the event an ID and timestamp and delivers it to all
+// matching subscribers. Subscribers that fall behind are dropped; they can
+// reconnect and resume from history.
+func (b *EventBus) Publish(ev types.Event) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.nextID++
+	ev.ID = b.nextID
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	if len(b.history) < b.size {
+		b.history = append(b.history, ev)
+	} else {
+		b.history[b.start] = ev
+		b.start = (b.start + 1) % b.size
+	}
+	for sub := range b.subs {
+		if sub.match != nil && !sub.match(ev.Type) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Subscribe registers a subscriber for events whose type match accepts,
+// or all events if match is nil. Events with an ID greater than since that
+// are still in history are returned as a backlog. The returned channel is
+// closed when the subscriber is dropped or the bus is closed; cancel must
+// be called to release the subscription.
+func (b *EventBus) Subscribe(match func(types.EventType) bool, since uint64) ([]types.Event, <-chan types.Event, func()) {
+	sub := &subscription{ch: make(chan types.Event, subscriberBuffer), match: match}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var backlog []types.Event
+	if since > 0 {
+		for i := range b.history {
+			ev := b.history[(b.start+i)%len(b.history)]
+			if ev.ID > since && (match == nil || match(ev.Type)) {
+				backlog = append(backlog, ev)
+			}
+		}
+	}
+	if b.closed {
+		close(sub.ch)
+		return backlog, sub.ch, func() {}
+	}
+	b.subs[sub] = struct{}{}
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[sub]; ok {
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+	return backlog, sub.ch, cancel
+}
+
+// Close disconnects all subscribers. Further events are dropped.
+func (b *EventBus) Close() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// eventFilter matches event types against a list of patterns. A pattern is
+// either an exact type ("node.offline") or a prefix ending in "*"
+// ("node.*"). An empty filter matches everything.
+type eventFilter []string
+
+// parseEventFilter splits comma-separated type patterns.
+func parseEventFilter(values []string) eventFilter {
+	var f eventFilter
+	for _, v := range values {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				f = append(f, p)
+			}
+		}
+	}
+	return f
+}
+
+func (f eventFilter) match(t types.EventType) bool {
+	if len(f) == 0 {
+		return true
+	}
+	for _, p := range f {
+		if p == "*" || p == string(t) {
+			return true
+		}
+		if strings.HasSuffix(p, "*") && strings.HasPrefix(string(t), strings.TrimSuffix(p, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// publicEvents are the event types streamed to clients without a token;
+// the others carry job, task, plan and schedule details that need auth.
+var publicEvents = eventFilter{"node.*", "rule.*"}
+
+// handleEvents handles GET /api/v1/events — a Server-Sent Events stream.
+// Query params: types=node.*,rule.added filters by type; since=<id> (or the
+// Last-Event-ID header) resumes after the given event ID. Without a token,
+// only publicEvents are streamed.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	filter := parseEventFilter(r.URL.Query()["types"])
+	match := filter.match
+	if _, ok := bearerToken(r); !ok && s.cfg.Token != "" {
+		match = func(t types.EventType) bool { return publicEvents.match(t) && filter.match(t) }
+	} else if msg := s.authError(r); msg != "" {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": msg})
+		return
+	}
+
+	var since uint64
+	sinceStr := r.Header.Get("Last-Event-ID")
+	if sinceStr == "" {
+		sinceStr = r.URL.Query().Get("since")
+	}
+	if sinceStr != "" {
+		v, err := strconv.ParseUint(sinceStr, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid event ID"})
+			return
+		}
+		since = v
+	}
+
+	// The stream outlives the server's WriteTimeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming not supported"})
+		return
+	}
+
+	backlog, ch, cancel := s.events.Subscribe(match, since)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	for _, ev := range backlog {
+		if err := writeSSE(w, ev); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := writeSSE(w, ev); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeSSE writes a single event in text/event-stream framing.
+func writeSSE(w http.ResponseWriter, ev types.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+	return err
+}
@@ -0,0 +1,157 @@
+package coordinator
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/config"
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+func TestEventFilter(t *testing.T) {
+	tests := []struct {
+		filter []string
+		typ    types.EventType
+		want   bool
+	}{
+		{nil, types.EventNodeOffline, true},
+		{[]string{"node.*"}, types.EventNodeOffline, true},
+		{[]string{"node.*"}, types.EventRuleAdded, false},
+		{[]string{"rule.added,node.offline"}, types.EventNodeOffline, true},
+		{[]string{"rule.added"}, types.EventRuleDeleted, false},
+		{[]string{"*"}, types.EventMessageFailed, true},
+	}
+	for _, tt := range tests {
+		if got := parseEventFilter(tt.filter).match(tt.typ); got != tt.want {
+			t.Errorf("filter %v match %s = %v, want %v", tt.filter, tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestEventBus_ResumeFromHistory(t *testing.T) {
+	bus := NewEventBus(3)
+	for i := 0; i < 5; i++ {
+		bus.Publish(types.Event{Type: types.EventNodeOnline})
+	}
+
+	// Only the last three events are retained.
+	backlog, _, cancel := bus.Subscribe(nil, 1)
+	defer cancel()
+	if len(backlog) != 3 || backlog[0].ID != 3 || backlog[2].ID != 5 {
+		t.Fatalf("unexpected backlog: %+v", backlog)
+	}
+
+	backlog, _, cancel2 := bus.Subscribe(nil, 4)
+	defer cancel2()
+	if len(backlog) != 1 || backlog[0].ID != 5 {
+		t.Fatalf("expected only event 5 after resume, got %+v", backlog)
+	}
+}
+
+func TestEventBus_DropsSlowSubscriber(t *testing.T) {
+	bus := NewEventBus(0)
+	_, ch, cancel := bus.Subscribe(nil, 0)
+	defer cancel()
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		bus.Publish(types.Event{Type: types.EventNodeStatus})
+	}
+	n := 0
+	for range ch {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Fatalf("expected %d buffered events before drop, got %d", subscriberBuffer, n)
+	}
+}
+
+func TestHandleEvents_StreamsFilteredEvents(t *testing.T) {
+	srv := &Server{cfg: &config.CoordinatorConfig{}, events: NewEventBus(0)}
+	ts := httptest.NewServer(http.HandlerFunc(srv.handleEvents))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"?types=node.offline", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET events: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+
+	srv.events.Publish(types.Event{Type: types.EventRuleAdded, RuleID: "r1"})
+	srv.events.Publish(types.Event{Type: types.EventNodeOffline, NodeID: "node-1"})
+
+	scanner := bufio.NewScanner(resp.Body)
+	var eventLine, dataLine string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event: ") {
+			eventLine = line
+		}
+		if strings.HasPrefix(line, "data: ") {
+			dataLine = strings.TrimPrefix(line, "data: ")
+			break
+		}
+	}
+	if eventLine != "event: node.offline" {
+		t.Fatalf("expected node.offline event, got %q", eventLine)
+	}
+	var ev types.Event
+	if err := json.Unmarshal([]byte(dataLine), &ev); err != nil {
+		t.Fatalf("decoding event: %v", err)
+	}
+	if ev.NodeID != "node-1" || ev.ID != 2 {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+}
+
+func TestHandleEvents_PublicWithoutToken(t *testing.T) {
+	srv := &Server{cfg: &config.CoordinatorConfig{Token: "secret"}, registry: NewRegistry(nil), events: NewEventBus(0)}
+	ts := httptest.NewServer(http.HandlerFunc(srv.handleEvents))
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET events: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong token, got %d", resp.StatusCode)
+	}
+
+	// Without a token, job events are left out of the stream.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET events: %v", err)
+	}
+	defer resp.Body.Close()
+	srv.events.Publish(types.Event{Type: types.EventJobFailed, Data: map[string]any{"error": "boom"}})
+	srv.events.Publish(types.Event{Type: types.EventRuleAdded, RuleID: "r1"})
+
+	scanner := bufio.NewScanner(resp.Body)
+	var eventLine string
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "event: ") {
+			eventLine = line
+			break
+		}
+	}
+	if eventLine != "event: rule.added" {
+		t.Fatalf("expected the job event to be skipped, got %q", eventLine)
+	}
+}
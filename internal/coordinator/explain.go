@@ -0,0 +1,144 @@
+package coordinator
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+// routeTracer records a routing decision into a RouteTrace.
+// All methods are no-ops on a nil tracer, so Route pays nothing for them.
+type routeTracer struct {
+	trace types.RouteTrace
+}
+
+func (tr *routeTracer) current() *types.RuleTrace {
+	if len(tr.trace.Rules) == 0 {
+		return nil
+	}
+	return &tr.trace.Rules[len(tr.trace.Rules)-1]
+}
+
+// rule starts the trace of a rule.
+func (tr *routeTracer) rule(rule *types.RoutingRule) {
+	if tr == nil {
+		return
+	}
+	tr.trace.Rules = append(tr.trace.Rules, types.RuleTrace{RuleID: rule.ID})
+}
+
+// skip marks the current rule as not applying.
+func (tr *routeTracer) skip(reason string) {
+	if tr == nil {
+		return
+	}
+	if cur := tr.current(); cur != nil {
+		cur.Matched = false
+		cur.Reason = reason
+	}
+}
+
+// exclude records a node the current rule ruled out.
+func (tr *routeTracer) exclude(n *types.Node, reason string) {
+	if tr == nil {
+		return
+	}
+	if cur := tr.current(); cur != nil {
+		cur.Excluded = append(cur.Excluded, types.NodeExclusion{NodeID: n.ID, Name: n.Name, Reason: reason})
+	}
+}
+
+// candidates records the nodes the current rule chooses among.
+func (tr *routeTracer) candidates(nodes []*types.Node) {
+	if tr == nil {
+		return
+	}
+	if cur := tr.current(); cur != nil {
+		cur.Matched = true
+		cur.Candidates = make([]string, 0, len(nodes))
+		for _, n := range sortedByID(nodes) {
+			cur.Candidates = append(cur.Candidates, n.ID)
+		}
+	}
+}
+
+// unavailable records a node that is not eligible for auto-routing.
+func (tr *routeTracer) unavailable(n *types.Node, reason string) {
+	if tr == nil {
+		return
+	}
+	tr.trace.Unavailable = append(tr.trace.Unavailable, types.NodeExclusion{NodeID: n.ID, Name: n.Name, Reason: reason})
+}
+
+// chose records the winning node. rule is nil for explicit targets.
+func (tr *routeTracer) chose(rule *types.RoutingRule, n *types.Node, how string) {
+	if tr == nil {
+		return
+	}
+	tr.trace.NodeID, tr.trace.NodeName, tr.trace.ChosenBy = n.ID, n.Name, how
+	if rule != nil {
+		tr.trace.RuleID = rule.ID
+	}
+}
+
+// fallback records that no rule applied and least-busy chose n.
+func (tr *routeTracer) fallback(n *types.Node) {
+	if tr == nil {
+		return
+	}
+	tr.trace.Fallback = true
+	tr.chose(nil, n, "fallback:least-busy")
+}
+
+// Explain routes msg like Route but returns a trace of the decision.
+// Nothing is forwarded, and round-robin counters and affinity
+// assignments are not advanced.
+func (rt *Router) Explain(msg *types.Message) *types.RouteTrace {
+	tr := &routeTracer{}
+	if _, err := rt.route(msg, tr); err != nil {
+		tr.trace.Error = err.Error()
+	}
+	if tr.trace.Rules == nil {
+		tr.trace.Rules = []types.RuleTrace{}
+	}
+	sortExclusions(tr.trace.Unavailable)
+	for i := range tr.trace.Rules {
+		sortExclusions(tr.trace.Rules[i].Excluded)
+	}
+	return &tr.trace
+}
+
+// sortExclusions orders exclusions by node ID; the registry lists nodes
+// in map order.
+func sortExclusions(ex []types.NodeExclusion) {
+	sort.Slice(ex, func(i, j int) bool { return ex[i].NodeID < ex[j].NodeID })
+}
+
+// handleRouteExplain handles POST /api/v1/explain/route — a dry run of
+// routing that reports why each rule did or didn't apply.
+func (s *Server) handleRouteExplain(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Content    string `json:"content"`
+		Source     string `json:"source"`
+		SessionID  string `json:"session_id"`
+		TargetNode string `json:"target_node"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if req.Content == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "content is required"})
+		return
+	}
+	msg := &types.Message{
+		Content:    req.Content,
+		Source:     req.Source,
+		SessionID:  req.SessionID,
+		TargetNode: req.TargetNode,
+		CreatedAt:  time.Now(),
+	}
+	writeJSON(w, http.StatusOK, s.router.Explain(msg))
+}
@@ -0,0 +1,438 @@
+package coordinator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+// Rule expressions are boolean conditions over node fields, e.g.
+//
+//	(skill:docker OR skill:kubernetes) AND NOT os:darwin AND memory_gb>=32
+//
+// Grammar:
+//
+//	expr       = and { ("OR" | "||") and }
+//	and        = unary { ("AND" | "&&") unary }
+//	unary      = ("NOT" | "!") unary | "(" expr ")" | comparison
+//	comparison = field [ op value ]
+//	op         = ":" | "=" | "==" | "!=" | "<" | "<=" | ">" | ">="
+//
+// Fields are os, arch, name, status (strings), gpu (bool), memory_gb
+// (number), skills/skill, tags/tag (lists) and labels.<key>/label.<key>.
+// On a list, ":" and "==" test membership and "!=" its absence. A bare
+// field tests gpu for true and a label for existence. Keywords are
+// case-insensitive; values may be quoted with double quotes.
+
+// ExprError reports a syntax or type error at a 1-based byte position.
+type ExprError struct {
+	Pos int
+	Msg string
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("at position %d: %s", e.Pos, e.Msg)
+}
+
+// exprNode is a compiled expression.
+type exprNode interface {
+	eval(n *types.Node) bool
+}
+
+type exprAnd struct{ left, right exprNode }
+type exprOr struct{ left, right exprNode }
+type exprNot struct{ inner exprNode }
+
+func (e exprAnd) eval(n *types.Node) bool { return e.left.eval(n) && e.right.eval(n) }
+func (e exprOr) eval(n *types.Node) bool  { return e.left.eval(n) || e.right.eval(n) }
+func (e exprNot) eval(n *types.Node) bool { return !e.inner.eval(n) }
+
+// fieldKind is the type of a node field.
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindBool
+	kindNumber
+	kindList
+	kindLabel
+)
+
+// exprFields maps field names to their kinds. Label fields ("labels.<key>")
+// are recognized by prefix in parseComparison.
+var exprFields = map[string]fieldKind{
+	"os":        kindString,
+	"arch":      kindString,
+	"name":      kindString,
+	"status":    kindString,
+	"gpu":       kindBool,
+	"memory_gb": kindNumber,
+	"skills":    kindList,
+	"skill":     kindList,
+	"tags":      kindList,
+	"tag":       kindList,
+}
+
+// exprCompare is one comparison against a node field.
+type exprCompare struct {
+	field string
+	kind  fieldKind
+	label string // label key for kindLabel
+	op    string // "" for a bare field
+	value string
+	num   float64
+}
+
+func (c exprCompare) eval(n *types.Node) bool {
+	switch c.kind {
+	case kindBool:
+		want := c.op == "" || c.value == "true"
+		got := n.Capabilities.GPU == want
+		if c.op == "!=" {
+			return !got
+		}
+		return got
+	case kindNumber:
+		return compareNumbers(float64(n.Capabilities.MemoryGB), c.op, c.num)
+	case kindList:
+		var has bool
+		if c.field == "tags" || c.field == "tag" {
+			has = containsString(n.Capabilities.Tags, c.value)
+		} else {
+			// Like requires_skill, tags count as skills too.
+			has = hasSkill(n, c.value)
+		}
+		if c.op == "!=" {
+			return !has
+		}
+		return has
+	case kindLabel:
+		v, ok := n.Labels[c.label]
+		switch c.op {
+		case "":
+			return ok
+		case "!=":
+			return !ok || v != c.value
+		case ":", "=", "==":
+			return ok && v == c.value
+		}
+		// Ordering on labels requires a numeric value.
+		f, err := strconv.ParseFloat(v, 64)
+		return ok && err == nil && compareNumbers(f, c.op, c.num)
+	default:
+		var got string
+		switch c.field {
+		case "os":
+			got = n.Capabilities.OS
+		case "arch":
+			got = n.Capabilities.Arch
+		case "name":
+			got = n.Name
+		case "status":
+			got = string(n.Status)
+		}
+		if c.op == "!=" {
+			return got != c.value
+		}
+		return got == c.value
+	}
+}
+
+func compareNumbers(a float64, op string, b float64) bool {
+	switch op {
+	case ":", "=", "==":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+// parseExpr compiles a rule expression. Errors are *ExprError.
+func parseExpr(src string) (exprNode, error) {
+	toks, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks, end: len(src) + 1}
+	if len(toks) == 0 {
+		return nil, &ExprError{Pos: 1, Msg: "empty expression"}
+	}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(p.toks) {
+		t := p.toks[p.i]
+		return nil, &ExprError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %s", t)}
+	}
+	return node, nil
+}
+
+type tokKind int
+
+const (
+	tokWord tokKind = iota
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+)
+
+type exprToken struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+func (t exprToken) String() string { return strconv.Quote(t.text) }
+
+// isWordRune reports whether r can appear in a bare word. Words cover
+// field names, label keys ("example.com/owner") and unquoted values.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-./", r)
+}
+
+func lexExpr(src string) ([]exprToken, error) {
+	var toks []exprToken
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		pos := i + 1
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			toks = append(toks, exprToken{tokLParen, "(", pos})
+			i++
+		case c == ')':
+			toks = append(toks, exprToken{tokRParen, ")", pos})
+			i++
+		case strings.HasPrefix(src[i:], "&&"):
+			toks = append(toks, exprToken{tokAnd, "&&", pos})
+			i += 2
+		case strings.HasPrefix(src[i:], "||"):
+			toks = append(toks, exprToken{tokOr, "||", pos})
+			i += 2
+		case strings.HasPrefix(src[i:], "=="), strings.HasPrefix(src[i:], "!="),
+			strings.HasPrefix(src[i:], "<="), strings.HasPrefix(src[i:], ">="):
+			toks = append(toks, exprToken{tokOp, src[i : i+2], pos})
+			i += 2
+		case c == ':' || c == '=' || c == '<' || c == '>':
+			toks = append(toks, exprToken{tokOp, string(c), pos})
+			i++
+		case c == '!':
+			toks = append(toks, exprToken{tokNot, "!", pos})
+			i++
+		case c == '"':
+			j := i + 1
+			var sb strings.Builder
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				sb.WriteByte(src[j])
+				j++
+			}
+			if j >= len(src) {
+				return nil, &ExprError{Pos: pos, Msg: "unterminated string"}
+			}
+			toks = append(toks, exprToken{tokString, sb.String(), pos})
+			i = j + 1
+		default:
+			j := i
+			for j < len(src) {
+				r := rune(src[j])
+				if r >= 0x80 || !isWordRune(r) {
+					break
+				}
+				j++
+			}
+			if j == i {
+				return nil, &ExprError{Pos: pos, Msg: fmt.Sprintf("unexpected character %q", c)}
+			}
+			word := src[i:j]
+			kind := tokWord
+			switch strings.ToUpper(word) {
+			case "AND":
+				kind = tokAnd
+			case "OR":
+				kind = tokOr
+			case "NOT":
+				kind = tokNot
+			}
+			toks = append(toks, exprToken{kind, word, pos})
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+type exprParser struct {
+	toks []exprToken
+	i    int
+	end  int // position reported for "unexpected end"
+}
+
+func (p *exprParser) peek() *exprToken {
+	if p.i < len(p.toks) {
+		return &p.toks[p.i]
+	}
+	return nil
+}
+
+func (p *exprParser) errEnd(msg string) error {
+	return &ExprError{Pos: p.end, Msg: msg + ", got end of expression"}
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t != nil && t.kind == tokOr; t = p.peek() {
+		p.i++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = exprOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t != nil && t.kind == tokAnd; t = p.peek() {
+		p.i++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = exprAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, p.errEnd("expected condition")
+	}
+	switch t.kind {
+	case tokNot:
+		p.i++
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return exprNot{inner}, nil
+	case tokLParen:
+		p.i++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		closing := p.peek()
+		if closing == nil {
+			return nil, p.errEnd(fmt.Sprintf("expected \")\" to close \"(\" at position %d", t.pos))
+		}
+		if closing.kind != tokRParen {
+			return nil, &ExprError{Pos: closing.pos, Msg: fmt.Sprintf("expected \")\", got %s", closing)}
+		}
+		p.i++
+		return inner, nil
+	case tokWord:
+		return p.parseComparison()
+	}
+	return nil, &ExprError{Pos: t.pos, Msg: fmt.Sprintf("expected condition, got %s", t)}
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	ft := p.toks[p.i]
+	p.i++
+	c := exprCompare{field: strings.ToLower(ft.text)}
+	if key, ok := strings.CutPrefix(ft.text, "labels."); ok {
+		c.kind, c.label = kindLabel, key
+	} else if key, ok := strings.CutPrefix(ft.text, "label."); ok {
+		c.kind, c.label = kindLabel, key
+	} else if kind, ok := exprFields[c.field]; ok {
+		c.kind = kind
+	} else {
+		return nil, &ExprError{Pos: ft.pos, Msg: fmt.Sprintf("unknown field %q", ft.text)}
+	}
+	if c.kind == kindLabel {
+		if err := validateLabelKey(c.label); err != nil {
+			return nil, &ExprError{Pos: ft.pos, Msg: err.Error()}
+		}
+	}
+
+	opTok := p.peek()
+	if opTok == nil || opTok.kind != tokOp {
+		// Bare field.
+		switch c.kind {
+		case kindBool, kindLabel:
+			return c, nil
+		}
+		return nil, &ExprError{Pos: ft.pos, Msg: fmt.Sprintf("field %q needs a comparison", ft.text)}
+	}
+	p.i++
+	c.op = opTok.text
+
+	vt := p.peek()
+	if vt == nil {
+		return nil, p.errEnd(fmt.Sprintf("expected value after %q", c.op))
+	}
+	if vt.kind != tokWord && vt.kind != tokString {
+		return nil, &ExprError{Pos: vt.pos, Msg: fmt.Sprintf("expected value after %q, got %s", c.op, vt)}
+	}
+	p.i++
+	c.value = vt.text
+
+	ordering := c.op == "<" || c.op == "<=" || c.op == ">" || c.op == ">="
+	switch c.kind {
+	case kindBool:
+		if ordering {
+			return nil, &ExprError{Pos: opTok.pos, Msg: fmt.Sprintf("operator %q not supported on %q", c.op, ft.text)}
+		}
+		if c.value != "true" && c.value != "false" {
+			return nil, &ExprError{Pos: vt.pos, Msg: fmt.Sprintf("%q expects true or false, got %q", ft.text, c.value)}
+		}
+	case kindNumber:
+		f, err := strconv.ParseFloat(c.value, 64)
+		if err != nil {
+			return nil, &ExprError{Pos: vt.pos, Msg: fmt.Sprintf("%q expects a number, got %q", ft.text, c.value)}
+		}
+		c.num = f
+	case kindLabel:
+		if ordering {
+			f, err := strconv.ParseFloat(c.value, 64)
+			if err != nil {
+				return nil, &ExprError{Pos: vt.pos, Msg: fmt.Sprintf("operator %q expects a number, got %q", c.op, c.value)}
+			}
+			c.num = f
+		}
+	default:
+		if ordering {
+			return nil, &ExprError{Pos: opTok.pos, Msg: fmt.Sprintf("operator %q not supported on %q", c.op, ft.text)}
+		}
+	}
+	return c, nil
+}
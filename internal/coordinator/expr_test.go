@@ -0,0 +1,122 @@
+package coordinator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+func TestExpr_Eval(t *testing.T) {
+	n := &types.Node{
+		Name:   "linux-gpu",
+		Status: types.NodeStatusOnline,
+		Capabilities: types.Capabilities{
+			OS: "linux", Arch: "amd64", GPU: true, MemoryGB: 64,
+			Skills: []string{"docker"}, Tags: []string{"fast"},
+		},
+		Labels: map[string]string{"zone": "home", "cores": "16"},
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"(skill:docker OR skill:kubernetes) AND NOT os:darwin AND memory_gb>=32", true},
+		{"skill:kubernetes || os == darwin", false},
+		{"gpu && arch:amd64", true},
+		{"!gpu", false},
+		{"gpu == false", false},
+		{"memory_gb < 64", false},
+		{"tags:fast and skill:fast", true}, // tags count as skills
+		{"tag:docker", false},
+		{"labels.zone == home AND label.cores > 8", true},
+		{"labels.missing", false},
+		{"labels.zone != lab", true},
+		{`name == "linux-gpu" AND status:online`, true},
+		{"not (os:linux or os:darwin)", false},
+		{"os:linux OR os:darwin AND gpu == false", true}, // AND binds tighter
+	}
+	for _, tt := range tests {
+		e, err := parseExpr(tt.expr)
+		if err != nil {
+			t.Errorf("parseExpr(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := e.eval(n); got != tt.want {
+			t.Errorf("%q = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestExpr_Errors(t *testing.T) {
+	tests := []struct {
+		expr string
+		pos  int
+	}{
+		{"", 1},
+		{"os:linux AND", 13},
+		{"(os:linux", 10},
+		{"os:linux)", 9},
+		{"cpu:8", 1},
+		{"memory_gb >= lots", 14},
+		{"os > linux", 4},
+		{"gpu:maybe", 5},
+		{"skills", 1},
+		{"os:linux # comment", 10},
+		{`name == "open`, 9},
+	}
+	for _, tt := range tests {
+		_, err := parseExpr(tt.expr)
+		var ee *ExprError
+		if !errors.As(err, &ee) {
+			t.Errorf("parseExpr(%q): expected ExprError, got %v", tt.expr, err)
+			continue
+		}
+		if ee.Pos != tt.pos {
+			t.Errorf("parseExpr(%q): position %d, want %d (%v)", tt.expr, ee.Pos, tt.pos, err)
+		}
+	}
+}
+
+func TestValidateRule_Expr(t *testing.T) {
+	if err := validateRule(&types.RoutingRule{Expr: "skill:docker OR os:linux"}, nil); err != nil {
+		t.Errorf("expected valid rule, got %v", err)
+	}
+	err := validateRule(&types.RoutingRule{Expr: "skill:docker OR"}, nil)
+	if err == nil || err.Error() != "invalid expr: at position 16: expected condition, got end of expression" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRoute_ExprRecompiledOnChange(t *testing.T) {
+	reg := NewRegistry(nil)
+	reg.Add(&types.Node{ID: "a", Status: types.NodeStatusOnline, Capabilities: types.Capabilities{OS: "darwin"}})
+	reg.Add(&types.Node{ID: "b", Status: types.NodeStatusOnline, Capabilities: types.Capabilities{OS: "linux"}})
+	rt := NewRouter(reg)
+	rule := &types.RoutingRule{Expr: "os:linux"}
+	if err := rt.AddRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	route := func() string {
+		n, err := rt.Route(&types.Message{Content: "hi"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n.ID
+	}
+	if got := route(); got != "b" {
+		t.Fatalf("expected the expr to select b, got %s", got)
+	}
+
+	edited := *rule
+	edited.Expr = "os:darwin"
+	if _, err := rt.UpdateRule(&edited, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := route(); got != "a" {
+		t.Fatalf("expected the edited expr to select a, got %s", got)
+	}
+	if _, ok := rt.compiled.exprs["os:linux"]; ok || len(rt.compiled.exprs) != 1 {
+		t.Fatalf("expected only the current expr to be compiled, got %v", rt.compiled.exprs)
+	}
+}
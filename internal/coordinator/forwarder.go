@@ -9,35 +9,96 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/SallyKAN/claw-mesh/internal/types"
 )
 
-// Forwarder sends messages to node endpoints via HTTP.
+// Forwarder sends messages to node endpoints via HTTP. It keeps an
+// exponentially weighted moving average of each node's response latency
+// and a circuit breaker per node that fails forwards fast while the node
+// is misbehaving.
 type Forwarder struct {
 	client *http.Client
+
+	mu       sync.Mutex
+	latency  map[string]time.Duration // nodeID -> EWMA of successful forwards
+	breakers *breakers
 }
 
+// latencyAlpha is the weight of a new sample in the latency EWMA.
+const latencyAlpha = 0.3
+
 // NewForwarder creates a message forwarder with sensible defaults.
 func NewForwarder() *Forwarder {
 	return &Forwarder{
-		client: &http.Client{Timeout: 180 * time.Second},
+		client:   &http.Client{Timeout: 180 * time.Second},
+		latency:  make(map[string]time.Duration),
+		breakers: newBreakers(defaultBreakerConfig),
 	}
 }
 
+// CircuitOpen reports whether forwards to the node are currently being
+// rejected by its circuit breaker.
+func (f *Forwarder) CircuitOpen(nodeID string) bool {
+	return f.breakers.isOpen(nodeID)
+}
+
+// CircuitState describes the node's circuit breaker.
+func (f *Forwarder) CircuitState(nodeID string) *types.CircuitState {
+	return f.breakers.state(nodeID)
+}
+
+// Latency returns the EWMA of successful forward latencies to a node.
+// The second result is false if no forward has succeeded yet.
+func (f *Forwarder) Latency(nodeID string) (time.Duration, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.latency[nodeID]
+	return d, ok
+}
+
+func (f *Forwarder) observe(nodeID string, d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	prev, ok := f.latency[nodeID]
+	if !ok {
+		f.latency[nodeID] = d
+		return
+	}
+	f.latency[nodeID] = time.Duration(latencyAlpha*float64(d) + (1-latencyAlpha)*float64(prev))
+}
+
 // backoff durations for retry attempts.
 var retryBackoffs = []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}
 
 // ForwardMessage sends a message to the target node and returns the response.
 // It retries on transient errors (502/503, network errors, connection reset, EOF)
-// with exponential backoff.
+// with exponential backoff, and stops as soon as the node's circuit is open.
 func (f *Forwarder) ForwardMessage(ctx context.Context, node *types.Node, msg *types.Message, token string) (*types.MessageResponse, error) {
 	maxAttempts := len(retryBackoffs) + 1
 	var lastErr error
 	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if !f.breakers.allow(node.ID) {
+			return nil, &transientError{cause: errCircuitOpen, nodeID: node.ID}
+		}
+		start := time.Now()
 		resp, err := f.doForward(ctx, node, msg, token)
+		elapsed := time.Since(start)
+		if ctx.Err() != nil {
+			// The caller gave up; that says nothing about the node.
+			f.breakers.release(node.ID)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			// A 4xx is about the message, e.g. a duplicate or one already
+			// cancelled, not about the node's health.
+			f.breakers.record(node.ID, (err != nil && !isClientError(err)) || elapsed > f.breakers.cfg.SlowCall)
+		}
 		if err == nil {
+			f.observe(node.ID, elapsed)
 			return resp, nil
 		}
 		lastErr = err
@@ -84,7 +145,7 @@ func (f *Forwarder) doForward(ctx context.Context, node *types.Node, msg *types.
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
-		return nil, fmt.Errorf("node %s returned status %d: %s", node.ID, resp.StatusCode, string(body))
+		return nil, &statusError{status: resp.StatusCode, nodeID: node.ID, body: string(body)}
 	}
 
 	var msgResp types.MessageResponse
@@ -94,6 +155,29 @@ func (f *Forwarder) doForward(ctx context.Context, node *types.Node, msg *types.
 	return &msgResp, nil
 }
 
+// CancelMessage asks a node to stop working on a message it was sent, so
+// the run on its gateway is aborted. A node that no longer has the message
+// replies 404, which is not an error.
+func (f *Forwarder) CancelMessage(ctx context.Context, node *types.Node, msgID, token string) error {
+	url := fmt.Sprintf("http://%s/api/v1/messages/%s", node.Endpoint, msgID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
+	if err != nil {
+		return fmt.Errorf("creating cancel request: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("node %s returned status %d", node.ID, resp.StatusCode)
+	}
+	return nil
+}
+
 // transientError represents a retryable forwarding failure.
 type transientError struct {
 	status int
@@ -110,6 +194,23 @@ func (e *transientError) Error() string {
 
 func (e *transientError) Unwrap() error { return e.cause }
 
+// statusError is a non-2xx reply from a node that is not worth retrying.
+type statusError struct {
+	status int
+	nodeID string
+	body   string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("node %s returned status %d: %s", e.nodeID, e.status, e.body)
+}
+
+// isClientError reports whether err is a 4xx reply from a node.
+func isClientError(err error) bool {
+	var se *statusError
+	return errors.As(err, &se) && se.status >= 400 && se.status < 500
+}
+
 func isTransient(err error) bool {
 	var te *transientError
 	if errors.As(err, &te) {
@@ -0,0 +1,404 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+// GroupStore provides persistent storage for node groups.
+type GroupStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// groupStoreData is the on-disk JSON structure.
+type groupStoreData struct {
+	Groups []*types.Group `json:"groups"`
+}
+
+// NewGroupStore creates a group store backed by the given file path.
+// The parent directory is created if it doesn't exist.
+func NewGroupStore(path string) (*GroupStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("creating store directory: %w", err)
+	}
+	return &GroupStore{path: path}, nil
+}
+
+// LoadGroups reads groups from disk.
+// Returns an empty slice if the file doesn't exist.
+func (s *GroupStore) LoadGroups() ([]*types.Group, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading group store: %w", err)
+	}
+	var sd groupStoreData
+	if err := json.Unmarshal(data, &sd); err != nil {
+		return nil, fmt.Errorf("parsing group store: %w", err)
+	}
+	return sd.Groups, nil
+}
+
+// SaveGroups writes groups to disk atomically.
+func (s *GroupStore) SaveGroups(groups []*types.Group) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(groupStoreData{Groups: groups}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling group store: %w", err)
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// GroupSet holds the named node groups. A nil *GroupSet has no groups.
+type GroupSet struct {
+	mu        sync.RWMutex
+	groups    map[string]*types.Group
+	selectors map[string]labelSelector // parsed selectors by group name
+	store     *GroupStore
+	events    *EventBus
+}
+
+// NewGroupSet creates an empty group set.
+// If store is non-nil, groups are loaded from and persisted to disk.
+func NewGroupSet(store ...*GroupStore) *GroupSet {
+	gs := &GroupSet{groups: make(map[string]*types.Group), selectors: make(map[string]labelSelector)}
+	if len(store) > 0 && store[0] != nil {
+		gs.store = store[0]
+		if groups, err := gs.store.LoadGroups(); err != nil {
+			log.Printf("WARN: failed to load persisted groups: %v", err)
+		} else if len(groups) > 0 {
+			for _, g := range groups {
+				if g != nil && g.Name != "" {
+					gs.setLocked(g)
+				}
+			}
+			log.Printf("loaded %d persisted node groups", len(gs.groups))
+		}
+	}
+	return gs
+}
+
+// Add stores a new group. Returns an error if the name is taken.
+func (gs *GroupSet) Add(g *types.Group) error {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	if _, exists := gs.groups[g.Name]; exists {
+		return fmt.Errorf("group %q already exists", g.Name)
+	}
+	g.CreatedAt = time.Now()
+	g.Nodes = nil
+	gs.setLocked(g)
+	if err := gs.persistLocked(); err != nil {
+		gs.deleteLocked(g.Name)
+		return err
+	}
+	gs.events.Publish(types.Event{Type: types.EventGroupAdded, Data: map[string]any{"group": g.Name}})
+	return nil
+}
+
+// Update replaces the definition of an existing group, keeping its
+// creation time. Returns false if the group doesn't exist.
+func (gs *GroupSet) Update(g *types.Group) (bool, error) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	old, exists := gs.groups[g.Name]
+	if !exists {
+		return false, nil
+	}
+	g.CreatedAt = old.CreatedAt
+	g.Nodes = nil
+	gs.setLocked(g)
+	if err := gs.persistLocked(); err != nil {
+		gs.setLocked(old)
+		return true, err
+	}
+	gs.events.Publish(types.Event{Type: types.EventGroupUpdated, Data: map[string]any{"group": g.Name}})
+	return true, nil
+}
+
+// Remove deletes a group. Returns false if not found. Callers go through
+// Router.RemoveGroup, so that no rule is left targeting the group.
+func (gs *GroupSet) Remove(name string) (bool, error) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	old, exists := gs.groups[name]
+	if !exists {
+		return false, nil
+	}
+	gs.deleteLocked(name)
+	if err := gs.persistLocked(); err != nil {
+		gs.setLocked(old)
+		return true, err
+	}
+	gs.events.Publish(types.Event{Type: types.EventGroupDeleted, Data: map[string]any{"group": name}})
+	return true, nil
+}
+
+// Get returns a copy of a group by name, or nil if not found.
+func (gs *GroupSet) Get(name string) *types.Group {
+	if gs == nil {
+		return nil
+	}
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	g, ok := gs.groups[name]
+	if !ok {
+		return nil
+	}
+	return copyGroup(g)
+}
+
+// matcher returns a copy of a group along with its parsed selector, or nil
+// if not found.
+func (gs *GroupSet) matcher(name string) *groupMatcher {
+	if gs == nil {
+		return nil
+	}
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	g, ok := gs.groups[name]
+	if !ok {
+		return nil
+	}
+	return &groupMatcher{Group: copyGroup(g), selector: gs.selectors[name]}
+}
+
+// List returns copies of all groups sorted by name.
+func (gs *GroupSet) List() []*types.Group {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	out := make([]*types.Group, 0, len(gs.groups))
+	for _, g := range gs.groups {
+		out = append(out, copyGroup(g))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// setLocked stores a group and parses its selector. Selectors are
+// validated when a group is added, so one that fails to parse is left out
+// and the group matches no nodes.
+func (gs *GroupSet) setLocked(g *types.Group) {
+	gs.groups[g.Name] = g
+	delete(gs.selectors, g.Name)
+	if g.Selector != "" {
+		if sel, err := parseLabelSelector(g.Selector); err == nil {
+			gs.selectors[g.Name] = sel
+		}
+	}
+}
+
+func (gs *GroupSet) deleteLocked(name string) {
+	delete(gs.groups, name)
+	delete(gs.selectors, name)
+}
+
+func (gs *GroupSet) persistLocked() error {
+	if gs.store == nil {
+		return nil
+	}
+	groups := make([]*types.Group, 0, len(gs.groups))
+	for _, g := range gs.groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	if err := gs.store.SaveGroups(groups); err != nil {
+		return fmt.Errorf("persisting groups: %w", err)
+	}
+	return nil
+}
+
+func copyGroup(g *types.Group) *types.Group {
+	cp := *g
+	cp.Members = append([]string(nil), g.Members...)
+	cp.Nodes = nil
+	return &cp
+}
+
+// groupMatcher is a group along with its parsed selector.
+type groupMatcher struct {
+	*types.Group
+	selector labelSelector
+}
+
+// contains reports whether node n belongs to the group, either by being
+// listed (by name or ID) or by matching its selector.
+func (g *groupMatcher) contains(n *types.Node) bool {
+	if g.Selector != "" {
+		return g.selector != nil && g.selector.matches(n.Labels)
+	}
+	for _, m := range g.Members {
+		if m == n.ID || m == n.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// members filters nodes down to those in the group.
+func (g *groupMatcher) members(nodes []*types.Node) []*types.Node {
+	var out []*types.Node
+	for _, n := range nodes {
+		if g.contains(n) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// validateGroup checks a group definition submitted through the API.
+func validateGroup(g *types.Group) error {
+	if len(g.Name) > maxLabelName || !labelNameRe.MatchString(g.Name) {
+		return fmt.Errorf("invalid group name %q", g.Name)
+	}
+	if g.Selector != "" && len(g.Members) > 0 {
+		return fmt.Errorf("group must have either members or a selector, not both")
+	}
+	if g.Selector == "" && len(g.Members) == 0 {
+		return fmt.Errorf("group must have members or a selector")
+	}
+	if g.Selector != "" {
+		if _, err := parseLabelSelector(g.Selector); err != nil {
+			return fmt.Errorf("invalid selector: %w", err)
+		}
+	}
+	for _, m := range g.Members {
+		if m == "" {
+			return fmt.Errorf("group members must not be empty")
+		}
+	}
+	return nil
+}
+
+// withNodes fills in the IDs of the group's current members.
+func (s *Server) withNodes(g *types.Group) *types.Group {
+	var members []*types.Node
+	if m := s.groups.matcher(g.Name); m != nil {
+		members = m.members(s.registry.List())
+	}
+	g.Nodes = make([]string, 0, len(members))
+	for _, n := range members {
+		g.Nodes = append(g.Nodes, n.ID)
+	}
+	sort.Strings(g.Nodes)
+	return g
+}
+
+// handleListGroups handles GET /api/v1/groups.
+func (s *Server) handleListGroups(w http.ResponseWriter, r *http.Request) {
+	groups := s.groups.List()
+	for _, g := range groups {
+		s.withNodes(g)
+	}
+	writeJSON(w, http.StatusOK, groups)
+}
+
+// handleGetGroup handles GET /api/v1/groups/{name}.
+func (s *Server) handleGetGroup(w http.ResponseWriter, r *http.Request) {
+	g := s.groups.Get(r.PathValue("name"))
+	if g == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "group not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.withNodes(g))
+}
+
+// groupRequest is the body accepted by POST and PUT on groups.
+type groupRequest struct {
+	Name     string   `json:"name"`
+	Members  []string `json:"members"`
+	Selector string   `json:"selector"`
+}
+
+// handleAddGroup handles POST /api/v1/groups.
+func (s *Server) handleAddGroup(w http.ResponseWriter, r *http.Request) {
+	var req groupRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	g := &types.Group{Name: req.Name, Members: req.Members, Selector: req.Selector}
+	if err := validateGroup(g); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if s.groups.Get(g.Name) != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": fmt.Sprintf("group %q already exists", g.Name)})
+		return
+	}
+	if err := s.groups.Add(g); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("adding group: %v", err)})
+		return
+	}
+	log.Printf("node group added: %s", g.Name)
+	writeJSON(w, http.StatusCreated, s.withNodes(copyGroup(g)))
+}
+
+// handleUpdateGroup handles PUT /api/v1/groups/{name}.
+func (s *Server) handleUpdateGroup(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var req groupRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if req.Name != "" && req.Name != name {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "group name cannot be changed"})
+		return
+	}
+	g := &types.Group{Name: name, Members: req.Members, Selector: req.Selector}
+	if err := validateGroup(g); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	found, err := s.groups.Update(g)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("updating group: %v", err)})
+		return
+	}
+	if !found {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "group not found"})
+		return
+	}
+	log.Printf("node group updated: %s", name)
+	writeJSON(w, http.StatusOK, s.withNodes(copyGroup(g)))
+}
+
+// handleDeleteGroup handles DELETE /api/v1/groups/{name}.
+// Groups still targeted by a routing rule cannot be deleted.
+func (s *Server) handleDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	found, err := s.router.RemoveGroup(name)
+	if errors.Is(err, errGroupInUse) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("persisting group deletion: %v", err)})
+		return
+	}
+	if !found {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "group not found"})
+		return
+	}
+	log.Printf("node group deleted: %s", name)
+	w.WriteHeader(http.StatusNoContent)
+}
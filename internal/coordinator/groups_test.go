@@ -0,0 +1,110 @@
+package coordinator
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+func TestRouter_TargetGroup(t *testing.T) {
+	reg := NewRegistry(nil)
+	reg.Add(&types.Node{ID: "node-a", Name: "a", Status: types.NodeStatusOnline})
+	reg.Add(&types.Node{ID: "node-b", Name: "b", Status: types.NodeStatusBusy, Labels: map[string]string{"tier": "gpu"}})
+	reg.Add(&types.Node{ID: "node-c", Name: "c", Status: types.NodeStatusOffline, Labels: map[string]string{"tier": "gpu"}})
+
+	groups := NewGroupSet()
+	groups.Add(&types.Group{Name: "gpu", Selector: "tier=gpu"})
+	groups.Add(&types.Group{Name: "empty", Members: []string{"c"}})
+
+	rt := NewRouter(reg)
+	rt.groups = groups
+	wildcard := true
+	rt.AddRule(&types.RoutingRule{Match: types.MatchCriteria{Wildcard: &wildcard}, TargetGroup: "empty"})
+	rt.AddRule(&types.RoutingRule{Match: types.MatchCriteria{Wildcard: &wildcard}, TargetGroup: "gpu"})
+
+	// "empty" has no healthy members and is skipped; within "gpu" only the
+	// busy node-b is healthy, so it wins over the idle non-member node-a.
+	n, err := rt.Route(&types.Message{Content: "hi"})
+	if err != nil || n.ID != "node-b" {
+		t.Fatalf("expected node-b from group gpu, got %v, %v", n, err)
+	}
+}
+
+func TestGroupSet_PersistAndDeleteGuard(t *testing.T) {
+	store, err := NewGroupStore(filepath.Join(t.TempDir(), "groups.json"))
+	if err != nil {
+		t.Fatalf("NewGroupStore: %v", err)
+	}
+	srv := newTestServer()
+	srv.groups = NewGroupSet(store)
+	srv.router = NewRouter(srv.registry)
+	srv.router.groups = srv.groups
+
+	if err := srv.groups.Add(&types.Group{Name: "home", Members: []string{"mac"}}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if g := NewGroupSet(store).Get("home"); g == nil || len(g.Members) != 1 || g.Members[0] != "mac" {
+		t.Fatalf("expected group to be restored from disk, got %+v", g)
+	}
+
+	srv.router.AddRule(&types.RoutingRule{Match: types.MatchCriteria{RequiresOS: "darwin"}, TargetGroup: "home"})
+	r := httptest.NewRequest(http.MethodDelete, "/api/v1/groups/home", nil)
+	r.SetPathValue("name", "home")
+	rr := httptest.NewRecorder()
+	srv.handleDeleteGroup(rr, r)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a group in use, got %d", rr.Code)
+	}
+}
+
+func TestGroupSet_SelectorFollowsUpdates(t *testing.T) {
+	gpu := &types.Node{ID: "node-a", Labels: map[string]string{"tier": "gpu"}}
+	cpu := &types.Node{ID: "node-b", Labels: map[string]string{"tier": "cpu"}}
+	groups := NewGroupSet()
+	groups.Add(&types.Group{Name: "pool", Selector: "tier=gpu"})
+	if m := groups.matcher("pool"); m == nil || !m.contains(gpu) || m.contains(cpu) {
+		t.Fatalf("expected pool to select gpu nodes, got %+v", m)
+	}
+
+	groups.Update(&types.Group{Name: "pool", Selector: "tier=cpu"})
+	if m := groups.matcher("pool"); m == nil || m.contains(gpu) || !m.contains(cpu) {
+		t.Fatalf("expected the updated selector to be used, got %+v", m)
+	}
+	groups.Update(&types.Group{Name: "pool", Members: []string{"node-a"}})
+	if m := groups.matcher("pool"); m == nil || m.selector != nil || !m.contains(gpu) {
+		t.Fatalf("expected the selector to be dropped for a member list, got %+v", m)
+	}
+
+	groups.Remove("pool")
+	if m := groups.matcher("pool"); m != nil || len(groups.selectors) != 0 {
+		t.Fatalf("expected the group and its selector to be removed, got %+v, %v", m, groups.selectors)
+	}
+}
+
+func TestRouter_RemoveGroupGuard(t *testing.T) {
+	rt := NewRouter(NewRegistry(nil))
+	rt.groups = NewGroupSet()
+	rt.groups.Add(&types.Group{Name: "home", Members: []string{"mac"}})
+
+	if err := rt.AddRule(&types.RoutingRule{TargetGroup: "away"}); !errors.Is(err, errUnknownGroup) {
+		t.Fatalf("expected a rule targeting a missing group to be refused, got %v", err)
+	}
+	rule := &types.RoutingRule{TargetGroup: "home"}
+	if err := rt.AddRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rt.RemoveGroup("home"); !errors.Is(err, errGroupInUse) {
+		t.Fatalf("expected a targeted group to be kept, got %v", err)
+	}
+	rt.RemoveRule(rule.ID)
+	if found, err := rt.RemoveGroup("home"); !found || err != nil {
+		t.Fatalf("expected the unused group to be removed, got %v, %v", found, err)
+	}
+	if _, err := rt.ReplaceRules([]*types.RoutingRule{{TargetGroup: "home"}}, 0); !errors.Is(err, errUnknownGroup) {
+		t.Fatalf("expected a rule set targeting a removed group to be refused, got %v", err)
+	}
+}
@@ -13,13 +13,14 @@ import (
 // HealthChecker monitors node heartbeats and optionally probes node endpoints.
 type HealthChecker struct {
 	registry *Registry
+	events   *EventBus
 	timeout  time.Duration
 	interval time.Duration
 	// Active probing
-	activeProbe    bool
-	probeClient    *http.Client
-	failThreshold  int
-	probeFailures  map[string]int // nodeID -> consecutive probe failures
+	activeProbe     bool
+	probeClient     *http.Client
+	failThreshold   int
+	probeFailures   map[string]int // nodeID -> consecutive probe failures
 	probeFailuresMu sync.Mutex
 
 	startOnce sync.Once
@@ -114,6 +115,11 @@ func (h *HealthChecker) recordProbeFailure(nodeID, name string) {
 		log.Printf("node %s (%s) failed %d active probes, marking offline", nodeID, name, count)
 		h.registry.UpdateStatus(nodeID, "offline")
 		delete(h.probeFailures, nodeID)
+		h.events.Publish(types.Event{
+			Type:   types.EventNodeOffline,
+			NodeID: nodeID,
+			Data:   map[string]any{"name": name, "reason": "probe_failed", "failures": count},
+		})
 	}
 }
 
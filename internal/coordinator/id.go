@@ -2,6 +2,8 @@ package coordinator
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 )
@@ -11,6 +13,11 @@ const maxIDRetries = 3
 // generateID creates a random node ID like "node-a1b2c3d4e5f6a7b8".
 // It retries up to maxIDRetries times on rand.Read failure.
 func generateID() (string, error) {
+	return generatePrefixedID("node")
+}
+
+// generatePrefixedID creates a random ID like "<prefix>-a1b2c3d4e5f6a7b8".
+func generatePrefixedID(prefix string) (string, error) {
 	b := make([]byte, 8)
 	var lastErr error
 	for i := 0; i < maxIDRetries; i++ {
@@ -18,9 +25,19 @@ func generateID() (string, error) {
 			lastErr = err
 			continue
 		}
-		return fmt.Sprintf("node-%x", b), nil
+		return fmt.Sprintf("%s-%x", prefix, b), nil
 	}
-	return "", fmt.Errorf("generating node ID after %d attempts: %w", maxIDRetries, lastErr)
+	return "", fmt.Errorf("generating %s ID after %d attempts: %w", prefix, maxIDRetries, lastErr)
+}
+
+// validNodeID reports whether id has the shape produced by generateID.
+func validNodeID(id string) bool {
+	const prefix = "node-"
+	if len(id) != len(prefix)+16 || id[:len(prefix)] != prefix {
+		return false
+	}
+	_, err := hex.DecodeString(id[len(prefix):])
+	return err == nil
 }
 
 // generateToken creates a random token for per-node authentication.
@@ -32,6 +49,13 @@ func generateToken() (string, error) {
 	return fmt.Sprintf("%x", b), nil
 }
 
+// hashToken returns the hex-encoded SHA-256 of a token. Only hashes of
+// per-node credentials are persisted to disk.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // generateUniqueID generates an ID that doesn't collide with existing keys.
 // exists is a function that returns true if the ID is already taken.
 func generateUniqueID(exists func(string) bool) (string, error) {
@@ -0,0 +1,83 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+var errMessageCancelled = errors.New("message cancelled")
+
+// inflightTable tracks the messages being forwarded, including those
+// waiting for a node's concurrency slot, so they can be cancelled by ID.
+// A broadcast message has one entry per node it is sent to.
+type inflightTable struct {
+	mu   sync.Mutex
+	next uint64
+	msgs map[string]map[uint64]context.CancelCauseFunc
+}
+
+func newInflightTable() *inflightTable {
+	return &inflightTable{msgs: make(map[string]map[uint64]context.CancelCauseFunc)}
+}
+
+// track returns a context for forwarding msgID that cancel ends, and a
+// function to call when the forward is over.
+func (t *inflightTable) track(ctx context.Context, msgID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	t.mu.Lock()
+	t.next++
+	key := t.next
+	if t.msgs[msgID] == nil {
+		t.msgs[msgID] = make(map[uint64]context.CancelCauseFunc)
+	}
+	t.msgs[msgID][key] = cancel
+	t.mu.Unlock()
+
+	return ctx, func() {
+		t.mu.Lock()
+		delete(t.msgs[msgID], key)
+		if len(t.msgs[msgID]) == 0 {
+			delete(t.msgs, msgID)
+		}
+		t.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancel cancels every forward of msgID and reports whether there was one.
+func (t *inflightTable) cancel(msgID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	forwards := t.msgs[msgID]
+	for _, cancel := range forwards {
+		cancel(errMessageCancelled)
+	}
+	return len(forwards) > 0
+}
+
+// cancelledErr returns errMessageCancelled if ctx was ended by
+// inflightTable.cancel, and err otherwise.
+func cancelledErr(ctx context.Context, err error) error {
+	if err != nil && errors.Is(context.Cause(ctx), errMessageCancelled) {
+		return errMessageCancelled
+	}
+	return err
+}
+
+// handleCancelMessage handles DELETE /api/v1/messages/{id} — cancel a
+// message that is being forwarded. The node is told to abort its run.
+func (s *Server) handleCancelMessage(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !s.inflight.cancel(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "message not in flight"})
+		return
+	}
+	log.Printf("message %s cancelled", id)
+	s.events.Publish(types.Event{Type: types.EventMessageCancelled, MessageID: id})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,68 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+func TestCancelMessage(t *testing.T) {
+	received := make(chan struct{})
+	aborted := make(chan struct{})
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			close(aborted)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		close(received)
+		select {
+		case <-aborted:
+		case <-r.Context().Done():
+		}
+	}))
+	defer node.Close()
+
+	srv := newTestServer()
+	srv.router = NewRouter(srv.registry)
+	srv.forwarder = NewForwarder()
+	srv.jobs = newJobTable(nil)
+	srv.registry.Add(&types.Node{ID: "n1", Name: "n1", Endpoint: strings.TrimPrefix(node.URL, "http://"), Status: types.NodeStatusOnline})
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/route?async=true", strings.NewReader(`{"content":"hi"}`))
+	rr := httptest.NewRecorder()
+	srv.handleRouteAuto(rr, r)
+	var job types.Job
+	json.NewDecoder(rr.Body).Decode(&job)
+	<-received
+
+	cancel := func() int {
+		r := httptest.NewRequest(http.MethodDelete, "/api/v1/messages/"+job.MessageID, nil)
+		r.SetPathValue("id", job.MessageID)
+		rr := httptest.NewRecorder()
+		srv.handleCancelMessage(rr, r)
+		return rr.Code
+	}
+	if code := cancel(); code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", code)
+	}
+	select {
+	case <-aborted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the node was not told to cancel the message")
+	}
+
+	_, done, _ := srv.jobs.get(job.ID)
+	<-done
+	if j, _, _ := srv.jobs.get(job.ID); j.Status != types.JobFailed || j.Error != errMessageCancelled.Error() {
+		t.Fatalf("unexpected job after cancel %+v", j)
+	}
+	if code := cancel(); code != http.StatusNotFound {
+		t.Fatalf("expected 404 once the message is no longer in flight, got %d", code)
+	}
+}
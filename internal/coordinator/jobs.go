@@ -0,0 +1,235 @@
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+const (
+	// maxJobs caps how many jobs are kept; the oldest finished jobs are
+	// dropped first.
+	maxJobs = 1000
+	// jobRetention is how long a finished job can still be fetched.
+	jobRetention = time.Hour
+	// maxJobWait bounds a long-poll so it ends well within the server's
+	// write timeout.
+	maxJobWait = 25 * time.Second
+)
+
+// jobResult is what a job ends with: the node's response, or the task
+// plan its message became.
+type jobResult struct {
+	resp     *types.MessageResponse
+	planID   string
+	attempts []types.ForwardAttempt
+}
+
+// jobRunner forwards or plans a job's message.
+type jobRunner func(ctx context.Context) (jobResult, error)
+
+// job is a running or finished asynchronous message.
+type job struct {
+	info   types.Job // guarded by jobTable.mu
+	done   chan struct{}
+	cancel context.CancelFunc
+}
+
+// jobTable keeps asynchronous messages in memory until they have been
+// finished for jobRetention.
+type jobTable struct {
+	events *EventBus
+
+	mu    sync.Mutex
+	jobs  map[string]*job
+	order []string // IDs, oldest first
+}
+
+func newJobTable(events *EventBus) *jobTable {
+	return &jobTable{events: events, jobs: make(map[string]*job)}
+}
+
+// start runs fn in the background as a new job for msg.
+func (jt *jobTable) start(msg *types.Message, fn jobRunner) (types.Job, error) {
+	id, err := generatePrefixedID("job")
+	if err != nil {
+		return types.Job{}, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		info: types.Job{
+			ID:         id,
+			Status:     types.JobRunning,
+			MessageID:  msg.ID,
+			TargetNode: msg.TargetNode,
+			CreatedAt:  time.Now(),
+		},
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+
+	jt.mu.Lock()
+	jt.pruneLocked()
+	jt.jobs[id] = j
+	jt.order = append(jt.order, id)
+	info := j.info
+	jt.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		res, err := fn(ctx)
+		jt.finish(j, res, err)
+	}()
+	return info, nil
+}
+
+// finish records a job's outcome and wakes its long-polls.
+func (jt *jobTable) finish(j *job, res jobResult, err error) {
+	now := time.Now()
+	jt.mu.Lock()
+	j.info.FinishedAt = &now
+	j.info.Attempts = res.attempts
+	if err != nil {
+		j.info.Status = types.JobFailed
+		j.info.Error = err.Error()
+	} else {
+		j.info.Status = types.JobSucceeded
+		j.info.Result = res.resp
+		j.info.PlanID = res.planID
+	}
+	info := j.info
+	jt.mu.Unlock()
+	close(j.done)
+
+	ev := types.Event{Type: types.EventJobSucceeded, MessageID: info.MessageID, Data: map[string]any{"job_id": info.ID}}
+	switch {
+	case err != nil:
+		ev.Type = types.EventJobFailed
+		ev.Data["error"] = info.Error
+	case res.planID != "":
+		ev.Data["plan_id"] = res.planID
+	default:
+		ev.NodeID = res.resp.NodeID
+	}
+	jt.events.Publish(ev)
+	log.Printf("job %s %s", info.ID, info.Status)
+}
+
+// pruneLocked drops jobs finished more than jobRetention ago and, over
+// maxJobs, the oldest finished ones. Running jobs are always kept.
+func (jt *jobTable) pruneLocked() {
+	cutoff := time.Now().Add(-jobRetention)
+	excess := len(jt.order) - maxJobs + 1
+	jt.order = slices.DeleteFunc(jt.order, func(id string) bool {
+		fin := jt.jobs[id].info.FinishedAt
+		if fin == nil || (excess <= 0 && fin.After(cutoff)) {
+			return false
+		}
+		delete(jt.jobs, id)
+		excess--
+		return true
+	})
+}
+
+// get returns a job and a channel closed when it finishes.
+func (jt *jobTable) get(id string) (types.Job, <-chan struct{}, bool) {
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+	j, ok := jt.jobs[id]
+	if !ok {
+		return types.Job{}, nil, false
+	}
+	return j.info, j.done, true
+}
+
+// list returns all jobs, newest first.
+func (jt *jobTable) list() []types.Job {
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+	out := make([]types.Job, 0, len(jt.order))
+	for i := len(jt.order) - 1; i >= 0; i-- {
+		out = append(out, jt.jobs[jt.order[i]].info)
+	}
+	return out
+}
+
+// cancelAll cancels every running job, e.g. on shutdown.
+func (jt *jobTable) cancelAll() {
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+	for _, j := range jt.jobs {
+		j.cancel()
+	}
+}
+
+// boolParam reports whether a route request set the query parameter name,
+// e.g. ?async=true.
+func boolParam(r *http.Request, name string) (bool, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s value %q", name, v)
+	}
+	return b, nil
+}
+
+// startJob runs fn as a job for msg and replies 202 with the job.
+func (s *Server) startJob(w http.ResponseWriter, msg *types.Message, fn jobRunner) {
+	j, err := s.jobs.start(msg, fn)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to generate job ID"})
+		return
+	}
+	log.Printf("job %s started for message %s", j.ID, msg.ID)
+	w.Header().Set("Location", "/api/v1/jobs/"+j.ID)
+	writeJSON(w, http.StatusAccepted, j)
+}
+
+// handleListJobs handles GET /api/v1/jobs.
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.jobs.list())
+}
+
+// handleGetJob handles GET /api/v1/jobs/{id}. With ?wait=<seconds> it
+// long-polls: the response is held until the job finishes or the wait
+// (at most 25s) is over.
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	var wait time.Duration
+	if v := r.URL.Query().Get("wait"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid wait %q: expected seconds", v)})
+			return
+		}
+		wait = min(time.Duration(secs)*time.Second, maxJobWait)
+	}
+
+	id := r.PathValue("id")
+	j, done, ok := s.jobs.get(id)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+		return
+	}
+	if j.Status == types.JobRunning && wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-done:
+			j, _, _ = s.jobs.get(id)
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, j)
+}
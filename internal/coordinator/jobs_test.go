@@ -0,0 +1,68 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+func TestAsyncRouteJob(t *testing.T) {
+	release := make(chan struct{})
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		json.NewEncoder(w).Encode(types.MessageResponse{Response: "done"})
+	}))
+	defer node.Close()
+
+	srv := newTestServer()
+	srv.router = NewRouter(srv.registry)
+	srv.forwarder = NewForwarder()
+	srv.jobs = newJobTable(nil)
+	srv.registry.Add(&types.Node{ID: "n1", Name: "n1", Endpoint: strings.TrimPrefix(node.URL, "http://"), Status: types.NodeStatusOnline})
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/route/n1?async=true", strings.NewReader(`{"content":"hi"}`))
+	r.SetPathValue("nodeId", "n1")
+	rr := httptest.NewRecorder()
+	srv.handleRouteToNode(rr, r)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var job types.Job
+	json.NewDecoder(rr.Body).Decode(&job)
+	if job.Status != types.JobRunning || rr.Header().Get("Location") != "/api/v1/jobs/"+job.ID {
+		t.Fatalf("unexpected job %+v, location %q", job, rr.Header().Get("Location"))
+	}
+
+	get := func(wait string) types.Job {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+job.ID+"?wait="+wait, nil)
+		r.SetPathValue("id", job.ID)
+		rr := httptest.NewRecorder()
+		srv.handleGetJob(rr, r)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("get job: %d: %s", rr.Code, rr.Body.String())
+		}
+		var j types.Job
+		json.NewDecoder(rr.Body).Decode(&j)
+		return j
+	}
+
+	// The message is still with the node, so a short poll times out.
+	if j := get("0"); j.Status != types.JobRunning {
+		t.Fatalf("expected a running job, got %s", j.Status)
+	}
+
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+	j := get("5")
+	if j.Status != types.JobSucceeded || j.Result == nil || j.Result.Response != "done" || j.FinishedAt == nil {
+		t.Fatalf("unexpected finished job %+v", j)
+	}
+	if jobs := srv.jobs.list(); len(jobs) != 1 || jobs[0].ID != job.ID {
+		t.Fatalf("unexpected job list %+v", jobs)
+	}
+}
@@ -0,0 +1,244 @@
+package coordinator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// journalSlack is how many journal records beyond one per item are
+// allowed before a journal store is compacted.
+const journalSlack = 1000
+
+// journalStore provides persistent storage for a list of items: a snapshot
+// of every item plus a journal of the changes made since, so that a change
+// costs one appended record rather than rewriting every item. The journal
+// is folded into a new snapshot once it outgrows the items.
+//
+// On disk the snapshot is {"seq": N, "<kind>s": [...]} and each journal
+// line is {"seq": N, "<kind>": {...}} or {"seq": N, "removed": [IDs]}.
+type journalStore[T any] struct {
+	kind string // e.g. "task"; names the JSON fields and errors
+	id   func(*T) string
+
+	mu      sync.Mutex
+	path    string
+	journal *os.File // opened on first append
+	seq     uint64   // sequence number of the last record written
+	records int      // records in the journal
+}
+
+// newJournalStore creates a store backed by the given file path, whose
+// journal is path + ".journal". The parent directory is created if it
+// doesn't exist.
+func newJournalStore[T any](path, kind string, id func(*T) string) (*journalStore[T], error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("creating store directory: %w", err)
+	}
+	return &journalStore[T]{kind: kind, id: id, path: path}, nil
+}
+
+func (s *journalStore[T]) journalPath() string {
+	return s.path + ".journal"
+}
+
+// Load reads the snapshot and replays the journal over it, returning the
+// items oldest first. Returns an empty slice if neither file exists.
+// Replay stops at a torn record left by a crash mid-write.
+func (s *journalStore[T]) Load() ([]*T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var snapshot map[string]json.RawMessage
+	data, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading %s store: %w", s.kind, err)
+	}
+	var snapSeq uint64
+	var saved []*T
+	if err == nil {
+		err := json.Unmarshal(data, &snapshot)
+		if err == nil && snapshot["seq"] != nil {
+			err = json.Unmarshal(snapshot["seq"], &snapSeq)
+		}
+		if err == nil && snapshot[s.kind+"s"] != nil {
+			err = json.Unmarshal(snapshot[s.kind+"s"], &saved)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s store: %w", s.kind, err)
+		}
+	}
+	s.seq = snapSeq
+	items := make(map[string]*T, len(saved))
+	var order []string
+	for _, it := range saved {
+		items[s.id(it)] = it
+		order = append(order, s.id(it))
+	}
+
+	data, err = os.ReadFile(s.journalPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading %s journal: %w", s.kind, err)
+	}
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			data = nil
+		}
+		seq, item, removed, err := s.decodeRecord(line)
+		if err != nil {
+			log.Printf("WARN: ignoring the rest of the %s journal: %v", s.kind, err)
+			break
+		}
+		if seq <= snapSeq {
+			continue // already in the snapshot
+		}
+		s.seq = seq
+		if item != nil {
+			id := s.id(item)
+			if items[id] == nil {
+				order = append(order, id)
+			}
+			items[id] = item
+		}
+		for _, id := range removed {
+			delete(items, id)
+		}
+	}
+
+	var out []*T
+	for _, id := range order {
+		if it := items[id]; it != nil {
+			out = append(out, it)
+			delete(items, id) // an ID removed and re-added is listed once
+		}
+	}
+	return out, nil
+}
+
+// decodeRecord parses one journal line.
+func (s *journalStore[T]) decodeRecord(line []byte) (seq uint64, item *T, removed []string, err error) {
+	var rec map[string]json.RawMessage
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return 0, nil, nil, err
+	}
+	if err := json.Unmarshal(rec["seq"], &seq); err != nil {
+		return 0, nil, nil, fmt.Errorf("record without seq: %w", err)
+	}
+	if raw := rec[s.kind]; raw != nil {
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+	if raw := rec["removed"]; raw != nil {
+		if err := json.Unmarshal(raw, &removed); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+	return seq, item, removed, nil
+}
+
+// Append journals the new state of changed and the removal of the removed
+// IDs.
+func (s *journalStore[T]) Append(changed []*T, removed []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	seq := s.seq
+	for _, it := range changed {
+		seq++
+		if err := enc.Encode(map[string]any{"seq": seq, s.kind: it}); err != nil {
+			return fmt.Errorf("marshaling %s record: %w", s.kind, err)
+		}
+	}
+	if len(removed) > 0 {
+		seq++
+		if err := enc.Encode(map[string]any{"seq": seq, "removed": removed}); err != nil {
+			return fmt.Errorf("marshaling %s record: %w", s.kind, err)
+		}
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	if s.journal == nil {
+		f, err := os.OpenFile(s.journalPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("opening %s journal: %w", s.kind, err)
+		}
+		s.journal = f
+	}
+	if _, err := s.journal.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("writing %s journal: %w", s.kind, err)
+	}
+	if err := s.journal.Sync(); err != nil {
+		return fmt.Errorf("syncing %s journal: %w", s.kind, err)
+	}
+	s.records += int(seq - s.seq)
+	s.seq = seq
+	return nil
+}
+
+// JournalLen returns the number of records in the journal.
+func (s *journalStore[T]) JournalLen() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records
+}
+
+// Save writes a snapshot of every item atomically and empties the
+// journal.
+func (s *journalStore[T]) Save(items []*T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if items == nil {
+		items = []*T{}
+	}
+	data, err := json.MarshalIndent(map[string]any{"seq": s.seq, s.kind + "s": items}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling %s store: %w", s.kind, err)
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return err
+	}
+	// Records up to seq are skipped on load, so a crash before the journal
+	// is emptied loses nothing.
+	if err := os.Truncate(s.journalPath(), 0); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("truncating %s journal: %w", s.kind, err)
+	}
+	s.records = 0
+	return nil
+}
+
+// persist journals changed and removed, logging failures, and compacts the
+// store with a snapshot of all() once the journal holds more than
+// journalSlack records beyond one per item. n is the number of items.
+func (s *journalStore[T]) persist(changed []*T, removed []string, n int, all func() []*T) {
+	if len(changed) == 0 && len(removed) == 0 {
+		return
+	}
+	if err := s.Append(changed, removed); err != nil {
+		log.Printf("WARN: failed to persist %ss: %v", s.kind, err)
+		return
+	}
+	if s.JournalLen() > n+journalSlack {
+		s.compact(all())
+	}
+}
+
+// compact writes a snapshot of items, emptying the journal, and logs a
+// failure.
+func (s *journalStore[T]) compact(items []*T) {
+	if err := s.Save(items); err != nil {
+		log.Printf("WARN: failed to persist %ss: %v", s.kind, err)
+	}
+}
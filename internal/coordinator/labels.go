@@ -0,0 +1,237 @@
+package coordinator
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Label keys are an optional DNS-style prefix plus a name ("tier",
+// "example.com/owner"); values are short alphanumeric strings. Both follow
+// Kubernetes conventions so selectors read the same way.
+var (
+	labelNameRe   = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+	labelPrefixRe = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+)
+
+const (
+	maxLabelName   = 63
+	maxLabelPrefix = 253
+	maxLabels      = 64
+)
+
+// validateLabelKey checks a label key.
+func validateLabelKey(key string) error {
+	name := key
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		prefix := key[:i]
+		name = key[i+1:]
+		if len(prefix) > maxLabelPrefix || !labelPrefixRe.MatchString(prefix) {
+			return fmt.Errorf("invalid label key %q: bad prefix", key)
+		}
+	}
+	if len(name) > maxLabelName || !labelNameRe.MatchString(name) {
+		return fmt.Errorf("invalid label key %q", key)
+	}
+	return nil
+}
+
+// validateLabelValue checks a label value. Empty values are allowed.
+func validateLabelValue(value string) error {
+	if value == "" {
+		return nil
+	}
+	if len(value) > maxLabelName || !labelNameRe.MatchString(value) {
+		return fmt.Errorf("invalid label value %q", value)
+	}
+	return nil
+}
+
+// validateLabels checks a full label set.
+func validateLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return fmt.Errorf("too many labels (max %d)", maxLabels)
+	}
+	for k, v := range labels {
+		if err := validateLabelKey(k); err != nil {
+			return err
+		}
+		if err := validateLabelValue(v); err != nil {
+			return fmt.Errorf("label %q: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// selectorOp is a label requirement operator.
+type selectorOp string
+
+const (
+	opEquals       selectorOp = "="
+	opNotEquals    selectorOp = "!="
+	opIn           selectorOp = "in"
+	opNotIn        selectorOp = "notin"
+	opExists       selectorOp = "exists"
+	opDoesNotExist selectorOp = "!"
+)
+
+// labelRequirement is one comma-separated term of a label selector.
+type labelRequirement struct {
+	key    string
+	op     selectorOp
+	values []string
+}
+
+// labelSelector is a conjunction of requirements; all must hold.
+type labelSelector []labelRequirement
+
+// parseLabelSelector parses a Kubernetes-style label selector such as
+// "zone=home,tier!=slow,owner in (alice,bob),gpu,!spot".
+func parseLabelSelector(s string) (labelSelector, error) {
+	var sel labelSelector
+	for _, term := range splitOutsideParens(s, ',') {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			return nil, fmt.Errorf("empty term in label selector %q", s)
+		}
+		req, err := parseRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, req)
+	}
+	if len(sel) == 0 {
+		return nil, fmt.Errorf("empty label selector")
+	}
+	return sel, nil
+}
+
+func parseRequirement(term string) (labelRequirement, error) {
+	// Set-based: "key in (a,b)" / "key notin (a,b)".
+	if open := strings.Index(term, "("); open >= 0 {
+		if !strings.HasSuffix(term, ")") {
+			return labelRequirement{}, fmt.Errorf("unterminated value list in %q", term)
+		}
+		fields := strings.Fields(term[:open])
+		if len(fields) != 2 {
+			return labelRequirement{}, fmt.Errorf("expected \"key in (...)\" or \"key notin (...)\", got %q", term)
+		}
+		op := selectorOp(fields[1])
+		if op != opIn && op != opNotIn {
+			return labelRequirement{}, fmt.Errorf("unknown operator %q in %q", fields[1], term)
+		}
+		var values []string
+		for _, v := range strings.Split(term[open+1:len(term)-1], ",") {
+			values = append(values, strings.TrimSpace(v))
+		}
+		if len(values) == 1 && values[0] == "" {
+			return labelRequirement{}, fmt.Errorf("empty value list in %q", term)
+		}
+		return newRequirement(fields[0], op, values)
+	}
+
+	// Equality-based: "key=value", "key==value", "key!=value".
+	if i := strings.Index(term, "!="); i >= 0 {
+		return newRequirement(term[:i], opNotEquals, []string{term[i+2:]})
+	}
+	if i := strings.Index(term, "=="); i >= 0 {
+		return newRequirement(term[:i], opEquals, []string{term[i+2:]})
+	}
+	if i := strings.Index(term, "="); i >= 0 {
+		return newRequirement(term[:i], opEquals, []string{term[i+1:]})
+	}
+
+	// Existence: "key" / "!key".
+	if strings.HasPrefix(term, "!") {
+		return newRequirement(term[1:], opDoesNotExist, nil)
+	}
+	return newRequirement(term, opExists, nil)
+}
+
+func newRequirement(key string, op selectorOp, values []string) (labelRequirement, error) {
+	key = strings.TrimSpace(key)
+	if err := validateLabelKey(key); err != nil {
+		return labelRequirement{}, err
+	}
+	for i, v := range values {
+		values[i] = strings.TrimSpace(v)
+		if err := validateLabelValue(values[i]); err != nil {
+			return labelRequirement{}, err
+		}
+	}
+	return labelRequirement{key: key, op: op, values: values}, nil
+}
+
+// matches reports whether labels satisfy every requirement. As in
+// Kubernetes, != and notin also match nodes that lack the key.
+func (sel labelSelector) matches(labels map[string]string) bool {
+	for _, req := range sel {
+		v, ok := labels[req.key]
+		switch req.op {
+		case opExists:
+			if !ok {
+				return false
+			}
+		case opDoesNotExist:
+			if ok {
+				return false
+			}
+		case opEquals:
+			if !ok || v != req.values[0] {
+				return false
+			}
+		case opNotEquals:
+			if ok && v == req.values[0] {
+				return false
+			}
+		case opIn:
+			if !ok || !containsString(req.values, v) {
+				return false
+			}
+		case opNotIn:
+			if ok && containsString(req.values, v) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// splitOutsideParens splits s on sep, ignoring separators inside
+// parentheses so that "a in (x,y),b" yields two terms.
+func splitOutsideParens(s string, sep rune) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case r == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// formatLabels renders labels as sorted "k=v" pairs.
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,51 @@
+package coordinator
+
+import "testing"
+
+func TestLabelSelector(t *testing.T) {
+	labels := map[string]string{"zone": "home", "tier": "fast", "owner": "alice"}
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"zone=home", true},
+		{"zone==home", true},
+		{"zone=lab", false},
+		{"zone!=lab", true},
+		{"region!=eu", true}, // missing key satisfies !=
+		{"tier in (fast,gpu)", true},
+		{"tier notin (fast)", false},
+		{"owner in (bob, carol)", false},
+		{"owner", true},
+		{"!spot", true},
+		{"!owner", false},
+		{"zone=home,tier in (fast,gpu),!spot", true},
+		{"zone=home,region", false},
+	}
+	for _, tt := range tests {
+		sel, err := parseLabelSelector(tt.selector)
+		if err != nil {
+			t.Fatalf("parseLabelSelector(%q): %v", tt.selector, err)
+		}
+		if got := sel.matches(labels); got != tt.want {
+			t.Errorf("%q matches = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestLabelSelector_Invalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"zone=home,",
+		"tier in fast",
+		"tier in (fast",
+		"tier between (a,b)",
+		"bad key=x",
+		"zone=bad value",
+		"tier in ()",
+	} {
+		if _, err := parseLabelSelector(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
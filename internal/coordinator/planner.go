@@ -0,0 +1,336 @@
+package coordinator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/config"
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+const (
+	defaultPlannerTimeout = 10 * time.Second
+	// maxPlannerTimeout bounds how long a message waits for the planner
+	// before it is routed as a single step.
+	maxPlannerTimeout = 5 * time.Minute
+
+	// plannerSource is the source of the llm planner's own messages,
+	// which are never planned.
+	plannerSource = "planner"
+)
+
+// Planner decomposes a message into a task plan. It returns nil if the
+// message is best handled as a single step.
+type Planner interface {
+	Plan(ctx context.Context, msg *types.Message, inv *SkillInventory) (*types.PlanRequest, error)
+}
+
+// SkillInventory is what a Planner can plan with: the nodes eligible for
+// auto-routing, sorted by ID, and for each skill (or tag) the nodes that
+// have it.
+type SkillInventory struct {
+	Nodes  []*types.Node
+	Skills map[string][]*types.Node
+}
+
+// skillNames returns the inventory's skills, sorted.
+func (inv *SkillInventory) skillNames() []string {
+	names := make([]string, 0, len(inv.Skills))
+	for name := range inv.Skills {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkPlanSkills rejects a plan with a step whose skill no node in the
+// inventory has.
+func checkPlanSkills(req *types.PlanRequest, inv *SkillInventory) error {
+	for i, st := range req.Steps {
+		if st.Skill != "" && len(inv.Skills[st.Skill]) == 0 {
+			return fmt.Errorf("step %d: no available node has skill %q", i+1, st.Skill)
+		}
+	}
+	return nil
+}
+
+// newPlanner creates the planner selected by cfg, or returns nil if
+// planning is disabled. ask sends a prompt to a node, for the llm planner.
+func newPlanner(cfg config.PlannerConfig, ask func(ctx context.Context, node, prompt string) (string, error)) (Planner, error) {
+	if cfg.TimeoutSec < 0 || cfg.TimeoutSec >= int(maxPlannerTimeout/time.Second) {
+		return nil, fmt.Errorf("planner timeout_sec must be between 0 and %d", int(maxPlannerTimeout/time.Second)-1)
+	}
+	switch cfg.Type {
+	case "":
+		return nil, nil
+	case "rules":
+		p, err := newRulePlanner(cfg.Rules)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	case "llm":
+		if cfg.Node == "" {
+			return nil, fmt.Errorf("the llm planner needs a node")
+		}
+		return &llmPlanner{node: cfg.Node, ask: ask}, nil
+	}
+	return nil, fmt.Errorf("unknown planner type %q (want rules or llm)", cfg.Type)
+}
+
+// rulePlanner plans with the first rule whose pattern matches the
+// message; other messages are single steps.
+type rulePlanner struct {
+	rules []plannerRule
+}
+
+type plannerRule struct {
+	re    *regexp.Regexp
+	steps []types.PlanStepSpec // validated, with IDs assigned
+}
+
+func newRulePlanner(rules []config.PlannerRule) (*rulePlanner, error) {
+	p := &rulePlanner{}
+	for i, r := range rules {
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("planner rule %d: invalid match: %w", i+1, err)
+		}
+		req := &types.PlanRequest{}
+		for _, st := range r.Steps {
+			req.Steps = append(req.Steps, types.PlanStepSpec{
+				ID:         st.ID,
+				Skill:      st.Skill,
+				Prompt:     st.Prompt,
+				DependsOn:  st.DependsOn,
+				TimeoutSec: st.TimeoutSec,
+			})
+		}
+		if err := validatePlan(req); err != nil {
+			return nil, fmt.Errorf("planner rule %d: %w", i+1, err)
+		}
+		p.rules = append(p.rules, plannerRule{re: re, steps: req.Steps})
+	}
+	return p, nil
+}
+
+func (p *rulePlanner) Plan(ctx context.Context, msg *types.Message, inv *SkillInventory) (*types.PlanRequest, error) {
+	for _, r := range p.rules {
+		if !r.re.MatchString(msg.Content) {
+			continue
+		}
+		req := &types.PlanRequest{UserPrompt: msg.Content}
+		for _, st := range r.steps {
+			st.DependsOn = slices.Clone(st.DependsOn)
+			req.Steps = append(req.Steps, st)
+		}
+		return req, nil
+	}
+	return nil, nil
+}
+
+// llmPlanner asks a model running on a mesh node to decompose the
+// message, and parses the JSON plan in its reply.
+type llmPlanner struct {
+	node string // ID or name
+	ask  func(ctx context.Context, node, prompt string) (string, error)
+}
+
+// llmPlan is the reply the llm planner asks for. depends_on_prev is
+// accepted as a shorthand for depending on the step before.
+type llmPlan struct {
+	Steps []struct {
+		ID            string   `json:"id"`
+		Skill         string   `json:"skill"`
+		Prompt        string   `json:"prompt"`
+		DependsOn     []string `json:"depends_on"`
+		DependsOnPrev bool     `json:"depends_on_prev"`
+		TimeoutSec    int      `json:"timeout_sec"`
+	} `json:"steps"`
+}
+
+func (p *llmPlanner) Plan(ctx context.Context, msg *types.Message, inv *SkillInventory) (*types.PlanRequest, error) {
+	reply, err := p.ask(ctx, p.node, plannerPrompt(msg.Content, inv))
+	if err != nil {
+		return nil, fmt.Errorf("asking node %s: %w", p.node, err)
+	}
+	return parseLLMPlan(msg.Content, reply)
+}
+
+// plannerPrompt builds the prompt sent to the llm planner's node.
+func plannerPrompt(content string, inv *SkillInventory) string {
+	var b strings.Builder
+	b.WriteString(`You are a task planner for a mesh of machines that each contribute different
+skills to one AI assistant.
+
+Your job: decompose the user's request into steps, each handled by a node
+with one of the skills available in the mesh.
+
+Available skills across the mesh:
+`)
+	for _, name := range inv.skillNames() {
+		var nodes []string
+		for _, n := range inv.Skills[name] {
+			nodes = append(nodes, n.Name)
+		}
+		fmt.Fprintf(&b, "- %q on nodes: %s\n", name, strings.Join(nodes, ", "))
+	}
+	b.WriteString(`
+Rules:
+1. If the task can be handled by a single skill on one node, return exactly one step.
+2. Only use skills listed above. Do not invent skills.
+3. Give each step a short id. List in depends_on the ids of the steps whose
+   output a step needs; that output is appended to its prompt. Steps that do
+   not depend on each other run in parallel.
+4. Keep the plan as short as possible. Fewer steps = better.
+5. Return ONLY valid JSON matching this schema:
+
+{"steps": [{"id": "...", "skill": "...", "prompt": "...", "depends_on": ["..."], "timeout_sec": 120}]}
+
+User request:
+`)
+	b.WriteString(content)
+	return b.String()
+}
+
+// parseLLMPlan parses the plan in a model's reply, which may surround the
+// JSON with prose or a code fence. The prompts are taken literally; each
+// step's prompt gets the output of the steps it depends on appended.
+func parseLLMPlan(userPrompt, reply string) (*types.PlanRequest, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON plan in the reply")
+	}
+	var plan llmPlan
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &plan); err != nil {
+		return nil, fmt.Errorf("parsing plan: %w", err)
+	}
+	if len(plan.Steps) <= 1 {
+		return nil, nil
+	}
+
+	req := &types.PlanRequest{UserPrompt: userPrompt}
+	for i, st := range plan.Steps {
+		spec := types.PlanStepSpec{
+			ID:         st.ID,
+			Skill:      st.Skill,
+			DependsOn:  st.DependsOn,
+			TimeoutSec: st.TimeoutSec,
+		}
+		if spec.ID == "" {
+			spec.ID = fmt.Sprintf("step%d", i+1)
+		}
+		if st.DependsOnPrev && i > 0 && !slices.Contains(spec.DependsOn, req.Steps[i-1].ID) {
+			spec.DependsOn = append(spec.DependsOn, req.Steps[i-1].ID)
+		}
+		var prompt strings.Builder
+		prompt.WriteString(strings.ReplaceAll(st.Prompt, "{{", `{{"{{"}}`))
+		for _, dep := range spec.DependsOn {
+			if planStepIDRe.MatchString(dep) {
+				fmt.Fprintf(&prompt, "\n\n--- Output of step %s ---\n{{.Steps.%s.Response}}", dep, dep)
+			}
+		}
+		spec.Prompt = prompt.String()
+		req.Steps = append(req.Steps, spec)
+	}
+	return req, nil
+}
+
+// skillInventory returns the nodes eligible for auto-routing and the
+// skills they offer.
+func (s *Server) skillInventory() *SkillInventory {
+	wildcard := true
+	inv := &SkillInventory{
+		Nodes:  s.router.MatchingNodes(&types.RoutingRule{Match: types.MatchCriteria{Wildcard: &wildcard}}),
+		Skills: make(map[string][]*types.Node),
+	}
+	for _, n := range inv.Nodes {
+		for _, skill := range slices.Concat(n.Capabilities.Skills, n.Capabilities.Tags) {
+			if !slices.Contains(inv.Skills[skill], n) {
+				inv.Skills[skill] = append(inv.Skills[skill], n)
+			}
+		}
+	}
+	return inv
+}
+
+// askPlannerNode sends the llm planner's prompt to its node, given by ID
+// or name, and returns the reply.
+func (s *Server) askPlannerNode(ctx context.Context, ref, prompt string) (string, error) {
+	node := s.registry.Get(ref)
+	if node == nil {
+		for _, n := range s.registry.List() {
+			if n.Name == ref {
+				node = n
+				break
+			}
+		}
+	}
+	if node == nil {
+		return "", fmt.Errorf("node not found")
+	}
+	msgID, err := generateID()
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.forward(ctx, node, &types.Message{
+		ID:         msgID,
+		Content:    prompt,
+		Source:     plannerSource,
+		TargetNode: node.ID,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.GatewayError {
+		return "", fmt.Errorf("node %s replied with a gateway error", node.ID)
+	}
+	return resp.Response, nil
+}
+
+// planMessage asks the planner to decompose msg and, if it needs several
+// steps, submits the plan. It returns nil if the message should be routed
+// as a single step, including when planning fails or the plan is invalid.
+// The planner's own messages, and messages in a mesh with fewer than two
+// skills to split work between, are not planned.
+func (s *Server) planMessage(ctx context.Context, msg *types.Message) *types.TaskPlan {
+	if msg.Source == plannerSource {
+		return nil
+	}
+	inv := s.skillInventory()
+	if len(inv.Skills) < 2 {
+		return nil
+	}
+
+	timeout := defaultPlannerTimeout
+	if s.cfg.Planner.TimeoutSec > 0 {
+		timeout = time.Duration(s.cfg.Planner.TimeoutSec) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := s.planner.Plan(ctx, msg, inv)
+	if err == nil && (req == nil || len(req.Steps) <= 1) {
+		return nil
+	}
+	if err == nil {
+		err = s.checkPlan(req)
+	}
+	if err == nil {
+		var p *types.TaskPlan
+		if p, err = s.plans.submit(req); err == nil {
+			log.Printf("message %s planned as %s (%d steps)", msg.ID, p.ID, len(p.Steps))
+			return p
+		}
+	}
+	log.Printf("WARN: planning message %s failed, routing it as a single step: %v", msg.ID, err)
+	return nil
+}
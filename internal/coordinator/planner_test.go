@@ -0,0 +1,133 @@
+package coordinator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SallyKAN/claw-mesh/internal/config"
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+func TestPlanner(t *testing.T) {
+	srv := newTestServer()
+	srv.registry.Add(&types.Node{ID: "mac", Name: "mac", Status: types.NodeStatusOnline, Capabilities: types.Capabilities{Skills: []string{"xcode"}}})
+	srv.registry.Add(&types.Node{ID: "gpu", Name: "gpu", Status: types.NodeStatusOnline, Capabilities: types.Capabilities{Skills: []string{"python"}, Tags: []string{"gpu"}}})
+	srv.router = NewRouter(srv.registry)
+	var mu sync.Mutex
+	prompts := map[string]string{}
+	srv.plans = newPlanExecutor(func(ctx context.Context, planID string, st *types.PlanStepSpec, content string) (*types.MessageResponse, error) {
+		mu.Lock()
+		prompts[planID+"/"+st.ID] = content
+		mu.Unlock()
+		return &types.MessageResponse{NodeID: "gpu", Response: "output of " + st.ID}, nil
+	}, nil, nil)
+	defer srv.plans.stop()
+
+	// Asking for a plan with no planner configured is refused rather than
+	// quietly routed as usual.
+	body, _ := json.Marshal(map[string]string{"content": "Train the model, then ship it"})
+	rr := httptest.NewRecorder()
+	srv.handleRouteAuto(rr, httptest.NewRequest(http.MethodPost, "/api/v1/route?plan=true", bytes.NewReader(body)))
+	if rr.Code != http.StatusNotImplemented || !strings.Contains(rr.Body.String(), "planning is unavailable") {
+		t.Fatalf("expected 501 without a planner, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// The rules planner turns a matching message into its steps.
+	p, err := newPlanner(config.PlannerConfig{Type: "rules", Rules: []config.PlannerRule{{
+		Match: `(?i)train .* then ship`,
+		Steps: []config.PlannerStep{
+			{ID: "train", Skill: "python", Prompt: "{{.Prompt}}"},
+			{ID: "ship", Skill: "xcode", Prompt: "Integrate {{.Steps.train.Response}}", DependsOn: []string{"train"}},
+		},
+	}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.planner = p
+	srv.forwarder = NewForwarder()
+	srv.jobs = newJobTable(nil)
+
+	// Without ?plan=true the message is routed as usual, not planned.
+	rr = httptest.NewRecorder()
+	srv.handleRouteAuto(rr, httptest.NewRequest(http.MethodPost, "/api/v1/route", bytes.NewReader(body)))
+	if rr.Code == http.StatusAccepted || len(srv.plans.list()) != 0 {
+		t.Fatalf("expected no plan without ?plan=true, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// With it, the reply is a job that ends with the plan's ID.
+	rr = httptest.NewRecorder()
+	srv.handleRouteAuto(rr, httptest.NewRequest(http.MethodPost, "/api/v1/route?plan=true", bytes.NewReader(body)))
+	var job types.Job
+	json.Unmarshal(rr.Body.Bytes(), &job)
+	if rr.Code != http.StatusAccepted || job.ID == "" {
+		t.Fatalf("expected a job, got %d: %s", rr.Code, rr.Body.String())
+	}
+	_, done, _ := srv.jobs.get(job.ID)
+	<-done
+	job, _, _ = srv.jobs.get(job.ID)
+	if job.Status != types.JobSucceeded || job.PlanID == "" || job.Result != nil {
+		t.Fatalf("expected the job to end with a plan, got %+v", job)
+	}
+	if plan := waitPlan(t, srv.plans, job.PlanID); plan.Status != types.PlanCompleted || len(plan.Steps) != 2 || plan.FinalResponse != "output of ship" {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+
+	// The llm planner's reply may be fenced and use depends_on_prev; its
+	// prompts are taken literally.
+	reply := "Here is the plan:\n```json\n" + `{"steps": [
+		{"skill": "gpu", "prompt": "Render {{x}}"},
+		{"skill": "xcode", "prompt": "Add it to the app", "depends_on_prev": true}
+	]}` + "\n```"
+	var asked string
+	srv.planner = &llmPlanner{node: "gpu", ask: func(ctx context.Context, node, prompt string) (string, error) {
+		asked = prompt
+		return reply, nil
+	}}
+	plan := srv.planMessage(context.Background(), &types.Message{ID: "m1", Content: "make an icon"})
+	if plan == nil {
+		t.Fatal("expected the llm reply to become a plan")
+	}
+	if !strings.Contains(asked, `- "xcode" on nodes: mac`) || !strings.HasSuffix(asked, "make an icon") {
+		t.Fatalf("unexpected planner prompt:\n%s", asked)
+	}
+	waitPlan(t, srv.plans, plan.ID)
+	if got := prompts[plan.ID+"/step1"]; got != "Render {{x}}" {
+		t.Fatalf("step1 prompt = %q", got)
+	}
+	if got, want := prompts[plan.ID+"/step2"], "Add it to the app\n\n--- Output of step step1 ---\noutput of step1"; got != want {
+		t.Fatalf("step2 prompt = %q, want %q", got, want)
+	}
+
+	// A plan using a skill no node has is rejected.
+	reply = `{"steps": [{"skill": "python", "prompt": "a"}, {"skill": "blender", "prompt": "b"}]}`
+	if plan := srv.planMessage(context.Background(), &types.Message{ID: "m2", Content: "3d"}); plan != nil {
+		t.Fatalf("expected a plan with an unknown skill to be rejected, got %+v", plan)
+	}
+	// So is a single step, which is routed as usual.
+	reply = `{"steps": [{"skill": "python", "prompt": "a"}]}`
+	if plan := srv.planMessage(context.Background(), &types.Message{ID: "m3", Content: "hi"}); plan != nil {
+		t.Fatal("expected a single step not to become a plan")
+	}
+
+	// The planner's own messages, and meshes with a single skill, are not
+	// planned at all.
+	asked = ""
+	srv.planMessage(context.Background(), &types.Message{ID: "m4", Content: "hi", Source: plannerSource})
+	srv.registry.Remove("mac")
+	srv.registry.Remove("gpu")
+	srv.registry.Add(&types.Node{ID: "solo", Name: "solo", Status: types.NodeStatusOnline, Capabilities: types.Capabilities{Skills: []string{"python"}}})
+	srv.planMessage(context.Background(), &types.Message{ID: "m5", Content: "hi"})
+	if asked != "" {
+		t.Fatalf("expected the planner not to be asked, got prompt:\n%s", asked)
+	}
+
+	if _, err := newPlanner(config.PlannerConfig{Type: "llm", Node: "gpu", TimeoutSec: 300}, nil); err == nil {
+		t.Fatal("expected a planner timeout of 5 minutes to be rejected")
+	}
+}
@@ -0,0 +1,699 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+const (
+	maxPlanSteps = 50
+	// maxPlans caps how many plans are kept; the oldest finished plans are
+	// dropped first.
+	maxPlans = 500
+
+	defaultPlanTimeout = 600 * time.Second
+	maxPlanTimeout     = 24 * time.Hour
+	defaultStepTimeout = 120 * time.Second
+)
+
+// planStepIDRe keeps step IDs usable as template fields: {{.Steps.id}}.
+var planStepIDRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+var errPlanNotFound = errors.New("plan not found")
+
+// PlanStore provides persistent storage for task plans and their
+// progress: a snapshot of the plans in plans.json plus a journal of the
+// plans changed since, so that a step finishing costs one appended record.
+type PlanStore struct {
+	*journalStore[types.TaskPlan]
+}
+
+// NewPlanStore creates a plan store backed by the given file path.
+// The parent directory is created if it doesn't exist.
+func NewPlanStore(path string) (*PlanStore, error) {
+	js, err := newJournalStore(path, "plan", func(p *types.TaskPlan) string { return p.ID })
+	if err != nil {
+		return nil, err
+	}
+	return &PlanStore{js}, nil
+}
+
+// planVars are the fields a step's prompt template can use.
+type planVars struct {
+	Prompt string              // the plan's user prompt
+	Steps  map[string]stepVars // completed steps, by ID
+}
+
+type stepVars struct {
+	Response string
+	Node     string
+}
+
+// stepRule returns the routing rule selecting a step's candidate nodes,
+// or nil if the step has no node criteria.
+func stepRule(st *types.PlanStepSpec) *types.RoutingRule {
+	if st.Skill == "" && st.Match == nil && st.Expr == "" {
+		return nil
+	}
+	rule := &types.RoutingRule{Expr: st.Expr}
+	if st.Match != nil {
+		rule.Match = *st.Match
+	}
+	if st.Skill != "" {
+		rule.Match.RequiresSkill = st.Skill
+	}
+	return rule
+}
+
+// validatePlan checks a submitted plan and assigns IDs to steps without
+// one ("step1", "step2", ...). The steps must form a DAG, and prompts may
+// only refer to steps they (transitively) depend on.
+func validatePlan(req *types.PlanRequest) error {
+	if len(req.Steps) == 0 || len(req.Steps) > maxPlanSteps {
+		return fmt.Errorf("a plan must have between 1 and %d steps", maxPlanSteps)
+	}
+	if req.TimeoutSec < 0 || req.TimeoutSec > int(maxPlanTimeout/time.Second) {
+		return fmt.Errorf("timeout_sec must be between 0 and %d", int(maxPlanTimeout.Seconds()))
+	}
+
+	index := make(map[string]int, len(req.Steps))
+	for i := range req.Steps {
+		st := &req.Steps[i]
+		if st.ID == "" {
+			st.ID = fmt.Sprintf("step%d", i+1)
+		}
+		if !planStepIDRe.MatchString(st.ID) {
+			return fmt.Errorf("step %d: invalid id %q (letters, digits and _ only)", i+1, st.ID)
+		}
+		if _, dup := index[st.ID]; dup {
+			return fmt.Errorf("step %d: duplicate id %q", i+1, st.ID)
+		}
+		index[st.ID] = i
+	}
+
+	for i := range req.Steps {
+		st := &req.Steps[i]
+		if err := validatePlanStep(st, index); err != nil {
+			return fmt.Errorf("step %q: %w", st.ID, err)
+		}
+	}
+
+	order, err := planOrder(req.Steps, index)
+	if err != nil {
+		return err
+	}
+
+	// Render each prompt with placeholders for its ancestors only, so a
+	// reference to any other step fails now rather than mid-plan.
+	ancestors := make([]map[string]bool, len(req.Steps))
+	for _, i := range order {
+		anc := make(map[string]bool)
+		for _, dep := range req.Steps[i].DependsOn {
+			anc[dep] = true
+			for a := range ancestors[index[dep]] {
+				anc[a] = true
+			}
+		}
+		ancestors[i] = anc
+		vars := planVars{Prompt: req.UserPrompt, Steps: make(map[string]stepVars, len(anc))}
+		for a := range anc {
+			vars.Steps[a] = stepVars{}
+		}
+		tmpl, err := parseStepPrompt(&req.Steps[i])
+		if err != nil {
+			return fmt.Errorf("step %q: invalid prompt template: %w", req.Steps[i].ID, err)
+		}
+		if err := tmpl.Execute(io.Discard, vars); err != nil {
+			return fmt.Errorf("step %q: prompt may only use steps it depends on: %w", req.Steps[i].ID, err)
+		}
+	}
+	return nil
+}
+
+func validatePlanStep(st *types.PlanStepSpec, index map[string]int) error {
+	if strings.TrimSpace(st.Prompt) == "" {
+		return fmt.Errorf("prompt is required")
+	}
+	if st.TimeoutSec < 0 || st.TimeoutSec > int(maxPlanTimeout/time.Second) {
+		return fmt.Errorf("timeout_sec must be between 0 and %d", int(maxPlanTimeout.Seconds()))
+	}
+	if st.NodeID != "" && stepRule(st) != nil {
+		return fmt.Errorf("set node_id or skill/match/expr, not both")
+	}
+	if st.Match != nil {
+		if hasMessagePredicates(st.Match) {
+			return fmt.Errorf("message predicates are not supported in plan steps")
+		}
+		if st.Match.LabelSelector != "" {
+			if _, err := parseLabelSelector(st.Match.LabelSelector); err != nil {
+				return fmt.Errorf("invalid label_selector: %w", err)
+			}
+		}
+	}
+	if st.Expr != "" {
+		if _, err := parseExpr(st.Expr); err != nil {
+			return fmt.Errorf("invalid expr: %w", err)
+		}
+	}
+	for _, dep := range st.DependsOn {
+		if dep == st.ID {
+			return fmt.Errorf("depends on itself")
+		}
+		if _, ok := index[dep]; !ok {
+			return fmt.Errorf("depends on unknown step %q", dep)
+		}
+	}
+	return nil
+}
+
+// planOrder sorts steps topologically, rejecting cycles.
+func planOrder(steps []types.PlanStepSpec, index map[string]int) ([]int, error) {
+	pending := make([]int, len(steps))
+	dependents := make([][]int, len(steps))
+	for i, st := range steps {
+		pending[i] = len(st.DependsOn)
+		for _, dep := range st.DependsOn {
+			dependents[index[dep]] = append(dependents[index[dep]], i)
+		}
+	}
+	var order, ready []int
+	for i := range steps {
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		order = append(order, i)
+		for _, d := range dependents[i] {
+			if pending[d]--; pending[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+	if len(order) != len(steps) {
+		return nil, fmt.Errorf("steps depend on each other in a cycle")
+	}
+	return order, nil
+}
+
+func parseStepPrompt(st *types.PlanStepSpec) (*template.Template, error) {
+	return template.New(st.ID).Option("missingkey=error").Parse(st.Prompt)
+}
+
+// stepRunner sends one step's message and returns the node's response.
+type stepRunner func(ctx context.Context, planID string, st *types.PlanStepSpec, content string) (*types.MessageResponse, error)
+
+// planExecutor runs task plans. Every step state change is journaled, so
+// a plan interrupted by a restart resumes where it left off; steps that
+// were running are run again.
+type planExecutor struct {
+	store  *PlanStore
+	events *EventBus
+	run    stepRunner
+
+	mu    sync.Mutex
+	plans map[string]*types.TaskPlan
+	order []string // IDs, oldest first
+
+	ctx       context.Context // cancelled by stop
+	cancel    context.CancelFunc
+	startOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// newPlanExecutor creates an executor that runs steps with run.
+// If store is non-nil, plans are loaded from and persisted to disk.
+func newPlanExecutor(run stepRunner, events *EventBus, store *PlanStore) *planExecutor {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &planExecutor{
+		store:  store,
+		events: events,
+		run:    run,
+		plans:  make(map[string]*types.TaskPlan),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	if store == nil {
+		return e
+	}
+	plans, err := store.Load()
+	if err != nil {
+		log.Printf("WARN: failed to load persisted plans: %v", err)
+	}
+	for _, p := range plans {
+		for i := range p.Steps {
+			if p.Steps[i].Status == types.StepRunning {
+				p.Steps[i].Status = types.StepPending
+				p.Steps[i].StartedAt = nil
+			}
+		}
+		e.plans[p.ID] = p
+		e.order = append(e.order, p.ID)
+	}
+	if len(plans) > 0 {
+		log.Printf("loaded %d persisted plans", len(plans))
+	}
+	if err == nil {
+		// Start from a fresh snapshot, dropping any torn journal tail.
+		e.compactLocked()
+	}
+	return e
+}
+
+// start resumes unfinished plans. Safe to call multiple times.
+func (e *planExecutor) start() {
+	e.startOnce.Do(func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		for _, id := range e.order {
+			if p := e.plans[id]; p.Status == types.PlanPending || p.Status == types.PlanRunning {
+				log.Printf("resuming plan %s", id)
+				e.wg.Add(1)
+				go e.execute(p)
+			}
+		}
+	})
+}
+
+// stop interrupts running plans, leaving them to resume on the next
+// start, and waits for their steps to return.
+func (e *planExecutor) stop() {
+	e.cancel()
+	e.wg.Wait()
+}
+
+// submit creates a plan from a validated request and starts it.
+func (e *planExecutor) submit(req *types.PlanRequest) (*types.TaskPlan, error) {
+	id, err := generatePrefixedID("plan")
+	if err != nil {
+		return nil, err
+	}
+	p := &types.TaskPlan{
+		ID:         id,
+		UserPrompt: req.UserPrompt,
+		Status:     types.PlanRunning,
+		TimeoutSec: req.TimeoutSec,
+		CreatedAt:  time.Now(),
+	}
+	if p.TimeoutSec == 0 {
+		p.TimeoutSec = int(defaultPlanTimeout / time.Second)
+	}
+	for _, spec := range req.Steps {
+		p.Steps = append(p.Steps, types.PlanStep{PlanStepSpec: spec, Status: types.StepPending})
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	pruned := e.pruneLocked()
+	e.plans[id] = p
+	e.order = append(e.order, id)
+	e.persistLocked([]*types.TaskPlan{p}, pruned)
+	e.wg.Add(1)
+	go e.execute(p)
+	return copyPlan(p), nil
+}
+
+// stepDone is the outcome of a step, sent back to execute.
+type stepDone struct {
+	i    int
+	resp *types.MessageResponse
+	err  error
+}
+
+// execute runs p until every step has finished or one has failed.
+func (e *planExecutor) execute(p *types.TaskPlan) {
+	defer e.wg.Done()
+	ctx, cancel := context.WithDeadline(e.ctx, p.CreatedAt.Add(time.Duration(p.TimeoutSec)*time.Second))
+	defer cancel()
+
+	done := make(chan stepDone)
+	running := 0
+	for {
+		e.mu.Lock()
+		starts := e.startReadyLocked(ctx, p)
+		if p.Status == types.PlanFailed {
+			// Stop the other steps; their outcome no longer matters.
+			cancel()
+		}
+		if len(starts) > 0 || p.Status == types.PlanFailed {
+			e.persistLocked([]*types.TaskPlan{p}, nil)
+		}
+		e.mu.Unlock()
+
+		for _, st := range starts {
+			running++
+			go func() {
+				timeout := defaultStepTimeout
+				if st.spec.TimeoutSec > 0 {
+					timeout = time.Duration(st.spec.TimeoutSec) * time.Second
+				}
+				sctx, scancel := context.WithTimeout(ctx, timeout)
+				defer scancel()
+				resp, err := e.run(sctx, p.ID, &st.spec, st.content)
+				done <- stepDone{st.i, resp, err}
+			}()
+		}
+		if running == 0 {
+			break
+		}
+
+		d := <-done
+		running--
+		e.mu.Lock()
+		ev, ok := e.recordLocked(ctx, p, d)
+		if p.Status == types.PlanFailed {
+			cancel()
+		}
+		e.persistLocked([]*types.TaskPlan{p}, nil)
+		e.mu.Unlock()
+		if ok {
+			e.events.Publish(ev)
+		}
+	}
+
+	if e.ctx.Err() != nil {
+		// Shutting down; the plan resumes on the next start.
+		return
+	}
+	e.mu.Lock()
+	ev := e.finishLocked(ctx, p)
+	e.persistLocked([]*types.TaskPlan{p}, nil)
+	e.mu.Unlock()
+	e.events.Publish(ev)
+}
+
+// stepStart is a step about to be sent, with its rendered prompt.
+type stepStart struct {
+	i       int
+	spec    types.PlanStepSpec
+	content string
+}
+
+// startReadyLocked marks the pending steps whose dependencies have all
+// completed as running and returns them. Nothing starts once the plan has
+// failed or ctx is done.
+func (e *planExecutor) startReadyLocked(ctx context.Context, p *types.TaskPlan) []stepStart {
+	if p.Status == types.PlanFailed || ctx.Err() != nil {
+		return nil
+	}
+	p.Status = types.PlanRunning
+	vars := planVars{Prompt: p.UserPrompt, Steps: make(map[string]stepVars)}
+	status := make(map[string]types.StepStatus, len(p.Steps))
+	for _, st := range p.Steps {
+		status[st.ID] = st.Status
+		if st.Status == types.StepCompleted {
+			vars.Steps[st.ID] = stepVars{Response: st.Response, Node: st.NodeUsed}
+		}
+	}
+
+	var starts []stepStart
+	for i := range p.Steps {
+		st := &p.Steps[i]
+		if st.Status != types.StepPending {
+			continue
+		}
+		ready := true
+		for _, dep := range st.DependsOn {
+			ready = ready && status[dep] == types.StepCompleted
+		}
+		if !ready {
+			continue
+		}
+		now := time.Now()
+		st.StartedAt = &now
+		var content strings.Builder
+		tmpl, err := parseStepPrompt(&st.PlanStepSpec)
+		if err == nil {
+			err = tmpl.Execute(&content, vars)
+		}
+		if err != nil {
+			st.Status = types.StepFailed
+			st.Error = fmt.Sprintf("rendering prompt: %v", err)
+			st.FinishedAt = &now
+			p.Status = types.PlanFailed
+			p.Error = fmt.Sprintf("step %q failed: %s", st.ID, st.Error)
+			return starts
+		}
+		st.Status = types.StepRunning
+		starts = append(starts, stepStart{i: i, spec: st.PlanStepSpec, content: content.String()})
+	}
+	return starts
+}
+
+// recordLocked records a step's outcome and returns its event. A failed
+// step fails the plan. A step cut short by shutdown is put back to
+// pending and has no event.
+func (e *planExecutor) recordLocked(ctx context.Context, p *types.TaskPlan, d stepDone) (types.Event, bool) {
+	st := &p.Steps[d.i]
+	err := d.err
+	if err != nil && e.ctx.Err() != nil {
+		st.Status = types.StepPending
+		st.StartedAt = nil
+		return types.Event{}, false
+	}
+
+	now := time.Now()
+	st.FinishedAt = &now
+	if err == nil && d.resp.GatewayError {
+		st.NodeUsed = d.resp.NodeID
+		err = fmt.Errorf("node %s replied with a gateway error", d.resp.NodeID)
+	}
+	if err != nil {
+		st.Status = types.StepFailed
+		st.Error = err.Error()
+		switch {
+		case p.Status == types.PlanFailed:
+			// Cancelled because another step failed.
+			st.Error = "cancelled"
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			st.Error = "cancelled"
+			p.Status = types.PlanFailed
+			p.Error = fmt.Sprintf("plan timed out after %ds", p.TimeoutSec)
+		default:
+			p.Status = types.PlanFailed
+			p.Error = fmt.Sprintf("step %q failed: %s", st.ID, st.Error)
+		}
+		log.Printf("plan %s step %s failed: %s", p.ID, st.ID, st.Error)
+		return types.Event{
+			Type:   types.EventPlanStepFailed,
+			NodeID: st.NodeUsed,
+			Data:   map[string]any{"plan_id": p.ID, "step_id": st.ID, "error": st.Error},
+		}, true
+	}
+
+	st.Status = types.StepCompleted
+	st.Response = d.resp.Response
+	st.NodeUsed = d.resp.NodeID
+	log.Printf("plan %s step %s completed on node %s", p.ID, st.ID, st.NodeUsed)
+	return types.Event{
+		Type:   types.EventPlanStepCompleted,
+		NodeID: st.NodeUsed,
+		Data:   map[string]any{"plan_id": p.ID, "step_id": st.ID},
+	}, true
+}
+
+// finishLocked settles a plan none of whose steps are running and
+// returns its event.
+func (e *planExecutor) finishLocked(ctx context.Context, p *types.TaskPlan) types.Event {
+	now := time.Now()
+	p.CompletedAt = &now
+	for i := range p.Steps {
+		if p.Steps[i].Status == types.StepPending {
+			p.Steps[i].Status = types.StepSkipped
+			if p.Status != types.PlanFailed && ctx.Err() != nil {
+				p.Status = types.PlanFailed
+				p.Error = fmt.Sprintf("plan timed out after %ds", p.TimeoutSec)
+			}
+		}
+	}
+	if p.Status == types.PlanFailed {
+		log.Printf("plan %s failed: %s", p.ID, p.Error)
+		return types.Event{Type: types.EventPlanFailed, Data: map[string]any{"plan_id": p.ID, "error": p.Error}}
+	}
+
+	// The final response is that of the steps nothing depends on.
+	needed := make(map[string]bool)
+	for _, st := range p.Steps {
+		for _, dep := range st.DependsOn {
+			needed[dep] = true
+		}
+	}
+	var final []string
+	for _, st := range p.Steps {
+		if !needed[st.ID] {
+			final = append(final, st.Response)
+		}
+	}
+	p.Status = types.PlanCompleted
+	p.FinalResponse = strings.Join(final, "\n\n")
+	log.Printf("plan %s completed (%d steps)", p.ID, len(p.Steps))
+	return types.Event{Type: types.EventPlanCompleted, Data: map[string]any{"plan_id": p.ID, "steps": len(p.Steps)}}
+}
+
+// pruneLocked drops the oldest finished plans to stay under maxPlans and
+// returns their IDs.
+func (e *planExecutor) pruneLocked() []string {
+	excess := len(e.order) - maxPlans + 1
+	var removed []string
+	e.order = slices.DeleteFunc(e.order, func(id string) bool {
+		p := e.plans[id]
+		if excess <= 0 || p.CompletedAt == nil {
+			return false
+		}
+		delete(e.plans, id)
+		removed = append(removed, id)
+		excess--
+		return true
+	})
+	return removed
+}
+
+// persistLocked journals the new state of the changed plans and the
+// removal of the removed ones, compacting the store as the journal grows.
+func (e *planExecutor) persistLocked(changed []*types.TaskPlan, removed []string) {
+	if e.store == nil {
+		return
+	}
+	e.store.persist(changed, removed, len(e.order), e.allLocked)
+}
+
+// compactLocked writes a snapshot of every plan, emptying the journal.
+func (e *planExecutor) compactLocked() {
+	e.store.compact(e.allLocked())
+}
+
+// allLocked returns every plan, oldest first.
+func (e *planExecutor) allLocked() []*types.TaskPlan {
+	plans := make([]*types.TaskPlan, len(e.order))
+	for i, id := range e.order {
+		plans[i] = e.plans[id]
+	}
+	return plans
+}
+
+// get returns a copy of a plan, or nil if not found.
+func (e *planExecutor) get(id string) *types.TaskPlan {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	p := e.plans[id]
+	if p == nil {
+		return nil
+	}
+	return copyPlan(p)
+}
+
+// list returns copies of all plans, newest first.
+func (e *planExecutor) list() []*types.TaskPlan {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := make([]*types.TaskPlan, 0, len(e.order))
+	for i := len(e.order) - 1; i >= 0; i-- {
+		out = append(out, copyPlan(e.plans[e.order[i]]))
+	}
+	return out
+}
+
+func copyPlan(p *types.TaskPlan) *types.TaskPlan {
+	cp := *p
+	cp.Steps = slices.Clone(p.Steps)
+	return &cp
+}
+
+// runPlanStep sends a step's message: to its node, to the least busy of
+// the nodes matching its criteria (failing over to the others), or as an
+// auto-routed message.
+func (s *Server) runPlanStep(ctx context.Context, planID string, st *types.PlanStepSpec, content string) (*types.MessageResponse, error) {
+	msgID, err := generateID()
+	if err != nil {
+		return nil, err
+	}
+	msg := &types.Message{
+		ID:         msgID,
+		Content:    content,
+		Source:     "plan:" + planID,
+		TargetNode: st.NodeID,
+		CreatedAt:  time.Now(),
+	}
+	rule := stepRule(st)
+	if rule == nil {
+		return s.deliver(ctx, msg)
+	}
+	matching := s.router.MatchingNodes(rule)
+	if len(matching) == 0 {
+		return nil, fmt.Errorf("no online node matches the step")
+	}
+	var nodes []*types.Node
+	for len(nodes) < defaultMaxNodes && len(matching) > 0 {
+		n := leastBusy(matching)
+		nodes = append(nodes, n)
+		matching = slices.DeleteFunc(matching, func(m *types.Node) bool { return m == n })
+	}
+	resp, _, err := s.forwardWithFailover(ctx, nodes, msg)
+	return resp, err
+}
+
+// checkPlan validates a plan before it is submitted: besides validatePlan,
+// its nodes must exist and some available node must have each skill.
+func (s *Server) checkPlan(req *types.PlanRequest) error {
+	if err := validatePlan(req); err != nil {
+		return err
+	}
+	for _, st := range req.Steps {
+		if st.NodeID != "" && s.registry.Get(st.NodeID) == nil {
+			return fmt.Errorf("step %q: node %q not found", st.ID, st.NodeID)
+		}
+	}
+	return checkPlanSkills(req, s.skillInventory())
+}
+
+// handleSubmitPlan handles POST /api/v1/plans. The plan runs in the
+// background; the reply is 202 with the plan, whose progress is fetched
+// from /api/v1/plans/{id}.
+func (s *Server) handleSubmitPlan(w http.ResponseWriter, r *http.Request) {
+	var req types.PlanRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if err := s.checkPlan(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	p, err := s.plans.submit(&req)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to generate plan ID"})
+		return
+	}
+	log.Printf("plan %s submitted (%d steps)", p.ID, len(p.Steps))
+	w.Header().Set("Location", "/api/v1/plans/"+p.ID)
+	writeJSON(w, http.StatusAccepted, p)
+}
+
+// handleListPlans handles GET /api/v1/plans.
+func (s *Server) handleListPlans(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.plans.list())
+}
+
+// handleGetPlan handles GET /api/v1/plans/{id}.
+func (s *Server) handleGetPlan(w http.ResponseWriter, r *http.Request) {
+	p := s.plans.get(r.PathValue("id"))
+	if p == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": errPlanNotFound.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
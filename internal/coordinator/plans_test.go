@@ -0,0 +1,134 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+func waitPlan(t *testing.T, e *planExecutor, id string) *types.TaskPlan {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if p := e.get(id); p.Status == types.PlanCompleted || p.Status == types.PlanFailed {
+			return p
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("plan %s did not finish", id)
+	return nil
+}
+
+func TestPlanExecutor(t *testing.T) {
+	if err := validatePlan(&types.PlanRequest{Steps: []types.PlanStepSpec{
+		{ID: "a", Prompt: "x", DependsOn: []string{"b"}},
+		{ID: "b", Prompt: "y", DependsOn: []string{"a"}},
+	}}); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected a cycle to be rejected, got %v", err)
+	}
+	if err := validatePlan(&types.PlanRequest{Steps: []types.PlanStepSpec{
+		{ID: "a", Prompt: "x"},
+		{ID: "b", Prompt: "{{.Steps.a.Response}}"},
+	}}); err == nil {
+		t.Fatal("expected a prompt using a step it does not depend on to be rejected")
+	}
+	if err := validatePlan(&types.PlanRequest{TimeoutSec: math.MaxInt64 / 1000, Steps: []types.PlanStepSpec{
+		{ID: "a", Prompt: "x"},
+	}}); err == nil {
+		t.Fatal("expected a timeout that overflows to be rejected")
+	}
+
+	store, err := NewPlanStore(filepath.Join(t.TempDir(), "plans.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	prompts := map[string]string{}
+	bothStarted := make(chan struct{})
+	var started sync.WaitGroup
+	started.Add(2)
+	go func() { started.Wait(); close(bothStarted) }()
+	run := func(ctx context.Context, planID string, st *types.PlanStepSpec, content string) (*types.MessageResponse, error) {
+		mu.Lock()
+		prompts[st.ID] = content
+		mu.Unlock()
+		switch st.ID {
+		case "research", "code":
+			// The two independent steps run in parallel.
+			started.Done()
+			<-bothStarted
+		case "broken":
+			return nil, errors.New("node unreachable")
+		}
+		return &types.MessageResponse{NodeID: "node-" + st.Skill, Response: st.ID + " done"}, nil
+	}
+	e := newPlanExecutor(run, nil, store)
+	defer e.stop()
+
+	req := &types.PlanRequest{
+		UserPrompt: "ship the feature",
+		Steps: []types.PlanStepSpec{
+			{ID: "research", Skill: "search", Prompt: "Research: {{.Prompt}}"},
+			{ID: "code", Skill: "coding", Prompt: "Implement: {{.Prompt}}"},
+			{Skill: "review", Prompt: "Review {{.Steps.code.Response}} from {{.Steps.code.Node}} using {{.Steps.research.Response}}", DependsOn: []string{"research", "code"}},
+		},
+	}
+	if err := validatePlan(req); err != nil {
+		t.Fatal(err)
+	}
+	p, err := e.submit(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = waitPlan(t, e, p.ID)
+	if p.Status != types.PlanCompleted || p.FinalResponse != "step3 done" {
+		t.Fatalf("unexpected plan %+v", p)
+	}
+	if got, want := prompts["step3"], "Review code done from node-coding using research done"; got != want {
+		t.Fatalf("step3 prompt = %q, want %q", got, want)
+	}
+
+	// A failed step fails the plan and skips the steps after it.
+	req = &types.PlanRequest{Steps: []types.PlanStepSpec{
+		{ID: "broken", Prompt: "x"},
+		{ID: "after", Prompt: "{{.Steps.broken.Response}}", DependsOn: []string{"broken"}},
+	}}
+	if err := validatePlan(req); err != nil {
+		t.Fatal(err)
+	}
+	p, err = e.submit(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = waitPlan(t, e, p.ID)
+	if p.Status != types.PlanFailed || p.Steps[0].Status != types.StepFailed || p.Steps[1].Status != types.StepSkipped {
+		t.Fatalf("unexpected failed plan %+v", p)
+	}
+
+	// Plans survive a restart, even with a torn journal record.
+	e.stop()
+	if store.JournalLen() == 0 {
+		t.Fatal("expected step changes to be journaled")
+	}
+	f, err := os.OpenFile(store.journalPath(), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"seq": 99999, "plan": {"id": "tor`)
+	f.Close()
+	reloaded := newPlanExecutor(run, nil, store)
+	if got := reloaded.list(); len(got) != 2 || got[0].ID != p.ID || got[1].Status != types.PlanCompleted {
+		t.Fatalf("unexpected reloaded plans %+v", got)
+	}
+	if store.JournalLen() != 0 {
+		t.Fatal("expected the journal to be compacted on load")
+	}
+}
@@ -0,0 +1,194 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+const (
+	defaultQueueSize    = 100
+	defaultQueueTimeout = 30 * time.Second
+)
+
+// Errors from forwardQueue.acquire.
+var (
+	errQueueFull    = errors.New("every candidate node is at its concurrency limit and the queue is full")
+	errQueueTimeout = errors.New("timed out waiting for a node below its concurrency limit")
+)
+
+// forwardQueue keeps forwards within each node's concurrency limit. A
+// forward takes a slot on the first of its candidate nodes that has one
+// free; if none has, it waits in a bounded FIFO queue until a slot frees
+// up on any of them, or gives up after a timeout.
+type forwardQueue struct {
+	registry *Registry
+	size     int
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	waiting []*queuedForward
+}
+
+// queuedForward is a forward waiting for a slot. The ID of the node whose
+// slot it was given is sent on grant.
+type queuedForward struct {
+	nodes []string
+	grant chan string
+}
+
+func newForwardQueue(registry *Registry, size int, timeout time.Duration) *forwardQueue {
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+	if timeout <= 0 {
+		timeout = defaultQueueTimeout
+	}
+	return &forwardQueue{registry: registry, size: size, timeout: timeout}
+}
+
+// acquire takes a forward slot on one of nodes, preferring them in order,
+// and returns that node. The caller must release the slot. A nil queue
+// applies no limits and returns the first node.
+func (q *forwardQueue) acquire(ctx context.Context, nodes []*types.Node) (*types.Node, error) {
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no nodes to forward to")
+	}
+	if q == nil {
+		return nodes[0], nil
+	}
+	ids := make([]string, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.ID
+	}
+	pick := func(id string) *types.Node {
+		return nodes[slices.Index(ids, id)]
+	}
+
+	q.mu.Lock()
+	// Slots are handed to waiters as soon as they free up, so a free slot
+	// here is not one a waiter could use.
+	if id := q.tryLocked(ids); id != "" {
+		q.mu.Unlock()
+		return pick(id), nil
+	}
+	if len(q.waiting) >= q.size {
+		q.mu.Unlock()
+		return nil, errQueueFull
+	}
+	w := &queuedForward{nodes: ids, grant: make(chan string, 1)}
+	q.waiting = append(q.waiting, w)
+	q.mu.Unlock()
+
+	timer := time.NewTimer(q.timeout)
+	defer timer.Stop()
+	select {
+	case id := <-w.grant:
+		return pick(id), nil
+	case <-ctx.Done():
+		q.abandon(w)
+		return nil, ctx.Err()
+	case <-timer.C:
+		q.abandon(w)
+		return nil, errQueueTimeout
+	}
+}
+
+// tryLocked takes a slot on the first node with one free and returns its
+// ID, or "" if all are saturated.
+func (q *forwardQueue) tryLocked(ids []string) string {
+	for _, id := range ids {
+		if q.registry.TryBeginForward(id) {
+			return id
+		}
+	}
+	return ""
+}
+
+// abandon removes a waiter that gave up. A slot granted to it in the
+// meantime is passed on.
+func (q *forwardQueue) abandon(w *queuedForward) {
+	q.mu.Lock()
+	if i := slices.Index(q.waiting, w); i >= 0 {
+		q.waiting = slices.Delete(q.waiting, i, i+1)
+		q.mu.Unlock()
+		return
+	}
+	q.mu.Unlock()
+	q.release(<-w.grant)
+}
+
+// release frees a slot taken by acquire and hands free slots to waiters.
+func (q *forwardQueue) release(nodeID string) {
+	if q == nil {
+		return
+	}
+	q.registry.EndForward(nodeID)
+	q.dispatch()
+}
+
+// dispatch gives free slots to waiters in arrival order. A waiter whose
+// candidates are all saturated does not hold up those behind it.
+func (q *forwardQueue) dispatch() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i := 0; i < len(q.waiting); {
+		w := q.waiting[i]
+		if id := q.tryLocked(w.nodes); id != "" {
+			w.grant <- id
+			q.waiting = slices.Delete(q.waiting, i, i+1)
+			continue
+		}
+		i++
+	}
+}
+
+// queued returns how many forwards are waiting for a slot.
+func (q *forwardQueue) queued() int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.waiting)
+}
+
+// isQueueError reports whether err means no slot could be had in time.
+func isQueueError(err error) bool {
+	return errors.Is(err, errQueueFull) || errors.Is(err, errQueueTimeout)
+}
+
+// handleSetConcurrency handles PUT /api/v1/nodes/{id}/concurrency — set
+// the admin override of a node's concurrency limit. A null
+// max_concurrency reverts to the limit the node advertises; 0 lifts it.
+func (s *Server) handleSetConcurrency(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var req struct {
+		MaxConcurrency *int `json:"max_concurrency"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if req.MaxConcurrency != nil && *req.MaxConcurrency < 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "max_concurrency must not be negative"})
+		return
+	}
+	n := s.registry.SetConcurrencyOverride(id, req.MaxConcurrency)
+	if n == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "node not found"})
+		return
+	}
+	// A raised limit may let waiting forwards through.
+	s.queue.dispatch()
+	writeJSON(w, http.StatusOK, n)
+}
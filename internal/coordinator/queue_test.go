@@ -0,0 +1,62 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+func TestForwardQueue(t *testing.T) {
+	reg := NewRegistry(nil)
+	a := &types.Node{ID: "a", Name: "a", Status: types.NodeStatusOnline, MaxConcurrency: 1}
+	b := &types.Node{ID: "b", Name: "b", Status: types.NodeStatusOnline, MaxConcurrency: 1}
+	reg.Add(a)
+	reg.Add(b)
+	q := newForwardQueue(reg, 1, 200*time.Millisecond)
+	ctx := context.Background()
+
+	// A saturated first choice is skipped rather than piled onto.
+	if n, err := q.acquire(ctx, []*types.Node{a, b}); err != nil || n.ID != "a" {
+		t.Fatalf("first acquire = %v, %v; want a", n, err)
+	}
+	if n, err := q.acquire(ctx, []*types.Node{a, b}); err != nil || n.ID != "b" {
+		t.Fatalf("second acquire = %v, %v; want b", n, err)
+	}
+
+	// With both saturated, one forward may wait; it gets the first slot freed.
+	got := make(chan string, 1)
+	go func() {
+		n, err := q.acquire(ctx, []*types.Node{a, b})
+		if err != nil {
+			got <- err.Error()
+			return
+		}
+		got <- n.ID
+	}()
+	for q.queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := q.acquire(ctx, []*types.Node{a}); !errors.Is(err, errQueueFull) {
+		t.Fatalf("expected the queue to be full, got %v", err)
+	}
+	q.release("b")
+	if id := <-got; id != "b" {
+		t.Fatalf("waiter got %s, want b", id)
+	}
+	if _, err := q.acquire(ctx, []*types.Node{a}); !errors.Is(err, errQueueTimeout) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if n := reg.Get("a"); n.InFlight != 1 {
+		t.Fatalf("a has %d in flight after a timed-out wait, want 1", n.InFlight)
+	}
+
+	// Lifting the limit lets the node take more.
+	unlimited := 0
+	reg.SetConcurrencyOverride("a", &unlimited)
+	if n, err := q.acquire(ctx, []*types.Node{a}); err != nil || n.ID != "a" {
+		t.Fatalf("acquire after override = %v, %v; want a", n, err)
+	}
+}
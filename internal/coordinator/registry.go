@@ -1,8 +1,10 @@
 package coordinator
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
@@ -11,16 +13,79 @@ import (
 
 // Registry manages the set of known nodes.
 type Registry struct {
-	mu         sync.RWMutex
-	nodes      map[string]*types.Node
-	nodeTokens map[string]string // nodeID -> per-node token
+	mu           sync.RWMutex
+	nodes        map[string]*types.Node
+	nodeTokens   map[string]string // nodeID -> per-node token (in memory only)
+	tokenHashes  map[string]string // nodeID -> SHA-256 of per-node token
+	secretHashes map[string]string // nodeID -> SHA-256 of node identity secret
+	store        *NodeStore
+	events       *EventBus
 }
 
 // NewRegistry creates an empty node registry.
-func NewRegistry() *Registry {
-	return &Registry{
-		nodes:      make(map[string]*types.Node),
-		nodeTokens: make(map[string]string),
+// If store is non-nil, nodes are restored from and persisted to disk.
+// Restored nodes are marked offline until their first heartbeat.
+func NewRegistry(store *NodeStore) *Registry {
+	r := &Registry{
+		nodes:        make(map[string]*types.Node),
+		nodeTokens:   make(map[string]string),
+		tokenHashes:  make(map[string]string),
+		secretHashes: make(map[string]string),
+	}
+	if store != nil {
+		r.store = store
+		if entries, err := r.store.LoadNodes(); err != nil {
+			log.Printf("WARN: failed to load persisted nodes: %v", err)
+		} else if len(entries) > 0 {
+			r.restore(entries)
+			log.Printf("restored %d persisted nodes (stale until first heartbeat)", len(r.nodes))
+		}
+	}
+	return r
+}
+
+// restore repopulates the registry from persisted entries. Every node is
+// marked offline: its plaintext token is unknown until it heartbeats again.
+func (r *Registry) restore(entries []*persistedNode) {
+	for _, e := range entries {
+		if e == nil || e.Node == nil || e.Node.ID == "" {
+			continue
+		}
+		n := copyNode(e.Node)
+		n.Status = types.NodeStatusOffline
+		n.InFlight = 0
+		n.Load = nil
+		// Nothing survives a restart in flight, so a drain is complete.
+		if n.Maintenance == types.MaintenanceDraining {
+			n.Maintenance = types.MaintenanceDrained
+		}
+		r.nodes[n.ID] = n
+		if e.TokenHash != "" {
+			r.tokenHashes[n.ID] = e.TokenHash
+		}
+		if e.SecretHash != "" {
+			r.secretHashes[n.ID] = e.SecretHash
+		}
+	}
+}
+
+// persistLocked writes the registry to the store, if configured.
+// Callers must hold r.mu; writing under the lock keeps snapshots ordered.
+func (r *Registry) persistLocked() {
+	if r.store == nil {
+		return
+	}
+	entries := make([]*persistedNode, 0, len(r.nodes))
+	for id, n := range r.nodes {
+		entries = append(entries, &persistedNode{
+			Node:       copyNode(n),
+			TokenHash:  r.tokenHashes[id],
+			SecretHash: r.secretHashes[id],
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Node.ID < entries[j].Node.ID })
+	if err := r.store.SaveNodes(entries); err != nil {
+		log.Printf("WARN: failed to persist node registry: %v", err)
 	}
 }
 
@@ -32,6 +97,7 @@ func (r *Registry) Add(node *types.Node) error {
 		return fmt.Errorf("node %s already registered", node.ID)
 	}
 	r.nodes[node.ID] = node
+	r.persistLocked()
 	return nil
 }
 
@@ -44,28 +110,108 @@ func (r *Registry) Remove(id string) bool {
 	}
 	delete(r.nodes, id)
 	delete(r.nodeTokens, id)
+	delete(r.tokenHashes, id)
+	delete(r.secretHashes, id)
+	r.persistLocked()
+	return true
+}
+
+// Reattach updates an existing node in place for a node that re-registers
+// with its stored identity: endpoint, name, capabilities and advertised
+// concurrency are refreshed and the node is marked online. Labels sent by
+// the node are merged over its current ones, so labels added through the
+// API survive a restart, as does a concurrency override.
+// Returns false if the node is unknown.
+func (r *Registry) Reattach(id, name, endpoint string, caps types.Capabilities, labels map[string]string, maxConcurrency int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, exists := r.nodes[id]
+	if !exists {
+		return false
+	}
+	n.Name = name
+	n.Endpoint = endpoint
+	n.Capabilities = caps
+	n.MaxConcurrency = maxConcurrency
+	for k, v := range labels {
+		if n.Labels == nil {
+			n.Labels = make(map[string]string)
+		}
+		n.Labels[k] = v
+	}
+	n.Status = types.NodeStatusOnline
+	n.LastHeartbeat = time.Now()
+	r.persistLocked()
 	return true
 }
 
-// SetNodeToken stores a per-node authentication token.
+// SetNodeSecret stores the hash of a node's identity secret.
+func (r *Registry) SetNodeSecret(nodeID, secret string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.secretHashes[nodeID] = hashToken(secret)
+	r.persistLocked()
+}
+
+// CheckNodeSecret reports whether nodeID is registered and whether secret
+// matches its stored identity secret.
+func (r *Registry) CheckNodeSecret(nodeID, secret string) (known, valid bool) {
+	h := hashToken(secret)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if _, ok := r.nodes[nodeID]; !ok {
+		return false, false
+	}
+	sh, ok := r.secretHashes[nodeID]
+	if !ok || secret == "" {
+		return true, false
+	}
+	return true, subtle.ConstantTimeCompare([]byte(sh), []byte(h)) == 1
+}
+
+// SetNodeToken stores a per-node authentication token, replacing (and so
+// revoking) any previous one. Only its hash is persisted.
 func (r *Registry) SetNodeToken(nodeID, token string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	r.nodeTokens[nodeID] = token
+	r.tokenHashes[nodeID] = hashToken(token)
+	r.persistLocked()
 }
 
-// ValidateNodeToken checks whether the given token matches the stored per-node token.
+// ValidateNodeToken checks whether the given token matches any stored per-node token.
 func (r *Registry) ValidateNodeToken(token string) bool {
+	h := hashToken(token)
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	for _, t := range r.nodeTokens {
-		if t == token {
+	for _, th := range r.tokenHashes {
+		if subtle.ConstantTimeCompare([]byte(th), []byte(h)) == 1 {
 			return true
 		}
 	}
 	return false
 }
 
+// RestoreNodeToken re-learns the plaintext token of a restored node from a
+// request it authenticated with. The token is kept only if it matches the
+// persisted hash for that node. Returns true if the token is valid for nodeID.
+func (r *Registry) RestoreNodeToken(nodeID, token string) bool {
+	if token == "" {
+		return false
+	}
+	h := hashToken(token)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	th, ok := r.tokenHashes[nodeID]
+	if !ok || subtle.ConstantTimeCompare([]byte(th), []byte(h)) != 1 {
+		return false
+	}
+	if r.nodeTokens[nodeID] == "" {
+		r.nodeTokens[nodeID] = token
+	}
+	return true
+}
+
 // GetNodeToken returns the per-node token for the given node ID.
 func (r *Registry) GetNodeToken(nodeID string) string {
 	r.mu.RLock()
@@ -84,6 +230,20 @@ func copyNode(n *types.Node) *types.Node {
 		cp.Capabilities.Skills = make([]string, len(n.Capabilities.Skills))
 		copy(cp.Capabilities.Skills, n.Capabilities.Skills)
 	}
+	if n.Load != nil {
+		l := *n.Load
+		cp.Load = &l
+	}
+	if n.Labels != nil {
+		cp.Labels = make(map[string]string, len(n.Labels))
+		for k, v := range n.Labels {
+			cp.Labels[k] = v
+		}
+	}
+	if n.ConcurrencyOverride != nil {
+		limit := *n.ConcurrencyOverride
+		cp.ConcurrencyOverride = &limit
+	}
 	return &cp
 }
 
@@ -125,13 +285,16 @@ func (r *Registry) UpdateStatus(id string, status types.NodeStatus) bool {
 	if !exists {
 		return false
 	}
-	n.Status = status
+	if n.Status != status {
+		n.Status = status
+		r.persistLocked()
+	}
 	return true
 }
 
-// RecordHeartbeat updates a node's heartbeat time and status.
-// Returns false if the node is not found.
-func (r *Registry) RecordHeartbeat(nodeID string, status types.NodeStatus) bool {
+// RecordHeartbeat updates a node's heartbeat time, status and reported
+// load. Returns false if the node is not found.
+func (r *Registry) RecordHeartbeat(nodeID string, status types.NodeStatus, load *types.NodeLoad) bool {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	n, exists := r.nodes[nodeID]
@@ -139,7 +302,26 @@ func (r *Registry) RecordHeartbeat(nodeID string, status types.NodeStatus) bool
 		return false
 	}
 	n.LastHeartbeat = time.Now()
-	n.Status = status
+	if load != nil {
+		l := *load
+		n.Load = &l
+	}
+	if n.Status != status {
+		// Only status transitions are persisted; heartbeat timestamps
+		// alone would rewrite the file every few seconds.
+		prev := n.Status
+		n.Status = status
+		r.persistLocked()
+		evType := types.EventNodeStatus
+		if prev == types.NodeStatusOffline {
+			evType = types.EventNodeOnline
+		}
+		r.events.Publish(types.Event{
+			Type:   evType,
+			NodeID: n.ID,
+			Data:   map[string]any{"name": n.Name, "from": prev, "to": status},
+		})
+	}
 	return true
 }
 
@@ -148,6 +330,7 @@ func (r *Registry) MarkOfflineIfStale(timeout time.Duration) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	now := time.Now()
+	changed := false
 	for _, n := range r.nodes {
 		if n.Status == types.NodeStatusOffline {
 			continue
@@ -155,6 +338,140 @@ func (r *Registry) MarkOfflineIfStale(timeout time.Duration) {
 		if now.Sub(n.LastHeartbeat) > timeout {
 			log.Printf("node %s (%s) missed heartbeat, marking offline", n.ID, n.Name)
 			n.Status = types.NodeStatusOffline
+			changed = true
+			r.events.Publish(types.Event{
+				Type:   types.EventNodeOffline,
+				NodeID: n.ID,
+				Data:   map[string]any{"name": n.Name, "reason": "heartbeat_timeout"},
+			})
 		}
 	}
+	if changed {
+		r.persistLocked()
+	}
+}
+
+// SetMaintenance puts a node into (or, with "", takes it out of) maintenance.
+// A node asked to drain with nothing in flight is reported drained at once;
+// otherwise EndForward completes the drain. Returns a copy of the updated
+// node, or nil if not found.
+func (r *Registry) SetMaintenance(id string, state types.MaintenanceState) *types.Node {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, exists := r.nodes[id]
+	if !exists {
+		return nil
+	}
+	if state == types.MaintenanceDraining && n.Maintenance == types.MaintenanceDrained {
+		return copyNode(n)
+	}
+	if state == types.MaintenanceDraining && n.InFlight == 0 {
+		state = types.MaintenanceDrained
+	}
+	if n.Maintenance == state {
+		return copyNode(n)
+	}
+	n.Maintenance = state
+	r.persistLocked()
+
+	evType := types.EventNodeUncordoned
+	switch state {
+	case types.MaintenanceCordoned:
+		evType = types.EventNodeCordoned
+	case types.MaintenanceDraining:
+		evType = types.EventNodeDraining
+	case types.MaintenanceDrained:
+		evType = types.EventNodeDrained
+	}
+	r.events.Publish(types.Event{
+		Type:   evType,
+		NodeID: n.ID,
+		Data:   map[string]any{"name": n.Name, "in_flight": n.InFlight},
+	})
+	return copyNode(n)
+}
+
+// TryBeginForward records a message being forwarded to a node, if the
+// node is below its concurrency limit. It reports whether it did.
+func (r *Registry) TryBeginForward(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, ok := r.nodes[id]
+	if !ok {
+		return false
+	}
+	if limit := n.ConcurrencyLimit(); limit > 0 && n.InFlight >= limit {
+		return false
+	}
+	n.InFlight++
+	return true
+}
+
+// SetConcurrencyOverride sets (or, with nil, clears) the admin override of
+// a node's concurrency limit. Returns a copy of the updated node, or nil if
+// not found.
+func (r *Registry) SetConcurrencyOverride(id string, limit *int) *types.Node {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, exists := r.nodes[id]
+	if !exists {
+		return nil
+	}
+	n.ConcurrencyOverride = limit
+	r.persistLocked()
+	return copyNode(n)
+}
+
+// EndForward records a forward to a node finishing. The last forward to
+// finish on a draining node marks it drained.
+func (r *Registry) EndForward(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, ok := r.nodes[id]
+	if !ok || n.InFlight == 0 {
+		return
+	}
+	n.InFlight--
+	if n.InFlight == 0 && n.Maintenance == types.MaintenanceDraining {
+		n.Maintenance = types.MaintenanceDrained
+		r.persistLocked()
+		log.Printf("node %s (%s) drained", n.ID, n.Name)
+		r.events.Publish(types.Event{
+			Type:   types.EventNodeDrained,
+			NodeID: n.ID,
+			Data:   map[string]any{"name": n.Name, "in_flight": 0},
+		})
+	}
+}
+
+// UpdateLabels applies a merge patch to a node's labels: a non-nil value
+// sets the key, a nil value removes it. Returns a copy of the updated node,
+// or nil if not found.
+func (r *Registry) UpdateLabels(id string, patch map[string]*string) *types.Node {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, exists := r.nodes[id]
+	if !exists {
+		return nil
+	}
+	for k, v := range patch {
+		if v == nil {
+			delete(n.Labels, k)
+			continue
+		}
+		if n.Labels == nil {
+			n.Labels = make(map[string]string)
+		}
+		n.Labels[k] = *v
+	}
+	if len(n.Labels) == 0 {
+		n.Labels = nil
+	}
+	r.persistLocked()
+	r.events.Publish(types.Event{
+		Type:   types.EventNodeLabeled,
+		NodeID: n.ID,
+		Data:   map[string]any{"name": n.Name, "labels": formatLabels(n.Labels)},
+	})
+	return copyNode(n)
 }
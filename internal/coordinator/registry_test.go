@@ -0,0 +1,142 @@
+package coordinator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+func newTestNodeStore(t *testing.T) (*NodeStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "nodes.json")
+	ns, err := NewNodeStore(path)
+	if err != nil {
+		t.Fatalf("NewNodeStore: %v", err)
+	}
+	return ns, path
+}
+
+func TestRegistry_PersistAndRestore(t *testing.T) {
+	ns, path := newTestNodeStore(t)
+
+	reg := NewRegistry(ns)
+	node := &types.Node{
+		ID:            "node-0123456789abcdef",
+		Name:          "linux-gpu",
+		Endpoint:      "10.0.0.5:9121",
+		Capabilities:  types.Capabilities{OS: "linux", GPU: true, Skills: []string{"docker"}},
+		Status:        types.NodeStatusOnline,
+		LastHeartbeat: time.Now(),
+	}
+	if err := reg.Add(node); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	reg.SetNodeToken(node.ID, "secret-node-token")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading node store: %v", err)
+	}
+	if strings.Contains(string(data), "secret-node-token") {
+		t.Fatal("plaintext node token must not be persisted")
+	}
+
+	// Simulate a coordinator restart.
+	restored := NewRegistry(ns)
+	got := restored.Get(node.ID)
+	if got == nil {
+		t.Fatal("expected node to be restored")
+	}
+	if got.Name != "linux-gpu" || !got.Capabilities.GPU || len(got.Capabilities.Skills) != 1 {
+		t.Errorf("restored node mismatch: %+v", got)
+	}
+	if got.Status != types.NodeStatusOffline {
+		t.Errorf("restored node should be stale (offline), got %s", got.Status)
+	}
+
+	if !restored.ValidateNodeToken("secret-node-token") {
+		t.Error("restored registry should accept the node token")
+	}
+	if restored.ValidateNodeToken("wrong-token") {
+		t.Error("restored registry should reject unknown tokens")
+	}
+	if tok := restored.GetNodeToken(node.ID); tok != "" {
+		t.Errorf("plaintext token should be unknown before first heartbeat, got %q", tok)
+	}
+
+	if restored.RestoreNodeToken(node.ID, "wrong-token") {
+		t.Error("RestoreNodeToken should reject a mismatched token")
+	}
+	if !restored.RestoreNodeToken(node.ID, "secret-node-token") {
+		t.Fatal("RestoreNodeToken should accept the matching token")
+	}
+	if tok := restored.GetNodeToken(node.ID); tok != "secret-node-token" {
+		t.Errorf("expected plaintext token after heartbeat, got %q", tok)
+	}
+
+	restored.RecordHeartbeat(node.ID, types.NodeStatusOnline, nil)
+	if got := restored.Get(node.ID); got.Status != types.NodeStatusOnline {
+		t.Errorf("expected node online after heartbeat, got %s", got.Status)
+	}
+}
+
+func TestRegistry_RemovePersists(t *testing.T) {
+	ns, _ := newTestNodeStore(t)
+
+	reg := NewRegistry(ns)
+	reg.Add(&types.Node{ID: "node-a", Name: "a", Status: types.NodeStatusOnline})
+	reg.Add(&types.Node{ID: "node-b", Name: "b", Status: types.NodeStatusOnline})
+	reg.SetNodeToken("node-a", "tok-a")
+	reg.Remove("node-a")
+
+	restored := NewRegistry(ns)
+	if restored.Exists("node-a") {
+		t.Error("removed node should not be restored")
+	}
+	if !restored.Exists("node-b") {
+		t.Error("remaining node should be restored")
+	}
+	if restored.ValidateNodeToken("tok-a") {
+		t.Error("token of removed node should not be restored")
+	}
+}
+
+func TestRegistry_DrainWaitsForInFlight(t *testing.T) {
+	reg := NewRegistry(nil)
+	reg.Add(&types.Node{ID: "node-a", Name: "a", Status: types.NodeStatusOnline})
+	reg.Add(&types.Node{ID: "node-b", Name: "b", Status: types.NodeStatusOnline})
+	rt := NewRouter(reg)
+
+	if !reg.TryBeginForward("node-a") {
+		t.Fatal("expected the forward to be recorded")
+	}
+	if n := reg.SetMaintenance("node-a", types.MaintenanceDraining); n.Maintenance != types.MaintenanceDraining {
+		t.Fatalf("expected draining with a forward in flight, got %q", n.Maintenance)
+	}
+
+	// Auto-routing skips the draining node; explicit routing still reaches it.
+	for i := 0; i < 5; i++ {
+		n, err := rt.Route(&types.Message{Content: "hi"})
+		if err != nil || n.ID != "node-b" {
+			t.Fatalf("expected auto-route to node-b, got %v, %v", n, err)
+		}
+	}
+	if n, err := rt.Route(&types.Message{Content: "hi", TargetNode: "node-a"}); err != nil || n.ID != "node-a" {
+		t.Fatalf("expected explicit route to node-a, got %v, %v", n, err)
+	}
+
+	reg.EndForward("node-a")
+	if n := reg.Get("node-a"); n.Maintenance != types.MaintenanceDrained || n.InFlight != 0 {
+		t.Fatalf("expected drained after last forward, got %q (%d in flight)", n.Maintenance, n.InFlight)
+	}
+
+	reg.SetMaintenance("node-a", "")
+	reg.SetMaintenance("node-b", types.MaintenanceCordoned)
+	if n, err := rt.Route(&types.Message{Content: "hi"}); err != nil || n.ID != "node-a" {
+		t.Fatalf("expected uncordoned node-a to be routable, got %v, %v", n, err)
+	}
+}
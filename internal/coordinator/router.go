@@ -1,9 +1,16 @@
 package coordinator
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/SallyKAN/claw-mesh/internal/types"
 )
@@ -13,28 +20,161 @@ import (
 type Router struct {
 	mu       sync.RWMutex
 	rules    []*types.RoutingRule
+	revision uint64 // bumped on every change to rules
 	registry *Registry
 	store    *Store
+	groups   *GroupSet
+	events   *EventBus
+	affinity *affinityTable
+	circuits CircuitChecker
+	// compiled holds the rules' patterns and expressions in parsed form.
+	// It is rebuilt, never modified, when the rules change.
+	compiled *compiledRules
+
+	strategyMu sync.RWMutex
+	strategies map[string]Strategy
+}
+
+// CircuitChecker reports whether a node's circuit breaker is open.
+type CircuitChecker interface {
+	CircuitOpen(nodeID string) bool
 }
 
+// Errors from conditional rule changes.
+var (
+	errStaleRevision = errors.New("rules have changed")
+	errRuleNotFound  = errors.New("rule not found")
+	errInvalidOrder  = errors.New("invalid rule order")
+	errUnknownGroup  = errors.New("unknown target group")
+	errGroupInUse    = errors.New("group in use")
+)
+
 // NewRouter creates a router backed by the given registry.
 // If store is non-nil, rules are loaded from and persisted to disk.
 func NewRouter(registry *Registry, store ...*Store) *Router {
 	rt := &Router{
-		registry: registry,
+		registry:   registry,
+		revision:   1,
+		affinity:   newAffinityTable(),
+		strategies: make(map[string]Strategy),
+	}
+	for _, st := range builtinStrategies() {
+		rt.strategies[st.Name()] = st
 	}
 	if len(store) > 0 && store[0] != nil {
 		rt.store = store[0]
-		if rules, err := rt.store.LoadRules(); err != nil {
+		if rules, rev, err := rt.store.LoadRules(); err != nil {
 			log.Printf("WARN: failed to load persisted rules: %v", err)
-		} else if len(rules) > 0 {
-			rt.rules = rules
-			log.Printf("loaded %d persisted routing rules", len(rules))
+		} else {
+			if rev > rt.revision {
+				rt.revision = rev
+			}
+			if len(rules) > 0 {
+				rt.rules = rules
+				log.Printf("loaded %d persisted routing rules", len(rules))
+			}
 		}
 	}
+	rt.compiled = compileRules(rt.rules)
 	return rt
 }
 
+// compiledRules holds the content_regex patterns, expressions and label
+// selectors of a set of rules in parsed form, keyed by their source text.
+// All are validated when a rule is added, so one that fails to parse is
+// left out and never matches.
+type compiledRules struct {
+	contentRes map[string]*regexp.Regexp
+	exprs      map[string]exprNode
+	selectors  map[string]labelSelector
+}
+
+func compileRules(rules []*types.RoutingRule) *compiledRules {
+	c := &compiledRules{
+		contentRes: make(map[string]*regexp.Regexp),
+		exprs:      make(map[string]exprNode),
+		selectors:  make(map[string]labelSelector),
+	}
+	for _, r := range rules {
+		if pattern := r.Match.ContentRegex; pattern != "" && c.contentRes[pattern] == nil {
+			if re, err := regexp.Compile(pattern); err == nil {
+				c.contentRes[pattern] = re
+			}
+		}
+		if r.Expr != "" && c.exprs[r.Expr] == nil {
+			if e, err := parseExpr(r.Expr); err == nil {
+				c.exprs[r.Expr] = e
+			}
+		}
+		if selector := r.Match.LabelSelector; selector != "" && c.selectors[selector] == nil {
+			if sel, err := parseLabelSelector(selector); err == nil {
+				c.selectors[selector] = sel
+			}
+		}
+	}
+	return c
+}
+
+// matchesExpr evaluates an expression against a node. An empty expression
+// matches everything.
+func (c *compiledRules) matchesExpr(src string, n *types.Node) bool {
+	if src == "" {
+		return true
+	}
+	e := c.exprs[src]
+	return e != nil && e.eval(n)
+}
+
+// matchesLabelSelector evaluates a label selector against a node's labels.
+func (c *compiledRules) matchesLabelSelector(selector string, n *types.Node) bool {
+	sel := c.selectors[selector]
+	return sel != nil && sel.matches(n.Labels)
+}
+
+// checkRevisionLocked returns errStaleRevision unless ifRevision is 0
+// (unconditional) or the current revision.
+func (rt *Router) checkRevisionLocked(ifRevision uint64) error {
+	if ifRevision != 0 && ifRevision != rt.revision {
+		return fmt.Errorf("%w: now at revision %d, not %d", errStaleRevision, rt.revision, ifRevision)
+	}
+	return nil
+}
+
+// commitLocked bumps the revision after a change and returns a copy of
+// the rules to persist along with the new revision.
+func (rt *Router) commitLocked() ([]*types.RoutingRule, uint64) {
+	rt.revision++
+	rt.compiled = compileRules(rt.rules)
+	rules := make([]*types.RoutingRule, len(rt.rules))
+	copy(rules, rt.rules)
+	return rules, rt.revision
+}
+
+// checkGroupsLocked returns errUnknownGroup if a rule targets a group that
+// doesn't exist. Groups are only removed under the rules lock (see
+// RemoveGroup), so the check holds until the lock is released.
+func (rt *Router) checkGroupsLocked(rules ...*types.RoutingRule) error {
+	for _, r := range rules {
+		if r.TargetGroup != "" && rt.groups.Get(r.TargetGroup) == nil {
+			return fmt.Errorf("%w %q", errUnknownGroup, r.TargetGroup)
+		}
+	}
+	return nil
+}
+
+// RemoveGroup deletes a node group unless a rule targets it, in which case
+// it returns errGroupInUse. Returns false if the group doesn't exist.
+func (rt *Router) RemoveGroup(name string) (bool, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for _, r := range rt.rules {
+		if r.TargetGroup == name {
+			return true, fmt.Errorf("%w by rule %s", errGroupInUse, r.ID)
+		}
+	}
+	return rt.groups.Remove(name)
+}
+
 // AddRule appends a routing rule and returns its assigned ID.
 func (rt *Router) AddRule(rule *types.RoutingRule) error {
 	id, err := generateID()
@@ -43,13 +183,17 @@ func (rt *Router) AddRule(rule *types.RoutingRule) error {
 	}
 	rule.ID = id
 	rt.mu.Lock()
+	if err := rt.checkGroupsLocked(rule); err != nil {
+		rt.mu.Unlock()
+		return err
+	}
 	rt.rules = append(rt.rules, rule)
-	rules := make([]*types.RoutingRule, len(rt.rules))
-	copy(rules, rt.rules)
+	rules, rev := rt.commitLocked()
 	rt.mu.Unlock()
-	if err := rt.persistRules(rules); err != nil {
+	if err := rt.persistRules(rules, rev); err != nil {
 		return fmt.Errorf("persisting rules: %w", err)
 	}
+	rt.events.Publish(types.Event{Type: types.EventRuleAdded, RuleID: rule.ID, Data: map[string]any{"rule": *rule}})
 	return nil
 }
 
@@ -69,25 +213,196 @@ func (rt *Router) RemoveRule(id string) (bool, error) {
 		rt.mu.Unlock()
 		return false, nil
 	}
-	rules := make([]*types.RoutingRule, len(rt.rules))
-	copy(rules, rt.rules)
+	rules, rev := rt.commitLocked()
 	rt.mu.Unlock()
-	if err := rt.persistRules(rules); err != nil {
+	rt.forgetRule(id)
+	if err := rt.persistRules(rules, rev); err != nil {
 		return true, fmt.Errorf("persisting rules: %w", err)
 	}
+	rt.events.Publish(types.Event{Type: types.EventRuleDeleted, RuleID: id})
 	return true, nil
 }
 
+// ReplaceRules swaps in a complete rule set and returns the new revision.
+// Rules without an ID are assigned one. Callers validate the rules first.
+// A non-zero ifRevision makes the change conditional on the current
+// revision.
+func (rt *Router) ReplaceRules(rules []*types.RoutingRule, ifRevision uint64) (uint64, error) {
+	for _, r := range rules {
+		if r.ID == "" {
+			id, err := generateID()
+			if err != nil {
+				return 0, err
+			}
+			r.ID = id
+		}
+	}
+	rt.mu.Lock()
+	if err := rt.checkRevisionLocked(ifRevision); err != nil {
+		rt.mu.Unlock()
+		return 0, err
+	}
+	if err := rt.checkGroupsLocked(rules...); err != nil {
+		rt.mu.Unlock()
+		return 0, err
+	}
+	old := rt.rules
+	rt.rules = append([]*types.RoutingRule(nil), rules...)
+	_, rev := rt.commitLocked()
+	rt.mu.Unlock()
+
+	kept := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		kept[r.ID] = true
+	}
+	for _, r := range old {
+		if !kept[r.ID] {
+			rt.forgetRule(r.ID)
+		}
+	}
+	if err := rt.persistRules(rules, rev); err != nil {
+		return rev, fmt.Errorf("persisting rules: %w", err)
+	}
+	rt.events.Publish(types.Event{Type: types.EventRulesReplaced, Data: map[string]any{"count": len(rules), "revision": rev}})
+	return rev, nil
+}
+
+// UpdateRule replaces the rule with the same ID in place, keeping its
+// position, and returns the new revision. Callers validate the rule first.
+func (rt *Router) UpdateRule(rule *types.RoutingRule, ifRevision uint64) (uint64, error) {
+	rt.mu.Lock()
+	if err := rt.checkRevisionLocked(ifRevision); err != nil {
+		rt.mu.Unlock()
+		return 0, err
+	}
+	i := slices.IndexFunc(rt.rules, func(r *types.RoutingRule) bool { return r.ID == rule.ID })
+	if i < 0 {
+		rt.mu.Unlock()
+		return 0, errRuleNotFound
+	}
+	if err := rt.checkGroupsLocked(rule); err != nil {
+		rt.mu.Unlock()
+		return 0, err
+	}
+	rt.rules[i] = rule
+	rules, rev := rt.commitLocked()
+	rt.mu.Unlock()
+
+	// The rule may now match other nodes; start its state afresh.
+	rt.forgetRule(rule.ID)
+	if err := rt.persistRules(rules, rev); err != nil {
+		return rev, fmt.Errorf("persisting rules: %w", err)
+	}
+	rt.events.Publish(types.Event{Type: types.EventRuleUpdated, RuleID: rule.ID, Data: map[string]any{"rule": *rule, "revision": rev}})
+	return rev, nil
+}
+
+// ReorderRules puts the rules in the order given by ids, which must list
+// every rule exactly once, and returns the new revision.
+func (rt *Router) ReorderRules(ids []string, ifRevision uint64) (uint64, error) {
+	rt.mu.Lock()
+	if err := rt.checkRevisionLocked(ifRevision); err != nil {
+		rt.mu.Unlock()
+		return 0, err
+	}
+	byID := make(map[string]*types.RoutingRule, len(rt.rules))
+	for _, r := range rt.rules {
+		byID[r.ID] = r
+	}
+	if len(ids) != len(rt.rules) {
+		rt.mu.Unlock()
+		return 0, fmt.Errorf("%w: got %d IDs for %d rules", errInvalidOrder, len(ids), len(byID))
+	}
+	reordered := make([]*types.RoutingRule, 0, len(ids))
+	for _, id := range ids {
+		r, ok := byID[id]
+		if !ok {
+			rt.mu.Unlock()
+			return 0, fmt.Errorf("%w: unknown or repeated rule %q", errInvalidOrder, id)
+		}
+		delete(byID, id)
+		reordered = append(reordered, r)
+	}
+	rt.rules = reordered
+	rules, rev := rt.commitLocked()
+	rt.mu.Unlock()
+
+	if err := rt.persistRules(rules, rev); err != nil {
+		return rev, fmt.Errorf("persisting rules: %w", err)
+	}
+	rt.events.Publish(types.Event{Type: types.EventRulesReordered, Data: map[string]any{"order": ids, "revision": rev}})
+	return rev, nil
+}
+
+// forgetRule drops the affinity and strategy state kept for a rule.
+func (rt *Router) forgetRule(id string) {
+	rt.affinity.forgetRule(id)
+	rt.strategyMu.RLock()
+	defer rt.strategyMu.RUnlock()
+	for _, st := range rt.strategies {
+		if f, ok := st.(forgetter); ok {
+			f.Forget(id)
+		}
+	}
+}
+
+// RegisterStrategy adds a node selection strategy, replacing any existing
+// strategy with the same name.
+func (rt *Router) RegisterStrategy(st Strategy) {
+	rt.strategyMu.Lock()
+	defer rt.strategyMu.Unlock()
+	rt.strategies[st.Name()] = st
+}
+
+// Strategy returns the named strategy, or nil if unknown. An empty name
+// selects the default strategy.
+func (rt *Router) Strategy(name string) Strategy {
+	if name == "" {
+		name = defaultStrategy
+	}
+	rt.strategyMu.RLock()
+	defer rt.strategyMu.RUnlock()
+	return rt.strategies[name]
+}
+
+// Strategies describes the registered strategies, sorted by name.
+func (rt *Router) Strategies() []types.StrategyInfo {
+	rt.strategyMu.RLock()
+	defer rt.strategyMu.RUnlock()
+	out := make([]types.StrategyInfo, 0, len(rt.strategies))
+	for name, st := range rt.strategies {
+		out = append(out, types.StrategyInfo{Name: name, Description: st.Description(), Default: name == defaultStrategy})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// StrategyNames returns the registered strategy names, sorted.
+func (rt *Router) StrategyNames() []string {
+	infos := rt.Strategies()
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name
+	}
+	return names
+}
+
 // persistRules saves rules to the store if configured.
-func (rt *Router) persistRules(rules []*types.RoutingRule) error {
+func (rt *Router) persistRules(rules []*types.RoutingRule, revision uint64) error {
 	if rt.store == nil {
 		return nil
 	}
-	return rt.store.SaveRules(rules)
+	return rt.store.SaveRules(rules, revision)
 }
 
 // ListRules returns a copy of all routing rules.
 func (rt *Router) ListRules() []*types.RoutingRule {
+	rules, _ := rt.RuleSet()
+	return rules
+}
+
+// RuleSet returns a copy of all routing rules and their revision.
+func (rt *Router) RuleSet() ([]*types.RoutingRule, uint64) {
 	rt.mu.RLock()
 	defer rt.mu.RUnlock()
 	out := make([]*types.RoutingRule, len(rt.rules))
@@ -95,13 +410,69 @@ func (rt *Router) ListRules() []*types.RoutingRule {
 		cp := *r
 		out[i] = &cp
 	}
-	return out
+	return out, rt.revision
 }
 
 // Route picks the best node for a message. If msg.TargetNode is set,
 // it routes directly to that node. Otherwise it evaluates rules in order.
 // Falls back to least-busy strategy if no rule matches.
 func (rt *Router) Route(msg *types.Message) (*types.Node, error) {
+	res, err := rt.route(msg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.node, nil
+}
+
+// defaultMaxNodes is how many candidates are tried for a rule without
+// max_nodes before giving up on a message.
+const defaultMaxNodes = 3
+
+// RouteWithFailover is like Route but also returns fallback nodes to try,
+// in order, if forwarding to the first one fails: the remaining candidates
+// of the same rule, least busy first, up to the rule's max_nodes in total.
+// Explicit and rule targets have no fallbacks.
+func (rt *Router) RouteWithFailover(msg *types.Message) ([]*types.Node, error) {
+	res, err := rt.route(msg, nil)
+	if err != nil {
+		return nil, err
+	}
+	maxNodes := defaultMaxNodes
+	if res.rule != nil && res.rule.MaxNodes > 0 {
+		maxNodes = res.rule.MaxNodes
+	}
+	nodes := []*types.Node{res.node}
+	rest := make([]*types.Node, 0, len(res.candidates))
+	for _, n := range res.candidates {
+		if n.ID != res.node.ID {
+			rest = append(rest, n)
+		}
+	}
+	for len(nodes) < maxNodes && len(rest) > 0 {
+		next := leastBusy(rest)
+		nodes = append(nodes, next)
+		for i, n := range rest {
+			if n == next {
+				rest = append(rest[:i], rest[i+1:]...)
+				break
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// routeResult is the outcome of route: the chosen node, the rule that
+// chose it (nil for explicit targets and the fallback) and the candidates
+// it chose among, which are the failover options.
+type routeResult struct {
+	node       *types.Node
+	rule       *types.RoutingRule
+	candidates []*types.Node
+}
+
+// route implements Route. If tr is non-nil, the decision is recorded in it
+// and routing state (round-robin counters, affinity) is left untouched.
+func (rt *Router) route(msg *types.Message, tr *routeTracer) (*routeResult, error) {
 	if msg.TargetNode != "" {
 		node := rt.registry.Get(msg.TargetNode)
 		if node == nil {
@@ -110,56 +481,132 @@ func (rt *Router) Route(msg *types.Message) (*types.Node, error) {
 		if node.Status == types.NodeStatusOffline {
 			return nil, fmt.Errorf("target node %q is offline", msg.TargetNode)
 		}
-		return node, nil
+		tr.chose(nil, node, "explicit target")
+		return &routeResult{node: node}, nil
 	}
 
 	rt.mu.RLock()
 	rules := make([]*types.RoutingRule, len(rt.rules))
 	copy(rules, rt.rules)
+	compiled := rt.compiled
 	rt.mu.RUnlock()
 
-	nodes := rt.registry.List()
-	online := filterOnline(nodes)
+	// A pinned rule is the only one considered, and has no fallback.
+	pinned := msg.TargetRule != ""
+	if pinned {
+		i := slices.IndexFunc(rules, func(r *types.RoutingRule) bool { return r.ID == msg.TargetRule })
+		if i < 0 {
+			return nil, fmt.Errorf("target rule %q not found", msg.TargetRule)
+		}
+		rules = rules[i : i+1]
+	}
+
+	var online []*types.Node
+	for _, n := range rt.registry.List() {
+		if reason := rt.unavailableReason(n); reason != "" {
+			tr.unavailable(n, reason)
+			continue
+		}
+		online = append(online, n)
+	}
 	if len(online) == 0 {
 		return nil, fmt.Errorf("no online nodes available")
 	}
 
 	// Evaluate rules in order.
 	for _, rule := range rules {
-		if isWildcard(rule) {
-			return rt.applyStrategy(rule.Strategy, online)
+		tr.rule(rule)
+		if rule.Disabled {
+			tr.skip("disabled")
+			continue
+		}
+		if reason := messageMismatch(&rule.Match, msg, compiled); reason != "" && !pinned {
+			tr.skip(reason)
+			continue
+		}
+		if isWildcard(rule) && rule.TargetGroup == "" {
+			tr.candidates(online)
+			return rt.choose(rule, msg, online, tr)
+		}
+		candidates := matchNodes(rule, online, compiled, tr)
+		if rule.TargetGroup != "" {
+			// Group targets apply the rule's strategy across the group's
+			// healthy members; an empty or unknown group skips the rule.
+			g := rt.groups.matcher(rule.TargetGroup)
+			if g == nil {
+				tr.skip(fmt.Sprintf("group %q not found", rule.TargetGroup))
+				continue
+			}
+			var members []*types.Node
+			for _, n := range candidates {
+				if g.contains(n) {
+					members = append(members, n)
+				} else {
+					tr.exclude(n, fmt.Sprintf("not in group %q", g.Name))
+				}
+			}
+			if len(members) > 0 {
+				tr.candidates(members)
+				return rt.choose(rule, msg, members, tr)
+			}
+			tr.skip(fmt.Sprintf("no eligible members in group %q", g.Name))
+			continue
 		}
-		candidates := matchNodes(rule, online)
 		if len(candidates) == 0 {
+			tr.skip("no eligible node matches")
 			continue
 		}
+		tr.candidates(candidates)
 		// If rule targets a specific node name, prefer it.
 		if rule.Target != "" {
 			for _, n := range candidates {
 				if n.Name == rule.Target || n.ID == rule.Target {
-					return n, nil
+					tr.chose(rule, n, "target")
+					return &routeResult{node: n, rule: rule}, nil
 				}
 			}
 			// Explicit target didn't match any candidate — skip this rule
 			// instead of silently falling back to leastBusy.
+			tr.skip(fmt.Sprintf("target %q is not a candidate", rule.Target))
 			continue
 		}
-		return leastBusy(candidates), nil
+		return rt.choose(rule, msg, candidates, tr)
+	}
+
+	if pinned {
+		return nil, fmt.Errorf("target rule %q selected no node", msg.TargetRule)
 	}
 
 	// No rule matched — fall back to least-busy across all online nodes.
-	return leastBusy(online), nil
+	n := leastBusy(online)
+	tr.fallback(n)
+	return &routeResult{node: n, candidates: online}, nil
 }
 
-// filterOnline returns nodes that are not offline.
-func filterOnline(nodes []*types.Node) []*types.Node {
-	var out []*types.Node
-	for _, n := range nodes {
-		if n.Status != types.NodeStatusOffline {
-			out = append(out, n)
-		}
+// choose applies the rule's strategy to candidates.
+func (rt *Router) choose(rule *types.RoutingRule, msg *types.Message, candidates []*types.Node, tr *routeTracer) (*routeResult, error) {
+	n, err := rt.applyStrategy(rule, msg, candidates, tr)
+	if err != nil {
+		return nil, err
 	}
-	return out
+	return &routeResult{node: n, rule: rule, candidates: candidates}, nil
+}
+
+// unavailableReason says why a node is not eligible for auto-routing,
+// or returns "" if it is. Offline nodes, nodes in maintenance and nodes
+// whose circuit is open are skipped; cordoned and draining nodes can
+// still be reached by explicit routing.
+func (rt *Router) unavailableReason(n *types.Node) string {
+	if n.Status == types.NodeStatusOffline {
+		return "offline"
+	}
+	if n.Maintenance != "" {
+		return string(n.Maintenance)
+	}
+	if rt.circuits != nil && rt.circuits.CircuitOpen(n.ID) {
+		return "circuit open"
+	}
+	return ""
 }
 
 // isWildcard returns true if the rule's match criteria is a wildcard.
@@ -170,29 +617,94 @@ func isWildcard(rule *types.RoutingRule) bool {
 	return false
 }
 
-// matchNodes filters nodes that satisfy a rule's match criteria.
-func matchNodes(rule *types.RoutingRule, nodes []*types.Node) []*types.Node {
+// matchNodes filters nodes that satisfy a rule's match criteria and
+// expression, recording why the others were excluded. compiled must hold
+// the rule's parsed expression and label selector.
+func matchNodes(rule *types.RoutingRule, nodes []*types.Node, compiled *compiledRules, tr *routeTracer) []*types.Node {
 	var out []*types.Node
 	for _, n := range nodes {
-		if matchesCriteria(&rule.Match, n) {
-			out = append(out, n)
+		reason := criteriaMismatch(&rule.Match, n, compiled)
+		if reason == "" && !compiled.matchesExpr(rule.Expr, n) {
+			reason = "expr is false"
 		}
+		if reason != "" {
+			tr.exclude(n, reason)
+			continue
+		}
+		out = append(out, n)
 	}
 	return out
 }
 
-// matchesCriteria checks whether a single node satisfies the criteria.
-func matchesCriteria(mc *types.MatchCriteria, n *types.Node) bool {
+// criteriaMismatch returns why a node fails the criteria, or "" if it
+// satisfies them. compiled holds the criteria's parsed label selector.
+func criteriaMismatch(mc *types.MatchCriteria, n *types.Node, compiled *compiledRules) string {
 	if mc.RequiresGPU != nil && *mc.RequiresGPU && !n.Capabilities.GPU {
-		return false
+		return "no GPU"
 	}
 	if mc.RequiresOS != "" && mc.RequiresOS != n.Capabilities.OS {
-		return false
+		return fmt.Sprintf("os is %q, not %q", n.Capabilities.OS, mc.RequiresOS)
 	}
 	if mc.RequiresSkill != "" && !hasSkill(n, mc.RequiresSkill) {
-		return false
+		return fmt.Sprintf("missing skill %q", mc.RequiresSkill)
+	}
+	if mc.LabelSelector != "" && !compiled.matchesLabelSelector(mc.LabelSelector, n) {
+		return "labels don't match selector"
 	}
-	return true
+	return ""
+}
+
+// messageMismatch returns why a message fails the criteria's message
+// predicates, or "" if it satisfies them. Criteria without any always match.
+// compiled holds the criteria's compiled content_regex pattern.
+func messageMismatch(mc *types.MatchCriteria, msg *types.Message, compiled *compiledRules) string {
+	if mc.Source != "" && mc.Source != msg.Source {
+		return fmt.Sprintf("source is %q, not %q", msg.Source, mc.Source)
+	}
+	if mc.Command != "" && messageCommand(msg.Content) != mc.Command {
+		return fmt.Sprintf("not a %s command", mc.Command)
+	}
+	if n := utf8.RuneCountInString(msg.Content); n < mc.MinLength || (mc.MaxLength > 0 && n > mc.MaxLength) {
+		return fmt.Sprintf("content length %d out of range", n)
+	}
+	if len(mc.Keywords) > 0 {
+		content := strings.ToLower(msg.Content)
+		found := false
+		for _, kw := range mc.Keywords {
+			if strings.Contains(content, strings.ToLower(kw)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "no keyword found"
+		}
+	}
+	if mc.ContentRegex != "" {
+		re := compiled.contentRes[mc.ContentRegex]
+		if re == nil || !re.MatchString(msg.Content) {
+			return "content doesn't match content_regex"
+		}
+	}
+	return ""
+}
+
+// hasMessagePredicates reports whether any message predicate is set.
+func hasMessagePredicates(mc *types.MatchCriteria) bool {
+	return len(mc.Keywords) > 0 || mc.ContentRegex != "" || mc.Command != "" || mc.Source != "" ||
+		mc.MinLength > 0 || mc.MaxLength > 0
+}
+
+// messageCommand returns the leading "/command" word of content, or "".
+func messageCommand(content string) string {
+	content = strings.TrimLeftFunc(content, unicode.IsSpace)
+	if !strings.HasPrefix(content, "/") {
+		return ""
+	}
+	if i := strings.IndexFunc(content, unicode.IsSpace); i >= 0 {
+		return content[:i]
+	}
+	return content
 }
 
 // hasSkill checks if a node advertises a given skill or tag.
@@ -210,31 +722,74 @@ func hasSkill(n *types.Node, skill string) bool {
 	return false
 }
 
-// applyStrategy selects a node using the named strategy.
-func (rt *Router) applyStrategy(strategy string, nodes []*types.Node) (*types.Node, error) {
+// applyStrategy selects a node from nodes using the rule's strategy, or
+// its affinity table when the rule has affinity and msg carries a key.
+// When tracing, stateful strategies and the affinity table are only peeked.
+func (rt *Router) applyStrategy(rule *types.RoutingRule, msg *types.Message, nodes []*types.Node, tr *routeTracer) (*types.Node, error) {
 	if len(nodes) == 0 {
-		return nil, fmt.Errorf("no nodes available for strategy %q", strategy)
+		return nil, fmt.Errorf("no nodes available for strategy %q", rule.Strategy)
 	}
-	// Default and "least-busy" both use the same logic.
-	return leastBusy(nodes), nil
+	if key := affinityKey(rule.Affinity, msg); key != "" {
+		var n *types.Node
+		if tr != nil {
+			n = rt.affinity.lookup(rule.ID, rule.Affinity, key, nodes)
+		} else {
+			n = rt.affinity.pick(rule.ID, rule.Affinity, key, nodes)
+		}
+		tr.chose(rule, n, "affinity:"+rule.Affinity)
+		return n, nil
+	}
+	st := rt.Strategy(rule.Strategy)
+	if st == nil {
+		return nil, fmt.Errorf("unknown strategy %q", rule.Strategy)
+	}
+	var n *types.Node
+	if p, ok := st.(peeker); ok && tr != nil {
+		n = p.Peek(rule.ID, nodes)
+	} else {
+		n = st.Pick(rule.ID, nodes)
+	}
+	tr.chose(rule, n, "strategy:"+st.Name())
+	return n, nil
 }
 
-// leastBusy picks the node that is least loaded.
-// Prefers "online" over "busy".
+// leastBusy picks the node with the lowest load score. Ties are broken
+// by node ID so the choice doesn't depend on registry map order.
 func leastBusy(nodes []*types.Node) *types.Node {
 	var best *types.Node
+	var bestScore float64
 	for _, n := range nodes {
-		if best == nil {
-			best = n
-			continue
-		}
-		if statusPriority(n.Status) < statusPriority(best.Status) {
-			best = n
+		score := loadScore(n)
+		if best == nil || score < bestScore || (score == bestScore && n.ID < best.ID) {
+			best, bestScore = n, score
 		}
 	}
 	return best
 }
 
+// loadScore estimates how loaded a node is (lower = more available).
+// It counts outstanding messages — the largest of the coordinator's own
+// in-flight forwards, the node's reported in-flight count and its gateway
+// queue, which all count the same requests — and adds CPU load per core,
+// a penalty for a busy status, and a penalty when free memory is low.
+func loadScore(n *types.Node) float64 {
+	work := n.InFlight
+	score := float64(statusPriority(n.Status))
+	if l := n.Load; l != nil {
+		work = max(work, l.InFlight, l.QueueDepth)
+		if l.CPUs > 0 {
+			score += l.LoadAvg / float64(l.CPUs)
+		}
+		if l.FreeMemoryMB > 0 && l.FreeMemoryMB < lowMemoryMB {
+			score++
+		}
+	}
+	return score + float64(work)
+}
+
+// lowMemoryMB is the free memory below which a node is penalized.
+const lowMemoryMB = 512
+
 // statusPriority returns a numeric priority (lower = more available).
 func statusPriority(s types.NodeStatus) int {
 	switch s {
@@ -0,0 +1,432 @@
+package coordinator
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+func TestLeastBusy_UsesLoadScore(t *testing.T) {
+	nodes := []*types.Node{
+		{ID: "node-c", Status: types.NodeStatusOnline, Load: &types.NodeLoad{QueueDepth: 3, CPUs: 4}},
+		{ID: "node-b", Status: types.NodeStatusOnline, InFlight: 1, Load: &types.NodeLoad{InFlight: 1, QueueDepth: 1, LoadAvg: 2, CPUs: 8}},
+		{ID: "node-a", Status: types.NodeStatusOnline, Load: &types.NodeLoad{LoadAvg: 7.5, CPUs: 4}},
+		{ID: "node-d", Status: types.NodeStatusBusy},
+	}
+	// Scores: c=3, b=1.25 (its in-flight message is counted once), a=1.875, d=1.
+	if got := leastBusy(nodes); got.ID != "node-d" {
+		t.Fatalf("expected node-d, got %s", got.ID)
+	}
+	if got := leastBusy(nodes[:3]); got.ID != "node-b" {
+		t.Fatalf("expected node-b, got %s", got.ID)
+	}
+}
+
+func TestLeastBusy_TieBreaksByID(t *testing.T) {
+	reg := NewRegistry(nil)
+	for _, id := range []string{"node-z", "node-m", "node-q"} {
+		reg.Add(&types.Node{ID: id, Status: types.NodeStatusOnline})
+	}
+	// Registry listing order is random; the pick must not be.
+	for i := 0; i < 20; i++ {
+		if got := leastBusy(reg.List()); got.ID != "node-m" {
+			t.Fatalf("expected deterministic pick node-m, got %s", got.ID)
+		}
+	}
+}
+
+type fakeLatency map[string]time.Duration
+
+func (f fakeLatency) Latency(id string) (time.Duration, bool) {
+	d, ok := f[id]
+	return d, ok
+}
+
+func TestStrategies(t *testing.T) {
+	nodes := []*types.Node{
+		{ID: "node-b", Labels: map[string]string{"weight": "0"}},
+		{ID: "node-a", Labels: map[string]string{"weight": "3"}},
+		{ID: "node-c"},
+	}
+
+	rr := &roundRobinStrategy{next: make(map[string]uint64)}
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, rr.Pick("rule-1", nodes).ID)
+	}
+	if strings.Join(got, ",") != "node-a,node-b,node-c,node-a" {
+		t.Errorf("round-robin order = %v", got)
+	}
+
+	for i := 0; i < 50; i++ {
+		if n := (weightedStrategy{}).Pick("", nodes); n.ID == "node-b" {
+			t.Fatal("weighted picked a node with weight 0")
+		}
+	}
+
+	lat := &latencyStrategy{source: fakeLatency{"node-a": 300 * time.Millisecond, "node-b": 100 * time.Millisecond}}
+	if n := lat.Pick("", nodes); n.ID != "node-c" {
+		t.Errorf("latency should try unmeasured node-c first, got %s", n.ID)
+	}
+	if n := lat.Pick("", nodes[:2]); n.ID != "node-b" {
+		t.Errorf("latency should pick fastest node-b, got %s", n.ID)
+	}
+}
+
+func TestRemoveRule_ForgetsRoundRobin(t *testing.T) {
+	reg := NewRegistry(nil)
+	reg.Add(&types.Node{ID: "node-a", Status: types.NodeStatusOnline})
+	rt := NewRouter(reg)
+	wild := true
+	rule := &types.RoutingRule{Match: types.MatchCriteria{Wildcard: &wild}, Strategy: "round-robin"}
+	if err := rt.AddRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rt.Route(&types.Message{Content: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	rr := rt.Strategy("round-robin").(*roundRobinStrategy)
+	if _, ok := rr.next[rule.ID]; !ok {
+		t.Fatal("expected a round-robin counter for the rule")
+	}
+	if _, err := rt.RemoveRule(rule.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(rr.next) != 0 {
+		t.Fatalf("expected the counter to be dropped with the rule, got %v", rr.next)
+	}
+}
+
+func TestValidateRule_Strategy(t *testing.T) {
+	rt := NewRouter(NewRegistry(nil))
+	wildcard := true
+	rule := &types.RoutingRule{Match: types.MatchCriteria{Wildcard: &wildcard}, Strategy: "round-robin"}
+	if err := validateRule(rule, rt.StrategyNames()); err != nil {
+		t.Fatalf("round-robin should be valid: %v", err)
+	}
+	rule.Strategy = "fastest"
+	if err := validateRule(rule, rt.StrategyNames()); err == nil {
+		t.Fatal("expected unknown strategy to be rejected")
+	}
+}
+
+func TestRoute_SessionAffinity(t *testing.T) {
+	reg := NewRegistry(nil)
+	for _, id := range []string{"node-a", "node-b", "node-c"} {
+		reg.Add(&types.Node{ID: id, Status: types.NodeStatusOnline})
+	}
+	rt := NewRouter(reg)
+	wild := true
+	if err := rt.AddRule(&types.RoutingRule{Match: types.MatchCriteria{Wildcard: &wild}, Affinity: affinitySession}); err != nil {
+		t.Fatal(err)
+	}
+
+	route := func(session string) string {
+		n, err := rt.Route(&types.Message{Content: "hi", SessionID: session})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n.ID
+	}
+
+	pinned := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		s := fmt.Sprintf("s%d", i)
+		pinned[s] = route(s)
+		// Load changes must not move a pinned session.
+		reg.TryBeginForward(pinned[s])
+		if got := route(s); got != pinned[s] {
+			t.Fatalf("session %s moved from %s to %s", s, pinned[s], got)
+		}
+	}
+
+	reg.UpdateStatus("node-b", types.NodeStatusOffline)
+	for s, was := range pinned {
+		got := route(s)
+		if was != "node-b" && got != was {
+			t.Errorf("session %s moved from %s to %s though its node is healthy", s, was, got)
+		}
+		if got == "node-b" {
+			t.Errorf("session %s still routed to offline node", s)
+		}
+	}
+	for _, e := range rt.affinity.list() {
+		if (pinned[e.Key] == "node-b") != (e.Reassignments == 1) {
+			t.Errorf("entry %s: reassignments = %d", e.Key, e.Reassignments)
+		}
+	}
+}
+
+func TestRoute_MessagePredicates(t *testing.T) {
+	reg := NewRegistry(nil)
+	reg.Add(&types.Node{ID: "mac", Name: "mac", Status: types.NodeStatusOnline, Capabilities: types.Capabilities{OS: "darwin"}})
+	reg.Add(&types.Node{ID: "gpu", Name: "gpu", Status: types.NodeStatusOnline, Capabilities: types.Capabilities{OS: "linux", GPU: true}})
+	reg.Add(&types.Node{ID: "box", Name: "box", Status: types.NodeStatusOnline, Capabilities: types.Capabilities{OS: "linux"}})
+	rt := NewRouter(reg)
+	yes := true
+	for _, r := range []*types.RoutingRule{
+		{Match: types.MatchCriteria{Keywords: []string{"xcode", "swiftui"}, RequiresOS: "darwin"}},
+		{Match: types.MatchCriteria{Command: "/gpu", RequiresGPU: &yes}},
+		{Match: types.MatchCriteria{Source: "slack", MinLength: 10}, Target: "mac"},
+		{Match: types.MatchCriteria{ContentRegex: `^deploy\s+\w+$`}, Target: "box"},
+	} {
+		if err := validateRule(r, rt.StrategyNames()); err != nil {
+			t.Fatalf("validateRule: %v", err)
+		}
+		if err := rt.AddRule(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		content, source, want string
+	}{
+		{"Why does XCode crash?", "cli", "mac"},
+		{"/gpu render the scene", "cli", "gpu"},
+		{"/gpus are great", "cli", "box"}, // not the /gpu command; falls back
+		{"a long slack message", "slack", "mac"},
+		{"short", "slack", "box"},
+		{"deploy api", "cli", "box"},
+	}
+	for _, tt := range tests {
+		n, err := rt.Route(&types.Message{Content: tt.content, Source: tt.source})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The fallback is least-busy: all idle, so the lowest ID ("box").
+		if n.ID != tt.want {
+			t.Errorf("%q from %s routed to %s, want %s", tt.content, tt.source, n.ID, tt.want)
+		}
+	}
+
+	bad := []types.MatchCriteria{
+		{ContentRegex: "("},
+		{Command: "gpu"},
+		{MinLength: 10, MaxLength: 5},
+		{Keywords: []string{" "}},
+	}
+	for _, mc := range bad {
+		if err := validateRule(&types.RoutingRule{Match: mc}, nil); err == nil {
+			t.Errorf("expected error for %+v", mc)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	reg := NewRegistry(nil)
+	reg.Add(&types.Node{ID: "a", Name: "mac", Status: types.NodeStatusOnline, Capabilities: types.Capabilities{OS: "darwin"}})
+	reg.Add(&types.Node{ID: "b", Name: "linux", Status: types.NodeStatusOnline, Capabilities: types.Capabilities{OS: "linux"}})
+	reg.Add(&types.Node{ID: "c", Name: "old", Status: types.NodeStatusOffline})
+	rt := NewRouter(reg)
+	yes := true
+	rules := []*types.RoutingRule{
+		{Match: types.MatchCriteria{Command: "/gpu"}},
+		{Match: types.MatchCriteria{RequiresGPU: &yes}},
+		{Match: types.MatchCriteria{RequiresOS: "linux"}, Target: "mac"},
+		{Match: types.MatchCriteria{Wildcard: &yes}, Strategy: "round-robin"},
+	}
+	for _, r := range rules {
+		if err := rt.AddRule(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tr := rt.Explain(&types.Message{Content: "hello"})
+	if tr.Fallback || tr.RuleID != rules[3].ID || tr.ChosenBy != "strategy:round-robin" || tr.NodeID != "a" {
+		t.Fatalf("unexpected decision: %+v", tr)
+	}
+	if len(tr.Unavailable) != 1 || tr.Unavailable[0].NodeID != "c" || tr.Unavailable[0].Reason != "offline" {
+		t.Errorf("unavailable = %+v", tr.Unavailable)
+	}
+	want := []struct {
+		matched bool
+		reason  string
+	}{
+		{false, "not a /gpu command"},
+		{false, "no eligible node matches"},
+		{false, `target "mac" is not a candidate`},
+		{true, ""},
+	}
+	if len(tr.Rules) != len(want) {
+		t.Fatalf("expected %d rule traces, got %d", len(want), len(tr.Rules))
+	}
+	for i, w := range want {
+		if tr.Rules[i].Matched != w.matched || tr.Rules[i].Reason != w.reason {
+			t.Errorf("rule %d: matched=%v reason=%q", i, tr.Rules[i].Matched, tr.Rules[i].Reason)
+		}
+	}
+	if ex := tr.Rules[1].Excluded; len(ex) != 2 || ex[0].Reason != "no GPU" {
+		t.Errorf("rule 1 exclusions = %+v", ex)
+	}
+
+	// A dry run must not advance round-robin.
+	tr = rt.Explain(&types.Message{Content: "hello"})
+	if tr.NodeID != "a" {
+		t.Errorf("explain advanced round-robin: got %s", tr.NodeID)
+	}
+	if n, _ := rt.Route(&types.Message{Content: "hello"}); n.ID != "a" {
+		t.Errorf("route = %s, want a", n.ID)
+	}
+
+	tr = rt.Explain(&types.Message{Content: "hi", TargetNode: "c"})
+	if tr.Error == "" {
+		t.Error("expected error for offline target")
+	}
+}
+
+func TestRouteWithFailover(t *testing.T) {
+	reg := NewRegistry(nil)
+	reg.Add(&types.Node{ID: "a", Name: "a", Status: types.NodeStatusOnline, Capabilities: types.Capabilities{GPU: true}})
+	reg.Add(&types.Node{ID: "b", Name: "b", Status: types.NodeStatusBusy, Capabilities: types.Capabilities{GPU: true}})
+	reg.Add(&types.Node{ID: "c", Name: "c", Status: types.NodeStatusOnline, Capabilities: types.Capabilities{GPU: true}, InFlight: 1})
+	reg.Add(&types.Node{ID: "d", Name: "d", Status: types.NodeStatusOnline})
+	rt := NewRouter(reg)
+	yes := true
+	rule := &types.RoutingRule{Match: types.MatchCriteria{RequiresGPU: &yes}}
+	if err := rt.AddRule(rule); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func() string {
+		nodes, err := rt.RouteWithFailover(&types.Message{Content: "hi"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, n := range nodes {
+			out = append(out, n.ID)
+		}
+		return strings.Join(out, ",")
+	}
+	// Only the rule's candidates, least busy first; "d" has no GPU.
+	if got := ids(); got != "a,b,c" {
+		t.Errorf("failover order = %s, want a,b,c", got)
+	}
+	rule.MaxNodes = 2
+	if got := ids(); got != "a,b" {
+		t.Errorf("with max_nodes 2 = %s, want a,b", got)
+	}
+}
+
+func TestRoute_SkipsCordonedAndDraining(t *testing.T) {
+	reg := NewRegistry(nil)
+	for _, id := range []string{"a", "b", "c"} {
+		reg.Add(&types.Node{ID: id, Name: id, Status: types.NodeStatusOnline})
+	}
+	reg.SetMaintenance("a", types.MaintenanceCordoned)
+	reg.SetMaintenance("b", types.MaintenanceDraining)
+	rt := NewRouter(reg)
+	wild := true
+	if err := rt.AddRule(&types.RoutingRule{Match: types.MatchCriteria{Wildcard: &wild}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Auto-routing, by rule or failover list, only reaches "c".
+	if n, err := rt.Route(&types.Message{Content: "hi"}); err != nil || n.ID != "c" {
+		t.Fatalf("Route = %v, %v; want c", n, err)
+	}
+	nodes, err := rt.RouteWithFailover(&types.Message{Content: "hi"})
+	if err != nil || len(nodes) != 1 || nodes[0].ID != "c" {
+		t.Fatalf("RouteWithFailover = %v, %v; want only c", nodes, err)
+	}
+
+	// With every node out of rotation, auto-routing fails...
+	reg.SetMaintenance("c", types.MaintenanceCordoned)
+	if _, err := rt.Route(&types.Message{Content: "hi"}); err == nil {
+		t.Fatal("expected auto-routing to fail with every node cordoned")
+	}
+	if _, err := rt.RouteWithFailover(&types.Message{Content: "hi"}); err == nil {
+		t.Fatal("expected failover routing to fail with every node cordoned")
+	}
+	// ...but a message for a specific node still reaches it.
+	for _, id := range []string{"a", "b"} {
+		if n, err := rt.Route(&types.Message{Content: "hi", TargetNode: id}); err != nil || n.ID != id {
+			t.Errorf("Route to %s = %v, %v", id, n, err)
+		}
+	}
+}
+
+func TestRouter_UpdateReorderDisable(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "rules.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := NewRegistry(nil)
+	reg.Add(&types.Node{ID: "a", Name: "a", Status: types.NodeStatusOnline, Labels: map[string]string{"pool": "a"}})
+	reg.Add(&types.Node{ID: "b", Name: "b", Status: types.NodeStatusOnline, Labels: map[string]string{"pool": "b"}})
+	rt := NewRouter(reg, store)
+	first := &types.RoutingRule{Match: types.MatchCriteria{LabelSelector: "pool=a"}}
+	second := &types.RoutingRule{Match: types.MatchCriteria{LabelSelector: "pool=b"}}
+	rt.AddRule(first)
+	rt.AddRule(second)
+	route := func() string {
+		n, err := rt.Route(&types.Message{Content: "hi"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n.ID
+	}
+	if got := route(); got != "a" {
+		t.Fatalf("expected the first rule to win, got %s", got)
+	}
+
+	_, rev := rt.RuleSet()
+	if _, err := rt.ReorderRules([]string{second.ID}, rev); !errors.Is(err, errInvalidOrder) {
+		t.Fatalf("expected an incomplete order to be rejected, got %v", err)
+	}
+	rev, err = rt.ReorderRules([]string{second.ID, first.ID}, rev)
+	if err != nil {
+		t.Fatalf("ReorderRules: %v", err)
+	}
+	if got := route(); got != "b" {
+		t.Fatalf("expected the moved rule to win, got %s", got)
+	}
+
+	// A change based on an old revision is refused.
+	edited := *second
+	edited.Disabled = true
+	if _, err := rt.UpdateRule(&edited, rev-1); !errors.Is(err, errStaleRevision) {
+		t.Fatalf("expected a stale revision error, got %v", err)
+	}
+	if rev, err = rt.UpdateRule(&edited, rev); err != nil {
+		t.Fatalf("UpdateRule: %v", err)
+	}
+	if got := route(); got != "a" {
+		t.Fatalf("expected the disabled rule to be skipped, got %s", got)
+	}
+
+	// Order, flags and revision survive a restart.
+	restored := NewRouter(reg, store)
+	rules, gotRev := restored.RuleSet()
+	if gotRev != rev || len(rules) != 2 || rules[0].ID != second.ID || !rules[0].Disabled {
+		t.Fatalf("unexpected restored rules at revision %d: %+v", gotRev, rules)
+	}
+}
+
+func TestRoute_TargetRule(t *testing.T) {
+	reg := NewRegistry(nil)
+	reg.Add(&types.Node{ID: "a", Name: "a", Status: types.NodeStatusOnline})
+	reg.Add(&types.Node{ID: "build", Name: "build", Status: types.NodeStatusOnline, Labels: map[string]string{"role": "ci"}})
+	rt := NewRouter(reg)
+	ci := &types.RoutingRule{Match: types.MatchCriteria{Keywords: []string{"deploy"}, LabelSelector: "role=ci"}}
+	rt.AddRule(ci)
+
+	// The pinned rule applies even though its keyword is not in the message.
+	n, err := rt.Route(&types.Message{Content: "summarize CI failures", TargetRule: ci.ID})
+	if err != nil || n.ID != "build" {
+		t.Fatalf("expected the pinned rule to pick build, got %v, %v", n, err)
+	}
+
+	reg.UpdateStatus("build", types.NodeStatusOffline)
+	if _, err := rt.Route(&types.Message{Content: "hi", TargetRule: ci.ID}); err == nil {
+		t.Fatal("expected no fallback when the pinned rule selects no node")
+	}
+	if _, err := rt.Route(&types.Message{Content: "hi", TargetRule: "rule-missing"}); err == nil {
+		t.Fatal("expected an error for an unknown rule")
+	}
+}
@@ -0,0 +1,283 @@
+package coordinator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+// rulesReloadDelay debounces bursts of file events, e.g. an editor that
+// truncates, writes and renames in quick succession.
+const rulesReloadDelay = 200 * time.Millisecond
+
+// ParseRules parses a YAML rule set. The document is either a list of
+// rules or a mapping with a "rules" list; other keys are ignored, so the
+// rules can live in claw-mesh.yaml itself. Unknown rule fields are errors.
+func ParseRules(data []byte) ([]*types.RoutingRule, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("empty document")
+	}
+	list := doc.Content[0]
+	if list.Kind == yaml.MappingNode {
+		list = nil
+		for i := 0; i+1 < len(doc.Content[0].Content); i += 2 {
+			if doc.Content[0].Content[i].Value == "rules" {
+				list = doc.Content[0].Content[i+1]
+				break
+			}
+		}
+		if list == nil {
+			return nil, fmt.Errorf("no rules list")
+		}
+	}
+	if list.Kind == yaml.ScalarNode && list.Tag == "!!null" {
+		return nil, nil
+	}
+	if list.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("line %d: rules must be a list", list.Line)
+	}
+
+	// yaml.Node.Decode cannot reject unknown fields, so re-encode the list
+	// and decode it strictly.
+	raw, err := yaml.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	var rules []*types.RoutingRule
+	if err := dec.Decode(&rules); err != nil {
+		return nil, err
+	}
+	for i, r := range rules {
+		if r == nil {
+			return nil, fmt.Errorf("rule %d is empty", i+1)
+		}
+	}
+	return rules, nil
+}
+
+// validateRuleSet checks every rule of a set, including that target
+// groups exist and IDs are unique.
+func validateRuleSet(rules []*types.RoutingRule, strategies []string, groups *GroupSet) error {
+	seen := make(map[string]bool, len(rules))
+	for i, r := range rules {
+		if err := validateRule(r, strategies); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		if r.TargetGroup != "" && groups.Get(r.TargetGroup) == nil {
+			return fmt.Errorf("rule %d: unknown target group %q", i+1, r.TargetGroup)
+		}
+		if r.ID != "" {
+			if seen[r.ID] {
+				return fmt.Errorf("rule %d: duplicate id %q", i+1, r.ID)
+			}
+			seen[r.ID] = true
+		}
+	}
+	return nil
+}
+
+// assignRuleIDs gives rules without an ID one derived from their content,
+// so that an unchanged rule keeps its ID (and its affinity entries) across
+// reloads. Identical rules are told apart by a numeric suffix.
+func assignRuleIDs(rules []*types.RoutingRule) {
+	taken := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		if r.ID != "" {
+			taken[r.ID] = true
+		}
+	}
+	for _, r := range rules {
+		if r.ID != "" {
+			continue
+		}
+		data, _ := json.Marshal(r)
+		sum := sha256.Sum256(data)
+		base := fmt.Sprintf("rule-%x", sum[:8])
+		id := base
+		for n := 2; taken[id]; n++ {
+			id = fmt.Sprintf("%s-%d", base, n)
+		}
+		r.ID = id
+		taken[id] = true
+	}
+}
+
+// rulesFile keeps the router's rules in sync with a YAML file. A changed
+// file is validated as a whole before it replaces the rules; a rejected
+// file leaves the current rules in place.
+type rulesFile struct {
+	path   string
+	router *Router
+	groups *GroupSet
+	events *EventBus
+
+	mu      sync.Mutex
+	status  types.RulesFileStatus
+	watcher *fsnotify.Watcher
+	timer   *time.Timer
+}
+
+func newRulesFile(path string, router *Router, groups *GroupSet, events *EventBus) *rulesFile {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return &rulesFile{
+		path:   path,
+		router: router,
+		groups: groups,
+		events: events,
+		status: types.RulesFileStatus{Path: path},
+	}
+}
+
+// load reads, parses and validates the file without applying it.
+func (rf *rulesFile) load() ([]*types.RoutingRule, error) {
+	data, err := os.ReadFile(rf.path)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := ParseRules(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateRuleSet(rules, rf.router.StrategyNames(), rf.groups); err != nil {
+		return nil, err
+	}
+	assignRuleIDs(rules)
+	return rules, nil
+}
+
+// reload applies the current contents of the file, or records why they
+// were rejected.
+func (rf *rulesFile) reload() error {
+	rules, err := rf.load()
+	if err == nil {
+		_, err = rf.router.ReplaceRules(rules, 0)
+	}
+	now := time.Now()
+
+	rf.mu.Lock()
+	if err != nil {
+		rf.status.LastError = err.Error()
+		rf.status.FailedAt = &now
+	} else {
+		rf.status.Rules = len(rules)
+		rf.status.LoadedAt = &now
+		rf.status.LastError = ""
+		rf.status.FailedAt = nil
+	}
+	rf.mu.Unlock()
+
+	if err != nil {
+		log.Printf("WARN: rejected rules file %s, keeping current rules: %v", rf.path, err)
+		rf.events.Publish(types.Event{Type: types.EventRulesRejected, Data: map[string]any{"path": rf.path, "error": err.Error()}})
+		return err
+	}
+	log.Printf("loaded %d routing rules from %s", len(rules), rf.path)
+	return nil
+}
+
+// watch reloads the file whenever it changes. It watches the directory
+// rather than the file so that editors that replace the file by renaming
+// a new one over it are followed.
+func (rf *rulesFile) watch() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := w.Add(filepath.Dir(rf.path)); err != nil {
+		w.Close()
+		return err
+	}
+	rf.mu.Lock()
+	rf.watcher = w
+	rf.mu.Unlock()
+
+	go func() {
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(ev.Name) == rf.path && !ev.Has(fsnotify.Chmod) {
+					rf.scheduleReload()
+				}
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("WARN: watching rules file %s: %v", rf.path, err)
+			}
+		}
+	}()
+	return nil
+}
+
+func (rf *rulesFile) scheduleReload() {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.watcher == nil {
+		return
+	}
+	if rf.timer != nil {
+		rf.timer.Stop()
+	}
+	rf.timer = time.AfterFunc(rulesReloadDelay, func() { rf.reload() })
+}
+
+// stop ends watching. A reload already scheduled is cancelled.
+func (rf *rulesFile) stop() {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.timer != nil {
+		rf.timer.Stop()
+	}
+	if rf.watcher != nil {
+		rf.watcher.Close()
+		rf.watcher = nil
+	}
+}
+
+func (rf *rulesFile) currentStatus() types.RulesFileStatus {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.status
+}
+
+// rulesManagedByFile writes a 409 and returns true if the rules come from
+// a file and so cannot be changed through the API.
+func (s *Server) rulesManagedByFile(w http.ResponseWriter) bool {
+	if s.rulesFile == nil {
+		return false
+	}
+	writeJSON(w, http.StatusConflict, map[string]string{"error": fmt.Sprintf("routing rules are managed by %s; edit the file instead", s.rulesFile.path)})
+	return true
+}
+
+// handleRulesFileStatus handles GET /api/v1/rules/file.
+func (s *Server) handleRulesFileStatus(w http.ResponseWriter, r *http.Request) {
+	if s.rulesFile == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "routing rules are not loaded from a file"})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.rulesFile.currentStatus())
+}
@@ -0,0 +1,97 @@
+package coordinator
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/config"
+)
+
+func TestParseRules(t *testing.T) {
+	list := "- match: {requires_os: linux}\n  strategy: round-robin\n- match: {wildcard: true}\n"
+	embedded := "coordinator:\n  port: 9180\nrules:\n" + list
+	for name, doc := range map[string]string{"list": list, "embedded": embedded} {
+		rules, err := ParseRules([]byte(doc))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(rules) != 2 || rules[0].Match.RequiresOS != "linux" || rules[0].Strategy != "round-robin" {
+			t.Fatalf("%s: unexpected rules %+v", name, rules)
+		}
+	}
+
+	for _, doc := range []string{
+		"coordinator: {port: 9180}\n",
+		"rules: {requires_os: linux}\n",
+		"rules:\n- match: {requires_os: linux}\n  stratgy: random\n",
+	} {
+		if _, err := ParseRules([]byte(doc)); err == nil {
+			t.Errorf("expected an error for %q", doc)
+		}
+	}
+}
+
+func TestRulesFile_Reload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "routes.yaml")
+	write := func(doc string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(doc), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("rules:\n- match: {requires_os: linux}\n- match: {wildcard: true}\n")
+
+	srv := NewServer(&config.CoordinatorConfig{DataDir: dir, RulesFile: path})
+	rules := srv.router.ListRules()
+	if len(rules) != 2 || !strings.HasPrefix(rules[0].ID, "rule-") {
+		t.Fatalf("expected 2 rules with derived IDs, got %+v", rules)
+	}
+	firstID := rules[0].ID
+
+	// The API cannot change file-managed rules.
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/rules", strings.NewReader(`{"match":{"requires_os":"darwin"}}`))
+	rr := httptest.NewRecorder()
+	srv.handleAddRule(rr, r)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for POST in file mode, got %d", rr.Code)
+	}
+
+	// An invalid file is rejected as a whole and the rules stay.
+	write("rules:\n- match: {requires_os: linux}\n- match: {wildcard: true}\n  strategy: fastest\n")
+	if err := srv.rulesFile.reload(); err == nil || !strings.Contains(err.Error(), "rule 2") {
+		t.Fatalf("expected rule 2 to be rejected, got %v", err)
+	}
+	if got := srv.router.ListRules(); len(got) != 2 {
+		t.Fatalf("expected the old rules to be kept, got %+v", got)
+	}
+	if st := srv.rulesFile.currentStatus(); st.LastError == "" || st.Rules != 2 {
+		t.Fatalf("expected the rejection in the status, got %+v", st)
+	}
+
+	// A change on disk is picked up by the watcher; the unchanged rule
+	// keeps its ID.
+	if err := srv.rulesFile.watch(); err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	defer srv.rulesFile.stop()
+	write("- match: {requires_os: linux}\n- match: {requires_os: darwin}\n- match: {wildcard: true}\n")
+	deadline := time.Now().Add(5 * time.Second)
+	for len(srv.router.ListRules()) != 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("rules file was not reloaded, have %+v", srv.router.ListRules())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if got := srv.router.ListRules()[0].ID; got != firstID {
+		t.Errorf("expected unchanged rule to keep ID %s, got %s", firstID, got)
+	}
+	if st := srv.rulesFile.currentStatus(); st.LastError != "" || st.Rules != 3 {
+		t.Errorf("expected a clean status after reload, got %+v", st)
+	}
+}
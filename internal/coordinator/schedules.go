@@ -0,0 +1,724 @@
+package coordinator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+const (
+	defaultKeepRuns = 10
+	maxKeepRuns     = 100
+	// minScheduleInterval keeps interval schedules from flooding the mesh.
+	minScheduleInterval = 10
+	// maxScheduleInterval (a year, in seconds) keeps the interval well
+	// within a time.Duration.
+	maxScheduleInterval = 365 * 24 * 60 * 60
+	// scheduleIdleWait is how long the scheduler sleeps with nothing due.
+	scheduleIdleWait = time.Hour
+)
+
+// Errors from scheduler.
+var (
+	errScheduleNotFound = errors.New("schedule not found")
+	errScheduleExists   = errors.New("a schedule with that name already exists")
+)
+
+// ScheduleStore provides persistent storage for schedules and their
+// recent runs.
+type ScheduleStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// scheduleStoreData is the on-disk JSON structure.
+type scheduleStoreData struct {
+	Schedules []*types.Schedule `json:"schedules"`
+}
+
+// NewScheduleStore creates a schedule store backed by the given file path.
+// The parent directory is created if it doesn't exist.
+func NewScheduleStore(path string) (*ScheduleStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("creating store directory: %w", err)
+	}
+	return &ScheduleStore{path: path}, nil
+}
+
+// LoadSchedules reads schedules from disk.
+// Returns an empty slice if the file doesn't exist.
+func (s *ScheduleStore) LoadSchedules() ([]*types.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading schedule store: %w", err)
+	}
+	var sd scheduleStoreData
+	if err := json.Unmarshal(data, &sd); err != nil {
+		return nil, fmt.Errorf("parsing schedule store: %w", err)
+	}
+	return sd.Schedules, nil
+}
+
+// SaveSchedules writes schedules to disk atomically.
+func (s *ScheduleStore) SaveSchedules(schedules []*types.Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(scheduleStoreData{Schedules: schedules}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling schedule store: %w", err)
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// scheduleVars are the fields a schedule's content template can use.
+type scheduleVars struct {
+	Name string    // schedule name
+	Run  int       // run number, from 1
+	Now  time.Time // firing time, in the schedule's time zone
+	Date string    // Now as 2006-01-02
+	Time string    // Now as 15:04
+}
+
+// scheduleRunner delivers one run's message.
+type scheduleRunner func(ctx context.Context, msg *types.Message) (*types.MessageResponse, error)
+
+// schedule is a schedule with its compiled spec.
+type schedule struct {
+	types.Schedule // guarded by scheduler.mu
+
+	cron    *cronExpr // nil for interval schedules
+	loc     *time.Location
+	tmpl    *template.Template
+	running map[string]context.CancelFunc // by message ID
+}
+
+// compileSchedule validates a spec and compiles its timing and template.
+func compileSchedule(spec *types.ScheduleSpec) (*schedule, error) {
+	if len(spec.Name) > maxLabelName || !labelNameRe.MatchString(spec.Name) {
+		return nil, fmt.Errorf("invalid schedule name %q", spec.Name)
+	}
+	if strings.TrimSpace(spec.Content) == "" {
+		return nil, fmt.Errorf("content is required")
+	}
+	if spec.TargetNode != "" && spec.TargetRule != "" {
+		return nil, fmt.Errorf("set target_node or target_rule, not both")
+	}
+	switch spec.Overlap {
+	case "", types.OverlapSkip, types.OverlapAllow, types.OverlapReplace:
+	default:
+		return nil, fmt.Errorf("invalid overlap policy %q (want skip, allow or replace)", spec.Overlap)
+	}
+	if spec.KeepRuns < 0 || spec.KeepRuns > maxKeepRuns {
+		return nil, fmt.Errorf("keep_runs must be between 0 and %d", maxKeepRuns)
+	}
+
+	sc := &schedule{loc: time.Local, running: make(map[string]context.CancelFunc)}
+	if spec.Timezone != "" {
+		loc, err := time.LoadLocation(spec.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q", spec.Timezone)
+		}
+		sc.loc = loc
+	}
+	switch {
+	case spec.Cron != "" && spec.IntervalSeconds != 0:
+		return nil, fmt.Errorf("set cron or interval_seconds, not both")
+	case spec.Cron != "":
+		c, err := parseCron(spec.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression: %w", err)
+		}
+		if c.next(time.Now().In(sc.loc)).IsZero() {
+			return nil, fmt.Errorf("cron expression %q never fires", spec.Cron)
+		}
+		sc.cron = c
+	case spec.IntervalSeconds >= minScheduleInterval && spec.IntervalSeconds <= maxScheduleInterval:
+	case spec.IntervalSeconds != 0:
+		return nil, fmt.Errorf("interval_seconds must be between %d and %d", minScheduleInterval, maxScheduleInterval)
+	default:
+		return nil, fmt.Errorf("cron or interval_seconds is required")
+	}
+
+	tmpl, err := template.New(spec.Name).Option("missingkey=error").Parse(spec.Content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid content template: %w", err)
+	}
+	sc.tmpl = tmpl
+	sc.ScheduleSpec = *spec
+	return sc, nil
+}
+
+// nextAfter returns when the schedule fires next after t.
+func (sc *schedule) nextAfter(t time.Time) time.Time {
+	if sc.cron != nil {
+		return sc.cron.next(t.In(sc.loc))
+	}
+	return t.Add(time.Duration(sc.IntervalSeconds) * time.Second)
+}
+
+// setNextLocked schedules the next run after now, or none if disabled.
+func (sc *schedule) setNextLocked(now time.Time) {
+	sc.NextRunAt = nil
+	if sc.Disabled {
+		return
+	}
+	if next := sc.nextAfter(now); !next.IsZero() {
+		sc.NextRunAt = &next
+	}
+}
+
+func (sc *schedule) keepRuns() int {
+	if sc.KeepRuns > 0 {
+		return sc.KeepRuns
+	}
+	return defaultKeepRuns
+}
+
+// snapshot returns a copy of the schedule's state.
+func (sc *schedule) snapshot() *types.Schedule {
+	cp := sc.Schedule
+	cp.Runs = slices.Clone(sc.Runs)
+	if cp.Runs == nil {
+		cp.Runs = []types.ScheduleRun{}
+	}
+	return &cp
+}
+
+// scheduler fires schedules and keeps their last runs. Missed firings
+// (e.g. while the coordinator was down) are not caught up: a schedule
+// next fires at its first time after startup.
+type scheduler struct {
+	store  *ScheduleStore
+	events *EventBus
+	run    scheduleRunner
+
+	mu        sync.Mutex
+	schedules []*schedule // in creation order
+
+	wake      chan struct{}
+	ctx       context.Context // cancelled by stop
+	cancel    context.CancelFunc
+	startOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// newScheduler creates a scheduler that delivers messages with run.
+// If store is non-nil, schedules are loaded from and persisted to disk.
+func newScheduler(run scheduleRunner, events *EventBus, store *ScheduleStore) *scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &scheduler{
+		store:  store,
+		events: events,
+		run:    run,
+		wake:   make(chan struct{}, 1),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	if store == nil {
+		return s
+	}
+	saved, err := store.LoadSchedules()
+	if err != nil {
+		log.Printf("WARN: failed to load persisted schedules: %v", err)
+	}
+	now := time.Now()
+	for _, saved := range saved {
+		sc, err := compileSchedule(&saved.ScheduleSpec)
+		if err != nil {
+			log.Printf("WARN: dropping invalid persisted schedule %s: %v", saved.ID, err)
+			continue
+		}
+		sc.Schedule = *saved
+		for i := range sc.Runs {
+			if sc.Runs[i].Status == types.RunRunning {
+				sc.Runs[i].Status = types.RunCancelled
+				sc.Runs[i].Error = "coordinator restarted"
+			}
+		}
+		sc.setNextLocked(now)
+		s.schedules = append(s.schedules, sc)
+	}
+	if len(s.schedules) > 0 {
+		log.Printf("loaded %d persisted schedules", len(s.schedules))
+	}
+	return s
+}
+
+// start launches the scheduling loop. Safe to call multiple times.
+func (s *scheduler) start() {
+	s.startOnce.Do(func() {
+		s.wg.Add(1)
+		go s.loop()
+	})
+}
+
+// stop ends the loop, cancels running runs and waits for them.
+func (s *scheduler) stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// kick makes the loop recompute its next wake-up.
+func (s *scheduler) kick() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) loop() {
+	defer s.wg.Done()
+	for {
+		timer := time.NewTimer(s.fireDue(time.Now()))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// fireDue fires every schedule that is due and returns how long until
+// the next one is.
+func (s *scheduler) fireDue(now time.Time) time.Duration {
+	var events []types.Event
+	s.mu.Lock()
+	wait := scheduleIdleWait
+	fired := false
+	for _, sc := range s.schedules {
+		if sc.NextRunAt == nil {
+			continue
+		}
+		if !sc.NextRunAt.After(now) {
+			if ev, ok := s.fireLocked(sc, now, false); ok {
+				events = append(events, ev)
+			}
+			sc.setNextLocked(now)
+			fired = true
+		}
+		if sc.NextRunAt != nil {
+			wait = min(wait, sc.NextRunAt.Sub(now))
+		}
+	}
+	if fired {
+		s.persistLocked()
+	}
+	s.mu.Unlock()
+
+	for _, ev := range events {
+		s.events.Publish(ev)
+	}
+	return wait
+}
+
+// fireLocked starts a run of sc, applying its overlap policy. A run that
+// does not start is recorded at once and its event returned.
+func (s *scheduler) fireLocked(sc *schedule, now time.Time, manual bool) (types.Event, bool) {
+	sc.RunCount++
+	run := types.ScheduleRun{Status: types.RunRunning, Manual: manual, StartedAt: now}
+
+	if len(sc.running) > 0 {
+		switch sc.Overlap {
+		case types.OverlapAllow:
+		case types.OverlapReplace:
+			for _, cancel := range sc.running {
+				cancel()
+			}
+		default:
+			run.Status = types.RunSkipped
+			run.Error = "previous run still in progress"
+			return s.recordLocked(sc, run), true
+		}
+	}
+
+	var content strings.Builder
+	local := now.In(sc.loc)
+	vars := scheduleVars{Name: sc.Name, Run: sc.RunCount, Now: local, Date: local.Format("2006-01-02"), Time: local.Format("15:04")}
+	if err := sc.tmpl.Execute(&content, vars); err != nil {
+		run.Status = types.RunFailed
+		run.Error = fmt.Sprintf("rendering content: %v", err)
+		return s.recordLocked(sc, run), true
+	}
+	msgID, err := generateID()
+	if err != nil {
+		run.Status = types.RunFailed
+		run.Error = "failed to generate message ID"
+		return s.recordLocked(sc, run), true
+	}
+	source := sc.Source
+	if source == "" {
+		source = "schedule:" + sc.Name
+	}
+	msg := &types.Message{
+		ID:         msgID,
+		Content:    content.String(),
+		Source:     source,
+		SessionID:  sc.SessionID,
+		TargetNode: sc.TargetNode,
+		TargetRule: sc.TargetRule,
+		CreatedAt:  now,
+	}
+	run.MessageID = msgID
+	s.recordLocked(sc, run)
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	sc.running[msgID] = cancel
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		resp, err := s.run(ctx, msg)
+		s.finish(sc, msgID, resp, err, ctx.Err() != nil)
+	}()
+	log.Printf("schedule %s fired: message %s", sc.Name, msgID)
+	return types.Event{}, false
+}
+
+// finish records the outcome of a run that was started.
+func (s *scheduler) finish(sc *schedule, msgID string, resp *types.MessageResponse, err error, cancelled bool) {
+	s.mu.Lock()
+	delete(sc.running, msgID)
+	run := types.ScheduleRun{MessageID: msgID, Status: types.RunSucceeded}
+	switch {
+	case cancelled || errors.Is(err, errMessageCancelled):
+		run.Status = types.RunCancelled
+		run.Error = "cancelled"
+	case err != nil:
+		run.Status = types.RunFailed
+		run.Error = err.Error()
+	case resp.GatewayError:
+		run.Status = types.RunFailed
+		run.Error = fmt.Sprintf("node %s replied with a gateway error", resp.NodeID)
+		run.NodeID = resp.NodeID
+	default:
+		run.NodeID = resp.NodeID
+		run.Response = resp.Response
+	}
+	ev := s.recordLocked(sc, run)
+	if slices.Contains(s.schedules, sc) {
+		s.persistLocked()
+	}
+	s.mu.Unlock()
+	s.events.Publish(ev)
+}
+
+// recordLocked adds or completes a run in sc's history, keeping the
+// newest keepRuns, and returns the event announcing its outcome. A
+// completed run whose entry has already been trimmed from the history is
+// not added back.
+func (s *scheduler) recordLocked(sc *schedule, run types.ScheduleRun) types.Event {
+	now := time.Now()
+	if run.Status != types.RunRunning {
+		run.FinishedAt = &now
+	}
+	i := -1
+	if run.MessageID != "" {
+		i = slices.IndexFunc(sc.Runs, func(r types.ScheduleRun) bool { return r.MessageID == run.MessageID })
+	}
+	switch {
+	case i >= 0:
+		run.Manual = sc.Runs[i].Manual
+		run.StartedAt = sc.Runs[i].StartedAt
+		sc.Runs[i] = run
+	case run.MessageID != "" && run.Status != types.RunRunning:
+		// Trimmed while it ran; the completion is dropped.
+	default:
+		sc.Runs = slices.Insert(sc.Runs, 0, run)
+		if len(sc.Runs) > sc.keepRuns() {
+			sc.Runs = sc.Runs[:sc.keepRuns()]
+		}
+	}
+	sc.UpdatedAt = now
+
+	ev := types.Event{
+		Type:      types.EventScheduleSucceeded,
+		NodeID:    run.NodeID,
+		MessageID: run.MessageID,
+		Data:      map[string]any{"schedule_id": sc.ID, "name": sc.Name},
+	}
+	switch run.Status {
+	case types.RunSkipped:
+		ev.Type = types.EventScheduleSkipped
+	case types.RunFailed, types.RunCancelled:
+		ev.Type = types.EventScheduleFailed
+		ev.Data["error"] = run.Error
+	}
+	if run.Status != types.RunRunning && run.Status != types.RunSucceeded {
+		log.Printf("schedule %s run %s: %s", sc.Name, run.Status, run.Error)
+	}
+	return ev
+}
+
+func (s *scheduler) persistLocked() {
+	if s.store == nil {
+		return
+	}
+	out := make([]*types.Schedule, len(s.schedules))
+	for i, sc := range s.schedules {
+		out[i] = sc.snapshot()
+	}
+	if err := s.store.SaveSchedules(out); err != nil {
+		log.Printf("WARN: failed to persist schedules: %v", err)
+	}
+}
+
+// findLocked returns the schedule with the given ID or name.
+func (s *scheduler) findLocked(ref string) *schedule {
+	for _, sc := range s.schedules {
+		if sc.ID == ref || sc.Name == ref {
+			return sc
+		}
+	}
+	return nil
+}
+
+// add creates a schedule from spec.
+func (s *scheduler) add(spec *types.ScheduleSpec) (*types.Schedule, error) {
+	sc, err := compileSchedule(spec)
+	if err != nil {
+		return nil, err
+	}
+	id, err := generatePrefixedID("sched")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	sc.ID = id
+	sc.CreatedAt = now
+	sc.UpdatedAt = now
+
+	s.mu.Lock()
+	if s.findLocked(spec.Name) != nil {
+		s.mu.Unlock()
+		return nil, errScheduleExists
+	}
+	sc.setNextLocked(now)
+	s.schedules = append(s.schedules, sc)
+	s.persistLocked()
+	out := sc.snapshot()
+	s.mu.Unlock()
+
+	s.kick()
+	return out, nil
+}
+
+// update replaces a schedule's spec, keeping its ID and run history.
+func (s *scheduler) update(ref string, spec *types.ScheduleSpec) (*types.Schedule, error) {
+	sc, err := compileSchedule(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	old := s.findLocked(ref)
+	if old == nil {
+		s.mu.Unlock()
+		return nil, errScheduleNotFound
+	}
+	if other := s.findLocked(spec.Name); other != nil && other != old {
+		s.mu.Unlock()
+		return nil, errScheduleExists
+	}
+	// Runs in progress belong to the old spec but are recorded on the
+	// same schedule, so the compiled parts are swapped in place.
+	now := time.Now()
+	old.ScheduleSpec = sc.ScheduleSpec
+	old.cron, old.loc, old.tmpl = sc.cron, sc.loc, sc.tmpl
+	old.UpdatedAt = now
+	if len(old.Runs) > old.keepRuns() {
+		old.Runs = old.Runs[:old.keepRuns()]
+	}
+	old.setNextLocked(now)
+	s.persistLocked()
+	out := old.snapshot()
+	s.mu.Unlock()
+
+	s.kick()
+	return out, nil
+}
+
+// remove deletes a schedule and cancels its running runs.
+func (s *scheduler) remove(ref string) (*types.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc := s.findLocked(ref)
+	if sc == nil {
+		return nil, errScheduleNotFound
+	}
+	for _, cancel := range sc.running {
+		cancel()
+	}
+	s.schedules = slices.DeleteFunc(s.schedules, func(x *schedule) bool { return x == sc })
+	s.persistLocked()
+	return sc.snapshot(), nil
+}
+
+// trigger fires a schedule now, outside its timing, applying its overlap
+// policy. Disabled schedules can be triggered too.
+func (s *scheduler) trigger(ref string) (*types.ScheduleRun, error) {
+	s.mu.Lock()
+	sc := s.findLocked(ref)
+	if sc == nil {
+		s.mu.Unlock()
+		return nil, errScheduleNotFound
+	}
+	ev, finished := s.fireLocked(sc, time.Now(), true)
+	run := sc.Runs[0]
+	s.persistLocked()
+	s.mu.Unlock()
+
+	if finished {
+		s.events.Publish(ev)
+	}
+	return &run, nil
+}
+
+// get returns a copy of a schedule by ID or name, or nil if not found.
+func (s *scheduler) get(ref string) *types.Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc := s.findLocked(ref)
+	if sc == nil {
+		return nil
+	}
+	return sc.snapshot()
+}
+
+// list returns copies of all schedules.
+func (s *scheduler) list() []*types.Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*types.Schedule, len(s.schedules))
+	for i, sc := range s.schedules {
+		out[i] = sc.snapshot()
+	}
+	return out
+}
+
+// validateScheduleTarget checks that a schedule's target node or rule
+// exists when the schedule is saved.
+func (s *Server) validateScheduleTarget(spec *types.ScheduleSpec) error {
+	if spec.TargetNode != "" && s.registry.Get(spec.TargetNode) == nil {
+		return fmt.Errorf("target node %q not found", spec.TargetNode)
+	}
+	if spec.TargetRule != "" {
+		rules := s.router.ListRules()
+		if !slices.ContainsFunc(rules, func(r *types.RoutingRule) bool { return r.ID == spec.TargetRule }) {
+			return fmt.Errorf("target rule %q not found", spec.TargetRule)
+		}
+	}
+	return nil
+}
+
+// writeScheduleError maps scheduler errors to HTTP statuses.
+func writeScheduleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errScheduleNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, errScheduleExists):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+}
+
+// handleListSchedules handles GET /api/v1/schedules.
+func (s *Server) handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.schedules.list())
+}
+
+// handleAddSchedule handles POST /api/v1/schedules.
+func (s *Server) handleAddSchedule(w http.ResponseWriter, r *http.Request) {
+	var spec types.ScheduleSpec
+	if err := decodeJSON(w, r, &spec); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if err := s.validateScheduleTarget(&spec); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	sc, err := s.schedules.add(&spec)
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	log.Printf("schedule added: %s (%s)", sc.Name, sc.ID)
+	writeJSON(w, http.StatusCreated, sc)
+}
+
+// handleGetSchedule handles GET /api/v1/schedules/{id}. The ID may also
+// be the schedule's name, as for the other schedule endpoints.
+func (s *Server) handleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	sc := s.schedules.get(r.PathValue("id"))
+	if sc == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "schedule not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, sc)
+}
+
+// handleUpdateSchedule handles PUT /api/v1/schedules/{id} — replace the
+// schedule's spec. Its run history is kept.
+func (s *Server) handleUpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	var spec types.ScheduleSpec
+	if err := decodeJSON(w, r, &spec); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if err := s.validateScheduleTarget(&spec); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	sc, err := s.schedules.update(r.PathValue("id"), &spec)
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	log.Printf("schedule updated: %s (%s)", sc.Name, sc.ID)
+	writeJSON(w, http.StatusOK, sc)
+}
+
+// handleDeleteSchedule handles DELETE /api/v1/schedules/{id}.
+func (s *Server) handleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	sc, err := s.schedules.remove(r.PathValue("id"))
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	log.Printf("schedule deleted: %s (%s)", sc.Name, sc.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRunSchedule handles POST /api/v1/schedules/{id}/run — fire the
+// schedule now. The reply is the run as started (or skipped).
+func (s *Server) handleRunSchedule(w http.ResponseWriter, r *http.Request) {
+	run, err := s.schedules.trigger(r.PathValue("id"))
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, run)
+}
@@ -0,0 +1,150 @@
+package coordinator
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC) // a Saturday
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 3, 14, 9, 45, 0, 0, time.UTC)},
+		{"0 7 * * mon-fri", time.Date(2026, 3, 16, 7, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"30 9 1,15 * *", time.Date(2026, 3, 15, 9, 30, 0, 0, time.UTC)},
+		{"0 0 1 * 7", time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)}, // day of month OR Sunday
+		{"0 12 29 feb *", time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		expr, err := parseCron(c.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", c.spec, err)
+		}
+		if got := expr.next(base); !got.Equal(c.want) {
+			t.Errorf("%s: next = %s, want %s", c.spec, got, c.want)
+		}
+	}
+
+	for _, bad := range []string{"* * * *", "60 * * * *", "* * * * mon-", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := parseCron(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+	if expr, _ := parseCron("0 0 30 2 *"); !expr.next(base).IsZero() {
+		t.Error("expected Feb 30 never to fire")
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	store, err := NewScheduleStore(filepath.Join(t.TempDir(), "schedules.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := make(chan *types.Message, 4)
+	release := make(chan struct{})
+	run := func(ctx context.Context, msg *types.Message) (*types.MessageResponse, error) {
+		sent <- msg
+		select {
+		case <-release:
+			return &types.MessageResponse{NodeID: "n1", Response: "done"}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	s := newScheduler(run, nil, store)
+	defer s.stop()
+
+	if _, err := s.add(&types.ScheduleSpec{Name: "bad", Cron: "0 9 * * *", Content: "{{.Missing"}); err == nil {
+		t.Fatal("expected an invalid template to be rejected")
+	}
+	for _, secs := range []int{5, maxScheduleInterval + 1, math.MaxInt64 / 1000} {
+		if _, err := s.add(&types.ScheduleSpec{Name: "bad", IntervalSeconds: secs, Content: "hi"}); err == nil {
+			t.Fatalf("expected interval_seconds %d to be rejected", secs)
+		}
+	}
+	sc, err := s.add(&types.ScheduleSpec{
+		Name:            "ci-summary",
+		IntervalSeconds: 3600,
+		Content:         "Summarize CI failures up to {{.Date}} (run {{.Run}})",
+		TargetNode:      "n1",
+		KeepRuns:        2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.NextRunAt == nil || time.Until(*sc.NextRunAt) < 59*time.Minute {
+		t.Fatalf("expected the first run in an hour, got %v", sc.NextRunAt)
+	}
+
+	// Due: the message is rendered and sent.
+	due := *sc.NextRunAt
+	s.fireDue(due)
+	msg := <-sent
+	if want := "Summarize CI failures up to " + due.Format("2006-01-02") + " (run 1)"; msg.Content != want || msg.TargetNode != "n1" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	// Still running: the default overlap policy skips the next run.
+	s.fireDue(due.Add(time.Hour))
+	if got := s.get("ci-summary"); len(got.Runs) != 2 || got.Runs[0].Status != types.RunSkipped || got.Runs[1].Status != types.RunRunning {
+		t.Fatalf("unexpected runs %+v", got.Runs)
+	}
+
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for s.get(sc.ID).Runs[1].Status == types.RunRunning && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if r := s.get(sc.ID).Runs[1]; r.Status != types.RunSucceeded || r.Response != "done" {
+		t.Fatalf("unexpected finished run %+v", r)
+	}
+
+	// A manual run counts against keep_runs like the others.
+	if _, err := s.trigger("ci-summary"); err != nil {
+		t.Fatal(err)
+	}
+	<-sent
+	if got := s.get(sc.ID); len(got.Runs) != 2 || !got.Runs[0].Manual || got.RunCount != 3 {
+		t.Fatalf("unexpected runs after a manual run %+v", got)
+	}
+
+	// Schedules and their runs survive a restart.
+	s.stop()
+	reloaded := newScheduler(run, nil, store)
+	got := reloaded.get("ci-summary")
+	if got == nil || got.RunCount != 3 || len(got.Runs) != 2 || got.NextRunAt == nil {
+		t.Fatalf("unexpected reloaded schedule %+v", got)
+	}
+}
+
+func TestSchedulerFinish(t *testing.T) {
+	s := newScheduler(nil, nil, nil)
+	defer s.stop()
+	if _, err := s.add(&types.ScheduleSpec{Name: "nightly", IntervalSeconds: 3600, Content: "hi", KeepRuns: 1}); err != nil {
+		t.Fatal(err)
+	}
+	sc := s.schedules[0]
+	s.mu.Lock()
+	s.recordLocked(sc, types.ScheduleRun{MessageID: "m1", Status: types.RunRunning, StartedAt: time.Now()})
+	s.mu.Unlock()
+
+	// A message cancelled through the API counts as a cancelled run.
+	s.finish(sc, "m1", nil, errMessageCancelled, false)
+	if got := s.get("nightly"); len(got.Runs) != 1 || got.Runs[0].Status != types.RunCancelled {
+		t.Fatalf("unexpected runs %+v", got.Runs)
+	}
+
+	// A run trimmed from the history while it ran is not added back.
+	s.finish(sc, "m0", &types.MessageResponse{NodeID: "n1", Response: "late"}, nil, false)
+	if got := s.get("nightly"); len(got.Runs) != 1 || got.Runs[0].MessageID != "m1" {
+		t.Fatalf("expected the late completion to be dropped, got %+v", got.Runs)
+	}
+}
@@ -15,7 +15,10 @@ import (
 	"github.com/SallyKAN/claw-mesh/internal/types"
 )
 
-const maxRequestBody = 1 << 20 // 1 MB
+const (
+	maxRequestBody     = 1 << 20 // 1 MB
+	serverWriteTimeout = 30 * time.Second
+)
 
 // Server is the coordinator HTTP server.
 type Server struct {
@@ -24,15 +27,22 @@ type Server struct {
 	router    *Router
 	health    *HealthChecker
 	forwarder *Forwarder
+	groups    *GroupSet
+	events    *EventBus
+	webhooks  *WebhookManager
+	queue     *forwardQueue
+	jobs      *jobTable
+	inflight  *inflightTable
+	tasks     *taskQueue
+	schedules *scheduler
+	plans     *planExecutor
+	planner   Planner    // nil unless planning is configured
+	rulesFile *rulesFile // nil unless rules are declared in a file
 	http      *http.Server
 }
 
 // NewServer creates a coordinator server.
 func NewServer(cfg *config.CoordinatorConfig) *Server {
-	reg := NewRegistry()
-
-	// Set up persistent store for routing rules.
-	var store *Store
 	dataDir := cfg.DataDir
 	if dataDir == "" {
 		if home, err := os.UserHomeDir(); err == nil {
@@ -41,24 +51,124 @@ func NewServer(cfg *config.CoordinatorConfig) *Server {
 			dataDir = "."
 		}
 	}
-	storePath := filepath.Join(dataDir, "rules.json")
-	if s, err := NewStore(storePath); err == nil {
-		store = s
-		log.Printf("rule store: %s", storePath)
+
+	// Set up persistent store for the node registry.
+	var nodeStore *NodeStore
+	nodeStorePath := filepath.Join(dataDir, "nodes.json")
+	if ns, err := NewNodeStore(nodeStorePath); err == nil {
+		nodeStore = ns
+		log.Printf("node store: %s", nodeStorePath)
+	} else {
+		log.Printf("WARN: could not init node store at %s: %v", nodeStorePath, err)
+	}
+	reg := NewRegistry(nodeStore)
+
+	// Set up persistent store for routing rules, unless they are
+	// declared in a file.
+	var store *Store
+	if cfg.RulesFile == "" {
+		storePath := filepath.Join(dataDir, "rules.json")
+		if s, err := NewStore(storePath); err == nil {
+			store = s
+			log.Printf("rule store: %s", storePath)
+		} else {
+			log.Printf("WARN: could not init rule store at %s: %v", storePath, err)
+		}
+	}
+
+	// Set up persistent store for node groups.
+	var groupStore *GroupStore
+	groupStorePath := filepath.Join(dataDir, "groups.json")
+	if gs, err := NewGroupStore(groupStorePath); err == nil {
+		groupStore = gs
+		log.Printf("group store: %s", groupStorePath)
 	} else {
-		log.Printf("WARN: could not init rule store at %s: %v", storePath, err)
+		log.Printf("WARN: could not init group store at %s: %v", groupStorePath, err)
 	}
+	groups := NewGroupSet(groupStore)
 
 	rt := NewRouter(reg, store)
+	rt.groups = groups
 	hc := NewHealthChecker(reg, 30*time.Second, 10*time.Second)
 	fwd := NewForwarder()
+	rt.RegisterStrategy(&latencyStrategy{source: fwd})
+	rt.circuits = fwd
+
+	events := NewEventBus(defaultEventHistory)
+	reg.events = events
+	rt.events = events
+	groups.events = events
+	hc.events = events
+	fwd.breakers.events = events
+
+	// Set up persistent store for webhook subscriptions.
+	var hookStore *WebhookStore
+	hookStorePath := filepath.Join(dataDir, "webhooks.json")
+	if ws, err := NewWebhookStore(hookStorePath); err == nil {
+		hookStore = ws
+		log.Printf("webhook store: %s", hookStorePath)
+	} else {
+		log.Printf("WARN: could not init webhook store at %s: %v", hookStorePath, err)
+	}
+
+	// Set up persistent store for the task queue.
+	var taskStore *TaskStore
+	taskStorePath := filepath.Join(dataDir, "tasks.json")
+	if ts, err := NewTaskStore(taskStorePath); err == nil {
+		taskStore = ts
+		log.Printf("task store: %s", taskStorePath)
+	} else {
+		log.Printf("WARN: could not init task store at %s: %v", taskStorePath, err)
+	}
+
+	// Set up persistent store for schedules.
+	var scheduleStore *ScheduleStore
+	scheduleStorePath := filepath.Join(dataDir, "schedules.json")
+	if ss, err := NewScheduleStore(scheduleStorePath); err == nil {
+		scheduleStore = ss
+		log.Printf("schedule store: %s", scheduleStorePath)
+	} else {
+		log.Printf("WARN: could not init schedule store at %s: %v", scheduleStorePath, err)
+	}
+
+	// Set up persistent store for task plans.
+	var planStore *PlanStore
+	planStorePath := filepath.Join(dataDir, "plans.json")
+	if ps, err := NewPlanStore(planStorePath); err == nil {
+		planStore = ps
+		log.Printf("plan store: %s", planStorePath)
+	} else {
+		log.Printf("WARN: could not init plan store at %s: %v", planStorePath, err)
+	}
 
 	s := &Server{
 		cfg:       cfg,
 		registry:  reg,
 		router:    rt,
+		groups:    groups,
 		health:    hc,
 		forwarder: fwd,
+		events:    events,
+		webhooks:  NewWebhookManager(events, hookStore, cfg.AllowPrivate),
+		queue:     newForwardQueue(reg, cfg.QueueSize, time.Duration(cfg.QueueTimeout)*time.Second),
+		jobs:      newJobTable(events),
+		inflight:  newInflightTable(),
+	}
+	s.tasks = newTaskQueue(s.runTask, events, taskStore)
+	s.schedules = newScheduler(s.deliver, events, scheduleStore)
+	s.plans = newPlanExecutor(s.runPlanStep, events, planStore)
+	if p, err := newPlanner(cfg.Planner, s.askPlannerNode); err != nil {
+		log.Printf("WARN: planning disabled: %v", err)
+	} else if p != nil {
+		s.planner = p
+		log.Printf("planner: %s", cfg.Planner.Type)
+	}
+	if cfg.RulesFile != "" {
+		s.rulesFile = newRulesFile(cfg.RulesFile, rt, groups, events)
+		log.Printf("rules file: %s", s.rulesFile.path)
+		// A rejected file is logged; the coordinator starts without rules
+		// and picks up the file once it is fixed.
+		s.rulesFile.reload()
 	}
 
 	mux := http.NewServeMux()
@@ -67,13 +177,72 @@ func NewServer(cfg *config.CoordinatorConfig) *Server {
 	mux.HandleFunc("GET /api/v1/nodes", s.handleListNodes)
 	mux.HandleFunc("GET /api/v1/nodes/{id}", s.handleGetNode)
 	mux.HandleFunc("POST /api/v1/nodes/{id}/heartbeat", s.requireAuth(s.handleHeartbeat))
+	mux.HandleFunc("POST /api/v1/nodes/{id}/cordon", s.requireAuth(s.handleCordon))
+	mux.HandleFunc("POST /api/v1/nodes/{id}/drain", s.requireAuth(s.handleDrain))
+	mux.HandleFunc("POST /api/v1/nodes/{id}/uncordon", s.requireAuth(s.handleUncordon))
+	mux.HandleFunc("PATCH /api/v1/nodes/{id}/labels", s.requireAuth(s.handlePatchLabels))
+	mux.HandleFunc("PUT /api/v1/nodes/{id}/concurrency", s.requireAuth(s.handleSetConcurrency))
+
+	// Events (without a token, only node and rule events)
+	mux.HandleFunc("GET /api/v1/events", s.handleEvents)
+
+	// Webhooks (secrets are sensitive, so reads require auth too)
+	mux.HandleFunc("GET /api/v1/webhooks", s.requireAuth(s.handleListWebhooks))
+	mux.HandleFunc("POST /api/v1/webhooks", s.requireAuth(s.handleAddWebhook))
+	mux.HandleFunc("GET /api/v1/webhooks/{id}", s.requireAuth(s.handleGetWebhook))
+	mux.HandleFunc("DELETE /api/v1/webhooks/{id}", s.requireAuth(s.handleDeleteWebhook))
+	mux.HandleFunc("GET /api/v1/webhooks/{id}/deliveries", s.requireAuth(s.handleWebhookDeliveries))
+	mux.HandleFunc("POST /api/v1/webhooks/{id}/test", s.requireAuth(s.handleTestWebhook))
 
 	// Routing
 	mux.HandleFunc("POST /api/v1/route", s.requireAuth(s.handleRouteAuto))
 	mux.HandleFunc("POST /api/v1/route/{nodeId}", s.requireAuth(s.handleRouteToNode))
+	// Not under /route/, where it would shadow a node with ID "explain".
+	mux.HandleFunc("POST /api/v1/explain/route", s.requireAuth(s.handleRouteExplain))
+	mux.HandleFunc("POST /api/v1/broadcast", s.requireAuth(s.handleBroadcast))
+	mux.HandleFunc("DELETE /api/v1/messages/{id}", s.requireAuth(s.handleCancelMessage))
 	mux.HandleFunc("GET /api/v1/rules", s.handleListRules)
 	mux.HandleFunc("POST /api/v1/rules", s.requireAuth(s.handleAddRule))
+	mux.HandleFunc("PUT /api/v1/rules", s.requireAuth(s.handleReplaceRules))
+	mux.HandleFunc("GET /api/v1/rules/file", s.handleRulesFileStatus)
+	mux.HandleFunc("POST /api/v1/rules/reorder", s.requireAuth(s.handleReorderRules))
+	mux.HandleFunc("GET /api/v1/rules/{id}", s.handleGetRule)
+	mux.HandleFunc("PUT /api/v1/rules/{id}", s.requireAuth(s.handleUpdateRule))
 	mux.HandleFunc("DELETE /api/v1/rules/{id}", s.requireAuth(s.handleDeleteRule))
+	mux.HandleFunc("GET /api/v1/strategies", s.handleListStrategies)
+	mux.HandleFunc("GET /api/v1/affinity", s.requireAuth(s.handleListAffinity))
+
+	// Asynchronous messages (results are as sensitive as the messages)
+	mux.HandleFunc("GET /api/v1/jobs", s.requireAuth(s.handleListJobs))
+	mux.HandleFunc("GET /api/v1/jobs/{id}", s.requireAuth(s.handleGetJob))
+
+	// Task queue
+	mux.HandleFunc("GET /api/v1/tasks", s.requireAuth(s.handleListTasks))
+	mux.HandleFunc("POST /api/v1/tasks", s.requireAuth(s.handleAddTask))
+	mux.HandleFunc("DELETE /api/v1/tasks", s.requireAuth(s.handlePurgeTasks))
+	mux.HandleFunc("GET /api/v1/tasks/{id}", s.requireAuth(s.handleGetTask))
+	mux.HandleFunc("DELETE /api/v1/tasks/{id}", s.requireAuth(s.handleDeleteTask))
+	mux.HandleFunc("POST /api/v1/tasks/{id}/requeue", s.requireAuth(s.handleRequeueTask))
+
+	// Schedules
+	mux.HandleFunc("GET /api/v1/schedules", s.requireAuth(s.handleListSchedules))
+	mux.HandleFunc("POST /api/v1/schedules", s.requireAuth(s.handleAddSchedule))
+	mux.HandleFunc("GET /api/v1/schedules/{id}", s.requireAuth(s.handleGetSchedule))
+	mux.HandleFunc("PUT /api/v1/schedules/{id}", s.requireAuth(s.handleUpdateSchedule))
+	mux.HandleFunc("DELETE /api/v1/schedules/{id}", s.requireAuth(s.handleDeleteSchedule))
+	mux.HandleFunc("POST /api/v1/schedules/{id}/run", s.requireAuth(s.handleRunSchedule))
+
+	// Task plans
+	mux.HandleFunc("GET /api/v1/plans", s.requireAuth(s.handleListPlans))
+	mux.HandleFunc("POST /api/v1/plans", s.requireAuth(s.handleSubmitPlan))
+	mux.HandleFunc("GET /api/v1/plans/{id}", s.requireAuth(s.handleGetPlan))
+
+	// Node groups
+	mux.HandleFunc("GET /api/v1/groups", s.handleListGroups)
+	mux.HandleFunc("POST /api/v1/groups", s.requireAuth(s.handleAddGroup))
+	mux.HandleFunc("GET /api/v1/groups/{name}", s.handleGetGroup)
+	mux.HandleFunc("PUT /api/v1/groups/{name}", s.requireAuth(s.handleUpdateGroup))
+	mux.HandleFunc("DELETE /api/v1/groups/{name}", s.requireAuth(s.handleDeleteGroup))
 
 	// Seed (config sync for new nodes)
 	mux.HandleFunc("GET /api/v1/seed/config", s.requireAuth(s.handleSeedConfig))
@@ -87,20 +256,31 @@ func NewServer(cfg *config.CoordinatorConfig) *Server {
 		Handler:           recoverMiddleware(requestLogger(mux)),
 		ReadHeaderTimeout: 10 * time.Second,
 		ReadTimeout:       30 * time.Second,
-		WriteTimeout:      30 * time.Second,
+		WriteTimeout:      serverWriteTimeout,
 		IdleTimeout:       120 * time.Second,
 	}
 
 	return s
 }
 
-// Start begins serving and the health checker. Blocks until the server stops.
+// Start begins serving, the health checker, webhook delivery, the task
+// queue workers, the scheduler, unfinished task plans and the rules file
+// watcher. Blocks until the server stops.
 func (s *Server) Start() error {
 	ln, err := net.Listen("tcp", s.http.Addr)
 	if err != nil {
 		return err
 	}
 	s.health.Start()
+	s.webhooks.Start()
+	s.tasks.start()
+	s.schedules.start()
+	s.plans.start()
+	if s.rulesFile != nil {
+		if err := s.rulesFile.watch(); err != nil {
+			log.Printf("WARN: not watching rules file %s: %v", s.rulesFile.path, err)
+		}
+	}
 	log.Printf("coordinator listening on %s", s.http.Addr)
 	return s.http.Serve(ln)
 }
@@ -108,6 +288,16 @@ func (s *Server) Start() error {
 // Shutdown gracefully stops the server.
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.health.Stop()
+	s.webhooks.Stop()
+	if s.rulesFile != nil {
+		s.rulesFile.stop()
+	}
+	s.jobs.cancelAll()
+	s.tasks.stop()
+	s.schedules.stop()
+	s.plans.stop()
+	// Event streams never go idle on their own; end them first.
+	s.events.Close()
 	return s.http.Shutdown(ctx)
 }
 
@@ -115,25 +305,39 @@ func (s *Server) Shutdown(ctx context.Context) error {
 // Accepts the coordinator admin token or any valid per-node token.
 func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if s.cfg.Token == "" {
-			next(w, r)
-			return
-		}
-		const prefix = "Bearer "
-		auth := r.Header.Get("Authorization")
-		if len(auth) <= len(prefix) || auth[:len(prefix)] != prefix {
-			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid authorization header"})
-			return
-		}
-		token := auth[len(prefix):]
-		if token != s.cfg.Token && !s.registry.ValidateNodeToken(token) {
-			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid token"})
+		if msg := s.authError(r); msg != "" {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": msg})
 			return
 		}
 		next(w, r)
 	}
 }
 
+// authError returns why r is not authorized, or "" if it is.
+func (s *Server) authError(r *http.Request) string {
+	if s.cfg.Token == "" {
+		return ""
+	}
+	token, ok := bearerToken(r)
+	if !ok {
+		return "missing or invalid authorization header"
+	}
+	if token != s.cfg.Token && !s.registry.ValidateNodeToken(token) {
+		return "invalid token"
+	}
+	return ""
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= len(prefix) || auth[:len(prefix)] != prefix {
+		return "", false
+	}
+	return auth[len(prefix):], true
+}
+
 // decodeJSON reads a JSON body with size limit and strict field checking.
 // It rejects requests with trailing data after the JSON value.
 func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
@@ -167,38 +371,129 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validateLabels(req.Labels); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if req.MaxConcurrency < 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "max_concurrency must not be negative"})
+		return
+	}
+
+	if req.NodeID != "" {
+		s.registerKnownIdentity(w, &req)
+		return
+	}
+	s.registerNewIdentity(w, &req)
+}
+
+// registerNewIdentity registers a node under a fresh ID and identity secret.
+func (s *Server) registerNewIdentity(w http.ResponseWriter, req *types.RegisterRequest) {
 	id, err := generateUniqueID(s.registry.Exists)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to generate node ID"})
 		return
 	}
 
+	secret, err := generateToken()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to generate node secret"})
+		return
+	}
+
+	resp, err := s.addNode(id, secret, req)
+	if err != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	resp.NodeSecret = secret
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// registerKnownIdentity handles a registration that carries a stored node
+// identity. A known node with a matching secret is reattached in place and
+// gets a fresh token. An unknown ID (e.g. after deregistration) is not
+// re-created, since the client chose it; the node gets a new identity.
+func (s *Server) registerKnownIdentity(w http.ResponseWriter, req *types.RegisterRequest) {
+	if !validNodeID(req.NodeID) || req.NodeSecret == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid node identity"})
+		return
+	}
+
+	known, valid := s.registry.CheckNodeSecret(req.NodeID, req.NodeSecret)
+	if known && !valid {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "node identity rejected"})
+		return
+	}
+
+	if !known {
+		log.Printf("unknown node identity %s, issuing a new one", req.NodeID)
+		s.registerNewIdentity(w, req)
+		return
+	}
+
 	nodeToken, err := generateToken()
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to generate node token"})
 		return
 	}
+	if !s.registry.Reattach(req.NodeID, req.Name, req.Endpoint, req.Capabilities, req.Labels, req.MaxConcurrency) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "node not found"})
+		return
+	}
+	s.registry.SetNodeToken(req.NodeID, nodeToken)
+	// A raised limit may let waiting forwards through.
+	s.queue.dispatch()
+
+	log.Printf("node reattached: %s (%s) at %s", req.NodeID, req.Name, req.Endpoint)
+	s.events.Publish(types.Event{
+		Type:   types.EventNodeReattached,
+		NodeID: req.NodeID,
+		Data:   map[string]any{"name": req.Name, "endpoint": req.Endpoint},
+	})
+	writeJSON(w, http.StatusOK, types.RegisterResponse{
+		NodeID:     req.NodeID,
+		Token:      nodeToken,
+		Reattached: true,
+	})
+}
+
+// addNode creates a new registry entry with the given ID and identity secret
+// and issues a per-node token.
+func (s *Server) addNode(id, secret string, req *types.RegisterRequest) (*types.RegisterResponse, error) {
+	nodeToken, err := generateToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate node token")
+	}
 
 	node := &types.Node{
-		ID:            id,
-		Name:          req.Name,
-		Endpoint:      req.Endpoint,
-		Capabilities:  req.Capabilities,
-		Status:        types.NodeStatusOnline,
-		LastHeartbeat: time.Now(),
+		ID:             id,
+		Name:           req.Name,
+		Endpoint:       req.Endpoint,
+		Capabilities:   req.Capabilities,
+		Labels:         req.Labels,
+		MaxConcurrency: req.MaxConcurrency,
+		Status:         types.NodeStatusOnline,
+		LastHeartbeat:  time.Now(),
 	}
 
 	if err := s.registry.Add(node); err != nil {
-		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
-		return
+		return nil, err
 	}
 	s.registry.SetNodeToken(node.ID, nodeToken)
+	s.registry.SetNodeSecret(node.ID, secret)
 
 	log.Printf("node registered: %s (%s) at %s", node.ID, node.Name, node.Endpoint)
-	writeJSON(w, http.StatusCreated, types.RegisterResponse{
+	s.events.Publish(types.Event{
+		Type:   types.EventNodeRegistered,
 		NodeID: node.ID,
-		Token:  nodeToken,
+		Data:   map[string]any{"name": node.Name, "endpoint": node.Endpoint},
 	})
+	return &types.RegisterResponse{
+		NodeID: node.ID,
+		Token:  nodeToken,
+	}, nil
 }
 
 func (s *Server) handleDeregister(w http.ResponseWriter, r *http.Request) {
@@ -208,11 +503,19 @@ func (s *Server) handleDeregister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	log.Printf("node deregistered: %s", id)
+	if s.forwarder != nil {
+		s.forwarder.breakers.forget(id)
+	}
+	s.events.Publish(types.Event{Type: types.EventNodeDeregistered, NodeID: id})
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (s *Server) handleListNodes(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, s.registry.List())
+	nodes := s.registry.List()
+	for _, n := range nodes {
+		s.withCircuit(n)
+	}
+	writeJSON(w, http.StatusOK, nodes)
 }
 
 func (s *Server) handleGetNode(w http.ResponseWriter, r *http.Request) {
@@ -222,7 +525,15 @@ func (s *Server) handleGetNode(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusNotFound, map[string]string{"error": "node not found"})
 		return
 	}
-	writeJSON(w, http.StatusOK, node)
+	writeJSON(w, http.StatusOK, s.withCircuit(node))
+}
+
+// withCircuit fills in the state of the node's circuit breaker.
+func (s *Server) withCircuit(n *types.Node) *types.Node {
+	if s.forwarder != nil {
+		n.Circuit = s.forwarder.CircuitState(n.ID)
+	}
+	return n
 }
 
 func (s *Server) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
@@ -238,13 +549,104 @@ func (s *Server) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !s.registry.RecordHeartbeat(id, req.Status) {
+	// A node restored from disk is only known by its token hash; its first
+	// heartbeat gives the plaintext back so messages can be forwarded again.
+	if token, ok := bearerToken(r); ok {
+		s.registry.RestoreNodeToken(id, token)
+	}
+
+	if req.Load != nil && (req.Load.InFlight < 0 || req.Load.QueueDepth < 0 || req.Load.LoadAvg < 0 ||
+		req.Load.CPUs < 0 || req.Load.FreeMemoryMB < 0) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid load values"})
+		return
+	}
+
+	if !s.registry.RecordHeartbeat(id, req.Status, req.Load) {
 		writeJSON(w, http.StatusNotFound, map[string]string{"error": "node not found"})
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// handleCordon handles POST /api/v1/nodes/{id}/cordon — stop auto-routing
+// new messages to the node. Explicit routing to it still works.
+func (s *Server) handleCordon(w http.ResponseWriter, r *http.Request) {
+	s.setMaintenance(w, r, types.MaintenanceCordoned)
+}
+
+// handleDrain handles POST /api/v1/nodes/{id}/drain — cordon the node and
+// report it drained once its in-flight forwards have finished.
+func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
+	s.setMaintenance(w, r, types.MaintenanceDraining)
+}
+
+// handleUncordon handles POST /api/v1/nodes/{id}/uncordon — return the node
+// to rotation.
+func (s *Server) handleUncordon(w http.ResponseWriter, r *http.Request) {
+	s.setMaintenance(w, r, "")
+}
+
+func (s *Server) setMaintenance(w http.ResponseWriter, r *http.Request, state types.MaintenanceState) {
+	id := r.PathValue("id")
+	node := s.registry.SetMaintenance(id, state)
+	if node == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "node not found"})
+		return
+	}
+	log.Printf("node %s (%s) maintenance: %q (%d in flight)", node.ID, node.Name, node.Maintenance, node.InFlight)
+	writeJSON(w, http.StatusOK, node)
+}
+
+// handlePatchLabels handles PATCH /api/v1/nodes/{id}/labels. The body is a
+// JSON merge patch: {"zone": "home", "spot": null} sets zone and removes spot.
+func (s *Server) handlePatchLabels(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var patch map[string]*string
+	if err := decodeJSON(w, r, &patch); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	for k, v := range patch {
+		if err := validateLabelKey(k); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if v != nil {
+			if err := validateLabelValue(*v); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("label %q: %v", k, err)})
+				return
+			}
+		}
+	}
+
+	current := s.registry.Get(id)
+	if current == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "node not found"})
+		return
+	}
+	n := len(current.Labels)
+	for k, v := range patch {
+		_, had := current.Labels[k]
+		if v != nil && !had {
+			n++
+		} else if v == nil && had {
+			n--
+		}
+	}
+	if n > maxLabels {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("too many labels (max %d)", maxLabels)})
+		return
+	}
+
+	node := s.registry.UpdateLabels(id, patch)
+	if node == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "node not found"})
+		return
+	}
+	log.Printf("node %s (%s) labels: %s", node.ID, node.Name, formatLabels(node.Labels))
+	writeJSON(w, http.StatusOK, node)
+}
+
 // recoverMiddleware catches panics and returns 500 instead of crashing.
 func recoverMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -295,25 +697,38 @@ func validateEndpoint(endpoint string, allowPrivate bool) error {
 	if allowPrivate {
 		return nil
 	}
+	if err := checkPrivateHost(host); err != nil {
+		return fmt.Errorf("endpoint %w", err)
+	}
+	return nil
+}
+
+// checkPrivateHost rejects a host that is, or resolves to, a loopback or
+// private IP. Hosts that can't be resolved are allowed, since they might be
+// reachable from the coordinator.
+func checkPrivateHost(host string) error {
+	return checkHost(host, isPrivateIP)
+}
 
+// checkHost is checkPrivateHost with the addresses to reject given by
+// blocked.
+func checkHost(host string, blocked func(net.IP) bool) error {
 	ip := net.ParseIP(host)
 	if ip == nil {
-		// Could be a hostname — resolve it.
 		addrs, err := net.LookupHost(host)
 		if err != nil {
-			// Can't resolve — allow it (might be reachable from coordinator).
 			return nil
 		}
 		for _, addr := range addrs {
-			if parsed := net.ParseIP(addr); parsed != nil && isPrivateIP(parsed) {
-				return fmt.Errorf("endpoint resolves to private/loopback IP %s (set allow_private to permit)", addr)
+			if parsed := net.ParseIP(addr); parsed != nil && blocked(parsed) {
+				return fmt.Errorf("resolves to private/loopback IP %s (set allow_private to permit)", addr)
 			}
 		}
 		return nil
 	}
 
-	if isPrivateIP(ip) {
-		return fmt.Errorf("private/loopback endpoints not allowed (set allow_private to permit)")
+	if blocked(ip) {
+		return fmt.Errorf("is a private/loopback address (set allow_private to permit)")
 	}
 	return nil
 }
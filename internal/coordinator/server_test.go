@@ -0,0 +1,194 @@
+package coordinator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/config"
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+func newTestServer() *Server {
+	reg := NewRegistry(nil)
+	return &Server{
+		cfg:      &config.CoordinatorConfig{AllowPrivate: true},
+		registry: reg,
+		queue:    newForwardQueue(reg, 0, 0),
+		inflight: newInflightTable(),
+	}
+}
+
+func postRegister(t *testing.T, srv *Server, req types.RegisterRequest) (*httptest.ResponseRecorder, types.RegisterResponse) {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/nodes/register", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	srv.handleRegister(rr, r)
+	var resp types.RegisterResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	return rr, resp
+}
+
+func TestHandleRegister_ReclaimsIdentity(t *testing.T) {
+	srv := newTestServer()
+
+	rr, first := postRegister(t, srv, types.RegisterRequest{Name: "mac", Endpoint: "127.0.0.1:9121"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if first.NodeSecret == "" {
+		t.Fatal("expected a node secret for a new identity")
+	}
+
+	rr, second := postRegister(t, srv, types.RegisterRequest{
+		Name:         "mac",
+		Endpoint:     "127.0.0.1:9122",
+		Capabilities: types.Capabilities{OS: "darwin"},
+		NodeID:       first.NodeID,
+		NodeSecret:   first.NodeSecret,
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 on reattach, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if second.NodeID != first.NodeID || !second.Reattached {
+		t.Fatalf("expected reattach to %s, got %+v", first.NodeID, second)
+	}
+	if second.Token == first.Token {
+		t.Error("expected token to be rotated on reattach")
+	}
+	if srv.registry.ValidateNodeToken(first.Token) {
+		t.Error("old token should be revoked after rotation")
+	}
+
+	nodes := srv.registry.List()
+	if len(nodes) != 1 {
+		t.Fatalf("expected a single node entry, got %d", len(nodes))
+	}
+	if nodes[0].Endpoint != "127.0.0.1:9122" || nodes[0].Capabilities.OS != "darwin" {
+		t.Errorf("expected endpoint and capabilities to be updated, got %+v", nodes[0])
+	}
+}
+
+func TestHandleRegister_ReattachRaisesConcurrency(t *testing.T) {
+	srv := newTestServer()
+
+	_, first := postRegister(t, srv, types.RegisterRequest{Name: "mac", Endpoint: "127.0.0.1:9121", MaxConcurrency: 1})
+	n := srv.registry.Get(first.NodeID)
+	ctx := context.Background()
+	if _, err := srv.queue.acquire(ctx, []*types.Node{n}); err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+
+	// A forward waits behind the limit of one...
+	got := make(chan error, 1)
+	go func() {
+		_, err := srv.queue.acquire(ctx, []*types.Node{n})
+		got <- err
+	}()
+	for srv.queue.queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// ...and goes through as soon as the node reattaches with a higher one.
+	rr, _ := postRegister(t, srv, types.RegisterRequest{
+		Name:           "mac",
+		Endpoint:       "127.0.0.1:9121",
+		NodeID:         first.NodeID,
+		NodeSecret:     first.NodeSecret,
+		MaxConcurrency: 2,
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 on reattach, got %d: %s", rr.Code, rr.Body.String())
+	}
+	select {
+	case err := <-got:
+		if err != nil {
+			t.Fatalf("queued acquire: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued forward was not dispatched after the limit was raised")
+	}
+}
+
+func TestHandleRegister_RejectsWrongSecret(t *testing.T) {
+	srv := newTestServer()
+
+	_, first := postRegister(t, srv, types.RegisterRequest{Name: "mac", Endpoint: "127.0.0.1:9121"})
+	rr, _ := postRegister(t, srv, types.RegisterRequest{
+		Name:       "impostor",
+		Endpoint:   "127.0.0.1:9999",
+		NodeID:     first.NodeID,
+		NodeSecret: "not-the-secret",
+	})
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rr.Code)
+	}
+}
+
+func TestHandleRegister_ReplacesUnknownIdentity(t *testing.T) {
+	srv := newTestServer()
+
+	rr, resp := postRegister(t, srv, types.RegisterRequest{
+		Name:       "mac",
+		Endpoint:   "127.0.0.1:9121",
+		NodeID:     "node-00112233aabbccdd",
+		NodeSecret: "stored-secret",
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if resp.NodeID == "node-00112233aabbccdd" || resp.NodeSecret == "" {
+		t.Errorf("expected a new identity instead of the client's, got %+v", resp)
+	}
+	if known, _ := srv.registry.CheckNodeSecret("node-00112233aabbccdd", "stored-secret"); known {
+		t.Error("expected the unknown ID not to be created")
+	}
+}
+
+func TestForwardWithFailover(t *testing.T) {
+	reply := func(status int, resp types.MessageResponse) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(resp)
+		}))
+	}
+	down := reply(http.StatusServiceUnavailable, types.MessageResponse{})
+	defer down.Close()
+	echo := reply(http.StatusOK, types.MessageResponse{Response: "echo", GatewayError: true})
+	defer echo.Close()
+	good := reply(http.StatusOK, types.MessageResponse{Response: "answer"})
+	defer good.Close()
+
+	srv := newTestServer()
+	srv.forwarder = NewForwarder()
+	node := func(id string, ts *httptest.Server) *types.Node {
+		n := &types.Node{ID: id, Name: id, Endpoint: strings.TrimPrefix(ts.URL, "http://"), Status: types.NodeStatusOnline}
+		srv.registry.Add(n)
+		return n
+	}
+	nodes := []*types.Node{node("down", down), node("echo", echo), node("good", good)}
+	msg := &types.Message{ID: "m1", Content: "hi"}
+
+	resp, attempts, err := srv.forwardWithFailover(context.Background(), nodes, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Response != "answer" || resp.NodeID != "good" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if len(attempts) != 3 || attempts[0].Error == "" || !attempts[1].GatewayError || attempts[2].Error != "" {
+		t.Fatalf("unexpected attempts %+v", attempts)
+	}
+
+	// Without a healthy node, the echo reply beats an error.
+	resp, _, err = srv.forwardWithFailover(context.Background(), nodes[:2], msg)
+	if err != nil || resp.Response != "echo" || len(resp.Attempts) != 2 {
+		t.Fatalf("expected echo fallback, got %+v, %v", resp, err)
+	}
+}
@@ -14,13 +14,15 @@ import (
 // Store provides persistent storage for routing rules.
 // Data is stored as a JSON file on disk.
 type Store struct {
-	mu   sync.Mutex
-	path string
+	mu    sync.Mutex
+	path  string
+	saved uint64 // revision last written
 }
 
 // storeData is the on-disk JSON structure.
 type storeData struct {
-	Rules []*types.RoutingRule `json:"rules"`
+	Revision uint64               `json:"revision,omitempty"`
+	Rules    []*types.RoutingRule `json:"rules"`
 }
 
 // NewStore creates a store backed by the given file path.
@@ -33,40 +35,51 @@ func NewStore(path string) (*Store, error) {
 	return &Store{path: path}, nil
 }
 
-// LoadRules reads routing rules from disk.
-// Returns an empty slice if the file doesn't exist.
-func (s *Store) LoadRules() ([]*types.RoutingRule, error) {
+// LoadRules reads routing rules and their revision from disk.
+// Returns an empty slice and revision 0 if the file doesn't exist.
+func (s *Store) LoadRules() ([]*types.RoutingRule, uint64, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	data, err := os.ReadFile(s.path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, nil
+			return nil, 0, nil
 		}
-		return nil, fmt.Errorf("reading store: %w", err)
+		return nil, 0, fmt.Errorf("reading store: %w", err)
 	}
 
 	var sd storeData
 	if err := json.Unmarshal(data, &sd); err != nil {
-		return nil, fmt.Errorf("parsing store: %w", err)
+		return nil, 0, fmt.Errorf("parsing store: %w", err)
 	}
-	return sd.Rules, nil
+	return sd.Rules, sd.Revision, nil
 }
 
-// SaveRules writes routing rules to disk atomically.
-func (s *Store) SaveRules(rules []*types.RoutingRule) error {
+// SaveRules writes routing rules and their revision to disk atomically.
+// A save older than the one on disk is skipped, so concurrent changes
+// persisted out of order cannot roll the file back.
+func (s *Store) SaveRules(rules []*types.RoutingRule, revision uint64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	sd := storeData{Rules: rules}
+	if revision != 0 && revision < s.saved {
+		return nil
+	}
+	s.saved = revision
+	sd := storeData{Revision: revision, Rules: rules}
 	data, err := json.MarshalIndent(sd, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshaling store: %w", err)
 	}
 
-	// Atomic write: write to unique temp file, fsync, then rename.
-	tmp := fmt.Sprintf("%s.tmp.%d", s.path, time.Now().UnixNano())
+	return writeFileAtomic(s.path, data)
+}
+
+// writeFileAtomic writes data to path by writing a unique temp file,
+// fsyncing it, and renaming it over the destination.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := fmt.Sprintf("%s.tmp.%d", path, time.Now().UnixNano())
 	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return fmt.Errorf("creating temp store: %w", err)
@@ -83,9 +96,72 @@ func (s *Store) SaveRules(rules []*types.RoutingRule) error {
 	}
 	f.Close()
 
-	if err := os.Rename(tmp, s.path); err != nil {
+	if err := os.Rename(tmp, path); err != nil {
 		os.Remove(tmp)
 		return fmt.Errorf("renaming store: %w", err)
 	}
 	return nil
 }
+
+// NodeStore provides persistent storage for the node registry, so nodes
+// keep their IDs and tokens across coordinator restarts.
+type NodeStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// nodeStoreData is the on-disk JSON structure of the node registry.
+type nodeStoreData struct {
+	Nodes []*persistedNode `json:"nodes"`
+}
+
+// persistedNode is a node entry together with its hashed credentials.
+// Plaintext tokens are never written to disk.
+type persistedNode struct {
+	Node       *types.Node `json:"node"`
+	TokenHash  string      `json:"token_hash,omitempty"`
+	SecretHash string      `json:"secret_hash,omitempty"`
+}
+
+// NewNodeStore creates a node store backed by the given file path.
+// The parent directory is created if it doesn't exist.
+func NewNodeStore(path string) (*NodeStore, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating store directory: %w", err)
+	}
+	return &NodeStore{path: path}, nil
+}
+
+// LoadNodes reads persisted nodes from disk.
+// Returns an empty slice if the file doesn't exist.
+func (s *NodeStore) LoadNodes() ([]*persistedNode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading node store: %w", err)
+	}
+
+	var sd nodeStoreData
+	if err := json.Unmarshal(data, &sd); err != nil {
+		return nil, fmt.Errorf("parsing node store: %w", err)
+	}
+	return sd.Nodes, nil
+}
+
+// SaveNodes writes the node registry to disk atomically.
+func (s *NodeStore) SaveNodes(nodes []*persistedNode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(nodeStoreData{Nodes: nodes}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling node store: %w", err)
+	}
+	return writeFileAtomic(s.path, data)
+}
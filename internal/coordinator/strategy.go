@@ -0,0 +1,198 @@
+package coordinator
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+// defaultStrategy is used when a rule doesn't name one.
+const defaultStrategy = "least-busy"
+
+// weightLabel is the node label read by the weighted strategy.
+const weightLabel = "weight"
+
+// Strategy selects one node from a non-empty list of candidates.
+// key identifies the rule being evaluated, so stateful strategies such as
+// round-robin can keep separate state per rule.
+type Strategy interface {
+	Name() string
+	Description() string
+	Pick(key string, nodes []*types.Node) *types.Node
+}
+
+// peeker is implemented by stateful strategies so that dry runs
+// (route explain) can preview a pick without advancing their state.
+type peeker interface {
+	Peek(key string, nodes []*types.Node) *types.Node
+}
+
+// forgetter is implemented by strategies that keep state per rule, so
+// the router can drop it when the rule is removed or replaced.
+type forgetter interface {
+	Forget(key string)
+}
+
+// LatencySource reports the observed forwarding latency for a node.
+type LatencySource interface {
+	Latency(nodeID string) (time.Duration, bool)
+}
+
+// builtinStrategies returns the strategies every router starts with.
+// The latency strategy has no source until one is registered with
+// Router.RegisterStrategy; without it, it behaves like least-busy.
+func builtinStrategies() []Strategy {
+	return []Strategy{
+		leastBusyStrategy{},
+		&roundRobinStrategy{next: make(map[string]uint64)},
+		weightedStrategy{},
+		randomStrategy{},
+		&latencyStrategy{},
+	}
+}
+
+// leastBusyStrategy picks the node with the lowest load score.
+type leastBusyStrategy struct{}
+
+func (leastBusyStrategy) Name() string { return "least-busy" }
+func (leastBusyStrategy) Description() string {
+	return "lowest load score from heartbeats and in-flight forwards"
+}
+func (leastBusyStrategy) Pick(_ string, nodes []*types.Node) *types.Node { return leastBusy(nodes) }
+
+// roundRobinStrategy cycles through candidates in node ID order.
+type roundRobinStrategy struct {
+	mu   sync.Mutex
+	next map[string]uint64 // rule key -> next index
+}
+
+func (*roundRobinStrategy) Name() string        { return "round-robin" }
+func (*roundRobinStrategy) Description() string { return "cycle through candidates in turn" }
+
+func (s *roundRobinStrategy) Pick(key string, nodes []*types.Node) *types.Node {
+	sorted := sortedByID(nodes)
+	s.mu.Lock()
+	i := s.next[key]
+	s.next[key] = i + 1
+	s.mu.Unlock()
+	return sorted[i%uint64(len(sorted))]
+}
+
+func (s *roundRobinStrategy) Peek(key string, nodes []*types.Node) *types.Node {
+	sorted := sortedByID(nodes)
+	s.mu.Lock()
+	i := s.next[key]
+	s.mu.Unlock()
+	return sorted[i%uint64(len(sorted))]
+}
+
+func (s *roundRobinStrategy) Forget(key string) {
+	s.mu.Lock()
+	delete(s.next, key)
+	s.mu.Unlock()
+}
+
+// weightedStrategy picks randomly in proportion to each node's "weight"
+// label. Nodes without a valid weight count as 1; weight 0 excludes a node
+// unless every candidate has weight 0.
+type weightedStrategy struct{}
+
+func (weightedStrategy) Name() string { return "weighted" }
+func (weightedStrategy) Description() string {
+	return "random, proportional to the node's \"" + weightLabel + "\" label (default 1)"
+}
+
+func (weightedStrategy) Pick(_ string, nodes []*types.Node) *types.Node {
+	sorted := sortedByID(nodes)
+	total := 0
+	weights := make([]int, len(sorted))
+	for i, n := range sorted {
+		weights[i] = nodeWeight(n)
+		total += weights[i]
+	}
+	if total == 0 {
+		return sorted[rand.IntN(len(sorted))]
+	}
+	r := rand.IntN(total)
+	for i, w := range weights {
+		if r < w {
+			return sorted[i]
+		}
+		r -= w
+	}
+	return sorted[len(sorted)-1]
+}
+
+// nodeWeight parses the weight label; missing or invalid values count as 1.
+func nodeWeight(n *types.Node) int {
+	v, ok := n.Labels[weightLabel]
+	if !ok {
+		return 1
+	}
+	w, err := strconv.Atoi(v)
+	if err != nil || w < 0 {
+		return 1
+	}
+	return w
+}
+
+// randomStrategy picks a candidate uniformly at random.
+type randomStrategy struct{}
+
+func (randomStrategy) Name() string        { return "random" }
+func (randomStrategy) Description() string { return "uniformly random candidate" }
+func (randomStrategy) Pick(_ string, nodes []*types.Node) *types.Node {
+	return nodes[rand.IntN(len(nodes))]
+}
+
+// latencyStrategy picks the node with the lowest observed forwarding
+// latency. Nodes with no samples yet are tried first so they get measured.
+type latencyStrategy struct {
+	source LatencySource
+}
+
+func (*latencyStrategy) Name() string { return "latency" }
+func (*latencyStrategy) Description() string {
+	return "lowest observed forwarding latency (EWMA); unmeasured nodes first"
+}
+
+func (s *latencyStrategy) Pick(_ string, nodes []*types.Node) *types.Node {
+	if s.source == nil {
+		return leastBusy(nodes)
+	}
+	var unmeasured []*types.Node
+	var best *types.Node
+	var bestLatency time.Duration
+	for _, n := range sortedByID(nodes) {
+		d, ok := s.source.Latency(n.ID)
+		if !ok {
+			unmeasured = append(unmeasured, n)
+			continue
+		}
+		if best == nil || d < bestLatency {
+			best, bestLatency = n, d
+		}
+	}
+	if len(unmeasured) > 0 {
+		return leastBusy(unmeasured)
+	}
+	return best
+}
+
+// sortedByID returns a copy of nodes ordered by ID.
+func sortedByID(nodes []*types.Node) []*types.Node {
+	out := make([]*types.Node, len(nodes))
+	copy(out, nodes)
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// handleListStrategies handles GET /api/v1/strategies.
+func (s *Server) handleListStrategies(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.router.Strategies())
+}
@@ -0,0 +1,648 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+const (
+	taskWorkers = 4
+	// maxActiveTasks bounds how many tasks may be pending or running.
+	maxActiveTasks = 10000
+	// taskRetention is how long a succeeded task is kept. Dead tasks stay
+	// until they are requeued or purged.
+	taskRetention = 24 * time.Hour
+	// taskIdleWait is how long an idle worker sleeps when nothing is due.
+	taskIdleWait = time.Minute
+
+	// minTaskBackoff is the shortest delay allowed between attempts.
+	minTaskBackoff = 10 * time.Millisecond
+
+	defaultTaskAttempts = 5
+	maxTaskAttempts     = 1000
+	defaultTaskTimeout  = time.Hour
+	maxTaskTimeout      = 30 * 24 * time.Hour
+)
+
+var defaultTaskBackoff = types.BackoffPolicy{Initial: 2, Multiplier: 2, Max: 300}
+
+// Errors from taskQueue.
+var (
+	errTaskQueueFull = errors.New("task queue is full")
+	errTaskNotFound  = errors.New("task not found")
+	errTaskRunning   = errors.New("task is running")
+	errTaskNotDead   = errors.New("only dead tasks can be requeued")
+)
+
+// TaskStore provides persistent storage for the task queue: a snapshot of
+// the tasks in tasks.json plus a journal of the changes made since.
+type TaskStore struct {
+	*journalStore[types.Task]
+}
+
+// NewTaskStore creates a task store backed by the given file path.
+// The parent directory is created if it doesn't exist.
+func NewTaskStore(path string) (*TaskStore, error) {
+	js, err := newJournalStore(path, "task", func(t *types.Task) string { return t.ID })
+	if err != nil {
+		return nil, err
+	}
+	return &TaskStore{js}, nil
+}
+
+// taskRunner makes one attempt at delivering a task's message.
+type taskRunner func(ctx context.Context, t *types.Task) (*types.MessageResponse, error)
+
+// taskQueue is a durable queue of messages that are retried with backoff
+// until a node answers, they run out of attempts or their deadline
+// passes. Every change is journaled to the store, so tasks survive a
+// coordinator restart; a task that was running at the time is retried.
+type taskQueue struct {
+	store  *TaskStore
+	events *EventBus
+	run    taskRunner
+
+	mu    sync.Mutex
+	tasks map[string]*types.Task
+	order []string // IDs, oldest first
+
+	wake      chan struct{}
+	ctx       context.Context // cancelled by stop
+	cancel    context.CancelFunc
+	startOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// newTaskQueue creates a task queue whose workers deliver tasks with run.
+// If store is non-nil, tasks are loaded from and persisted to disk.
+func newTaskQueue(run taskRunner, events *EventBus, store *TaskStore) *taskQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &taskQueue{
+		store:  store,
+		events: events,
+		run:    run,
+		tasks:  make(map[string]*types.Task),
+		wake:   make(chan struct{}, 1),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	if store != nil {
+		tasks, err := store.Load()
+		if err != nil {
+			log.Printf("WARN: failed to load persisted tasks: %v", err)
+		}
+		for _, t := range tasks {
+			if t.Status == types.TaskRunning {
+				// Interrupted by a restart; the attempt may or may not have
+				// reached the node, so it still counts.
+				t.Status = types.TaskPending
+				t.NextAttemptAt = time.Now()
+			}
+			q.tasks[t.ID] = t
+			q.order = append(q.order, t.ID)
+		}
+		if len(tasks) > 0 {
+			log.Printf("loaded %d persisted tasks", len(tasks))
+		}
+		if err == nil {
+			// Start from a fresh snapshot, dropping any torn journal tail.
+			q.compactLocked()
+		}
+	}
+	return q
+}
+
+// start launches the workers. Safe to call multiple times.
+func (q *taskQueue) start() {
+	q.startOnce.Do(func() {
+		q.wg.Add(taskWorkers)
+		for i := 0; i < taskWorkers; i++ {
+			go q.worker()
+		}
+	})
+}
+
+// stop cancels running attempts and waits for the workers to exit. The
+// cancelled tasks are left pending, to be retried on the next start.
+func (q *taskQueue) stop() {
+	q.cancel()
+	q.wg.Wait()
+}
+
+// kick wakes an idle worker.
+func (q *taskQueue) kick() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *taskQueue) worker() {
+	defer q.wg.Done()
+	for {
+		t, wait := q.claim()
+		if t != nil {
+			q.attempt(t)
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-q.ctx.Done():
+			timer.Stop()
+			return
+		case <-q.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// claim marks the oldest due task running and returns a copy of it. If
+// none is due it returns how long until one will be. Pending tasks past
+// their deadline are moved to the dead-letter queue on the way.
+func (q *taskQueue) claim() (*types.Task, time.Duration) {
+	if q.ctx.Err() != nil {
+		return nil, 0
+	}
+	var events []types.Event
+	defer func() {
+		for _, ev := range events {
+			q.events.Publish(ev)
+		}
+	}()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	wait := taskIdleWait
+	var claimed *types.Task
+	var changed []*types.Task
+	pruned := q.pruneLocked(now)
+	for _, id := range q.order {
+		t := q.tasks[id]
+		if t.Status != types.TaskPending {
+			continue
+		}
+		if !now.Before(t.Deadline) {
+			events = append(events, q.buryLocked(t, now, "deadline exceeded"))
+			changed = append(changed, t)
+			continue
+		}
+		if t.NextAttemptAt.After(now) {
+			wait = min(wait, t.NextAttemptAt.Sub(now), t.Deadline.Sub(now))
+			continue
+		}
+		if claimed != nil {
+			// More work is due; let another worker have it.
+			q.kick()
+			break
+		}
+		t.Status = types.TaskRunning
+		t.Attempts++
+		t.UpdatedAt = now
+		claimed = t
+		changed = append(changed, t)
+	}
+	q.persistLocked(changed, pruned)
+	if claimed == nil {
+		return nil, wait
+	}
+	cp := *claimed
+	return &cp, 0
+}
+
+// attempt delivers t once and records the outcome.
+func (q *taskQueue) attempt(t *types.Task) {
+	ctx, cancel := context.WithDeadline(q.ctx, t.Deadline)
+	defer cancel()
+	resp, err := q.run(ctx, t)
+	if err == nil && resp.GatewayError {
+		err = fmt.Errorf("node %s replied with a gateway error", resp.NodeID)
+	}
+	if ev, ok := q.finish(t.ID, resp, err); ok {
+		q.events.Publish(ev)
+	}
+}
+
+// finish records the outcome of an attempt: the task succeeded, is
+// scheduled for a retry, or is dead. It returns the event to publish.
+func (q *taskQueue) finish(id string, resp *types.MessageResponse, err error) (types.Event, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t := q.tasks[id]
+	if t == nil {
+		return types.Event{}, false
+	}
+	now := time.Now()
+	t.UpdatedAt = now
+	defer q.persistLocked([]*types.Task{t}, nil)
+
+	if err != nil && q.ctx.Err() != nil {
+		// Shutting down: the attempt was cut short by us, not the node.
+		t.Status = types.TaskPending
+		t.Attempts--
+		t.NextAttemptAt = now
+		return types.Event{}, false
+	}
+	if err == nil {
+		t.Status = types.TaskSucceeded
+		t.Result = resp
+		t.LastError = ""
+		t.FinishedAt = &now
+		log.Printf("task %s succeeded on node %s after %d attempts", t.ID, resp.NodeID, t.Attempts)
+		return types.Event{
+			Type:   types.EventTaskSucceeded,
+			NodeID: resp.NodeID,
+			Data:   map[string]any{"task_id": t.ID, "attempts": t.Attempts},
+		}, true
+	}
+
+	t.LastError = err.Error()
+	if errors.Is(err, errMessageCancelled) {
+		// Cancelled on purpose; retrying would undo that.
+		t.LastError = ""
+		return q.buryLocked(t, now, "cancelled"), true
+	}
+	if t.Attempts >= t.MaxAttempts {
+		return q.buryLocked(t, now, fmt.Sprintf("failed after %d attempts", t.Attempts)), true
+	}
+	t.NextAttemptAt = now.Add(backoffDelay(t.Backoff, t.Attempts))
+	if !t.NextAttemptAt.Before(t.Deadline) {
+		return q.buryLocked(t, now, "deadline exceeded"), true
+	}
+	t.Status = types.TaskPending
+	log.Printf("task %s attempt %d failed, retrying at %s: %v", t.ID, t.Attempts, t.NextAttemptAt.Format(time.RFC3339), err)
+	return types.Event{
+		Type: types.EventTaskRetrying,
+		Data: map[string]any{
+			"task_id":         t.ID,
+			"attempts":        t.Attempts,
+			"error":           t.LastError,
+			"next_attempt_at": t.NextAttemptAt,
+		},
+	}, true
+}
+
+// buryLocked moves t to the dead-letter queue and returns the event to
+// publish.
+func (q *taskQueue) buryLocked(t *types.Task, now time.Time, reason string) types.Event {
+	t.Status = types.TaskDead
+	t.UpdatedAt = now
+	t.FinishedAt = &now
+	if t.LastError == "" {
+		t.LastError = reason
+	} else {
+		t.LastError = reason + ": " + t.LastError
+	}
+	log.Printf("task %s dead: %s", t.ID, t.LastError)
+	return types.Event{
+		Type: types.EventTaskDead,
+		Data: map[string]any{"task_id": t.ID, "attempts": t.Attempts, "error": t.LastError},
+	}
+}
+
+// backoffDelay returns the delay after the given number of failed attempts.
+// It never exceeds maxTaskTimeout, which is past any task's deadline, so a
+// policy without a max cannot overflow.
+func backoffDelay(p types.BackoffPolicy, attempts int) time.Duration {
+	secs := p.Initial * math.Pow(p.Multiplier, float64(attempts-1))
+	if p.Max > 0 && secs > p.Max {
+		secs = p.Max
+	}
+	secs = min(secs, maxTaskTimeout.Seconds())
+	return time.Duration(secs * float64(time.Second))
+}
+
+// pruneLocked drops tasks that succeeded more than taskRetention ago and
+// returns their IDs.
+func (q *taskQueue) pruneLocked(now time.Time) []string {
+	cutoff := now.Add(-taskRetention)
+	return q.removeLocked(func(t *types.Task) bool {
+		return t.Status == types.TaskSucceeded && t.FinishedAt.Before(cutoff)
+	})
+}
+
+// removeLocked drops the tasks for which fn returns true and returns
+// their IDs.
+func (q *taskQueue) removeLocked(fn func(*types.Task) bool) []string {
+	kept := q.order[:0]
+	var removed []string
+	for _, id := range q.order {
+		if fn(q.tasks[id]) {
+			delete(q.tasks, id)
+			removed = append(removed, id)
+			continue
+		}
+		kept = append(kept, id)
+	}
+	q.order = kept
+	return removed
+}
+
+// persistLocked journals the new state of the changed tasks and the
+// removal of the removed ones, compacting the store as the journal grows.
+func (q *taskQueue) persistLocked(changed []*types.Task, removed []string) {
+	if q.store == nil {
+		return
+	}
+	q.store.persist(changed, removed, len(q.order), q.allLocked)
+}
+
+// compactLocked writes a snapshot of every task, emptying the journal.
+func (q *taskQueue) compactLocked() {
+	q.store.compact(q.allLocked())
+}
+
+// allLocked returns every task, oldest first.
+func (q *taskQueue) allLocked() []*types.Task {
+	tasks := make([]*types.Task, len(q.order))
+	for i, id := range q.order {
+		tasks[i] = q.tasks[id]
+	}
+	return tasks
+}
+
+// add queues a new task, filling in defaults.
+func (q *taskQueue) add(req *types.TaskRequest) (*types.Task, error) {
+	id, err := generatePrefixedID("task")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	t := &types.Task{
+		ID:             id,
+		Status:         types.TaskPending,
+		Content:        req.Content,
+		Source:         req.Source,
+		SessionID:      req.SessionID,
+		TargetNode:     req.TargetNode,
+		MaxAttempts:    req.MaxAttempts,
+		TimeoutSeconds: req.TimeoutSeconds,
+		Backoff:        defaultTaskBackoff,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if t.MaxAttempts == 0 {
+		t.MaxAttempts = defaultTaskAttempts
+	}
+	if t.TimeoutSeconds == 0 {
+		t.TimeoutSeconds = int(defaultTaskTimeout / time.Second)
+	}
+	if req.Backoff != nil {
+		t.Backoff = *req.Backoff
+	}
+	t.Deadline = now.Add(time.Duration(t.TimeoutSeconds) * time.Second)
+
+	q.mu.Lock()
+	active := 0
+	for _, t := range q.tasks {
+		if t.Status == types.TaskPending || t.Status == types.TaskRunning {
+			active++
+		}
+	}
+	if active >= maxActiveTasks {
+		q.mu.Unlock()
+		return nil, errTaskQueueFull
+	}
+	q.tasks[id] = t
+	q.order = append(q.order, id)
+	q.persistLocked([]*types.Task{t}, nil)
+	cp := *t
+	q.mu.Unlock()
+
+	q.kick()
+	return &cp, nil
+}
+
+// get returns a copy of a task, or nil if not found.
+func (q *taskQueue) get(id string) *types.Task {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t := q.tasks[id]
+	if t == nil {
+		return nil
+	}
+	cp := *t
+	return &cp
+}
+
+// list returns copies of the tasks with the given status (all if empty),
+// newest first.
+func (q *taskQueue) list(status types.TaskStatus) []*types.Task {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := []*types.Task{}
+	for i := len(q.order) - 1; i >= 0; i-- {
+		t := q.tasks[q.order[i]]
+		if status == "" || t.Status == status {
+			cp := *t
+			out = append(out, &cp)
+		}
+	}
+	return out
+}
+
+// requeue gives a dead task a fresh set of attempts and a new deadline.
+func (q *taskQueue) requeue(id string) (*types.Task, error) {
+	q.mu.Lock()
+	t := q.tasks[id]
+	if t == nil {
+		q.mu.Unlock()
+		return nil, errTaskNotFound
+	}
+	if t.Status != types.TaskDead {
+		q.mu.Unlock()
+		return nil, errTaskNotDead
+	}
+	now := time.Now()
+	t.Status = types.TaskPending
+	t.Attempts = 0
+	t.LastError = ""
+	t.FinishedAt = nil
+	t.NextAttemptAt = now
+	t.Deadline = now.Add(time.Duration(t.TimeoutSeconds) * time.Second)
+	t.UpdatedAt = now
+	q.persistLocked([]*types.Task{t}, nil)
+	cp := *t
+	q.mu.Unlock()
+
+	log.Printf("task %s requeued", id)
+	q.kick()
+	return &cp, nil
+}
+
+// remove deletes a task that is not running.
+func (q *taskQueue) remove(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t := q.tasks[id]
+	if t == nil {
+		return errTaskNotFound
+	}
+	if t.Status == types.TaskRunning {
+		return errTaskRunning
+	}
+	q.persistLocked(nil, q.removeLocked(func(t *types.Task) bool { return t.ID == id }))
+	return nil
+}
+
+// purge deletes every task with the given status and returns how many
+// there were. Running tasks cannot be purged.
+func (q *taskQueue) purge(status types.TaskStatus) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	removed := q.removeLocked(func(t *types.Task) bool { return t.Status == status })
+	q.persistLocked(nil, removed)
+	return len(removed)
+}
+
+// runTask makes one attempt at a task as a new message.
+func (s *Server) runTask(ctx context.Context, t *types.Task) (*types.MessageResponse, error) {
+	msgID, err := generateID()
+	if err != nil {
+		return nil, err
+	}
+	return s.deliver(ctx, &types.Message{
+		ID:         msgID,
+		Content:    t.Content,
+		Source:     t.Source,
+		SessionID:  t.SessionID,
+		TargetNode: t.TargetNode,
+		CreatedAt:  time.Now(),
+	})
+}
+
+// validateTaskRequest checks a task submitted through the API.
+func validateTaskRequest(req *types.TaskRequest) error {
+	if req.Content == "" {
+		return fmt.Errorf("content is required")
+	}
+	if req.MaxAttempts < 0 || req.MaxAttempts > maxTaskAttempts {
+		return fmt.Errorf("max_attempts must be between 0 and %d", maxTaskAttempts)
+	}
+	if req.TimeoutSeconds < 0 || req.TimeoutSeconds > int(maxTaskTimeout/time.Second) {
+		return fmt.Errorf("timeout_seconds must be between 0 and %d", int(maxTaskTimeout.Seconds()))
+	}
+	if b := req.Backoff; b != nil {
+		// A zero delay would send every attempt straight after the last.
+		if b.Initial < minTaskBackoff.Seconds() {
+			return fmt.Errorf("backoff initial must be at least %g seconds", minTaskBackoff.Seconds())
+		}
+		if b.Max != 0 && b.Max < b.Initial {
+			return fmt.Errorf("backoff max must be 0 (no max) or at least initial")
+		}
+		if b.Multiplier < 1 {
+			return fmt.Errorf("backoff multiplier must be at least 1")
+		}
+	}
+	return nil
+}
+
+// parseTaskStatus parses a ?status= filter.
+func parseTaskStatus(v string) (types.TaskStatus, error) {
+	switch s := types.TaskStatus(v); s {
+	case "", types.TaskPending, types.TaskRunning, types.TaskSucceeded, types.TaskDead:
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid status %q", v)
+}
+
+// handleAddTask handles POST /api/v1/tasks.
+func (s *Server) handleAddTask(w http.ResponseWriter, r *http.Request) {
+	var req types.TaskRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if err := validateTaskRequest(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if req.TargetNode != "" && s.registry.Get(req.TargetNode) == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "target node not found"})
+		return
+	}
+	t, err := s.tasks.add(&req)
+	if errors.Is(err, errTaskQueueFull) {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to generate task ID"})
+		return
+	}
+	log.Printf("task %s queued", t.ID)
+	writeJSON(w, http.StatusCreated, t)
+}
+
+// handleListTasks handles GET /api/v1/tasks, optionally filtered by
+// ?status=pending|running|succeeded|dead.
+func (s *Server) handleListTasks(w http.ResponseWriter, r *http.Request) {
+	status, err := parseTaskStatus(r.URL.Query().Get("status"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.tasks.list(status))
+}
+
+// handleGetTask handles GET /api/v1/tasks/{id}.
+func (s *Server) handleGetTask(w http.ResponseWriter, r *http.Request) {
+	t := s.tasks.get(r.PathValue("id"))
+	if t == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+// handleRequeueTask handles POST /api/v1/tasks/{id}/requeue — move a task
+// out of the dead-letter queue for another round of attempts.
+func (s *Server) handleRequeueTask(w http.ResponseWriter, r *http.Request) {
+	t, err := s.tasks.requeue(r.PathValue("id"))
+	switch {
+	case errors.Is(err, errTaskNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusOK, t)
+	}
+}
+
+// handleDeleteTask handles DELETE /api/v1/tasks/{id}.
+func (s *Server) handleDeleteTask(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	err := s.tasks.remove(id)
+	switch {
+	case errors.Is(err, errTaskNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		log.Printf("task deleted: %s", id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handlePurgeTasks handles DELETE /api/v1/tasks?status=... — delete every
+// task with the status, e.g. to empty the dead-letter queue.
+func (s *Server) handlePurgeTasks(w http.ResponseWriter, r *http.Request) {
+	status, err := parseTaskStatus(r.URL.Query().Get("status"))
+	if err != nil || status == "" || status == types.TaskRunning {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "status must be pending, succeeded or dead"})
+		return
+	}
+	n := s.tasks.purge(status)
+	log.Printf("purged %d %s tasks", n, status)
+	writeJSON(w, http.StatusOK, map[string]int{"purged": n})
+}
@@ -0,0 +1,132 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+func TestTaskQueue(t *testing.T) {
+	store, err := NewTaskStore(filepath.Join(t.TempDir(), "tasks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls atomic.Int32
+	flaky := func(ctx context.Context, task *types.Task) (*types.MessageResponse, error) {
+		if task.Content == "always fails" || calls.Add(1) < 3 {
+			return nil, errors.New("node unreachable")
+		}
+		return &types.MessageResponse{NodeID: "n1", Response: "ok"}, nil
+	}
+	q := newTaskQueue(flaky, nil, store)
+	q.start()
+	defer q.stop()
+
+	waitFor := func(id string, status types.TaskStatus) *types.Task {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if task := q.get(id); task.Status == status {
+				return task
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("task %s did not become %s: %+v", id, status, q.get(id))
+		return nil
+	}
+	backoff := &types.BackoffPolicy{Initial: 0.01, Multiplier: 2, Max: 0.05}
+
+	// Retried with backoff until the node answers.
+	ok, err := q.add(&types.TaskRequest{Content: "hi", Backoff: backoff})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := waitFor(ok.ID, types.TaskSucceeded); got.Attempts != 3 || got.Result.Response != "ok" {
+		t.Fatalf("unexpected succeeded task %+v", got)
+	}
+
+	// Out of attempts: dead-lettered, and a requeue starts over.
+	bad, _ := q.add(&types.TaskRequest{Content: "always fails", MaxAttempts: 2, Backoff: backoff})
+	if got := waitFor(bad.ID, types.TaskDead); got.Attempts != 2 || got.LastError == "" {
+		t.Fatalf("unexpected dead task %+v", got)
+	}
+	if _, err := q.requeue(ok.ID); !errors.Is(err, errTaskNotDead) {
+		t.Fatalf("requeue of a succeeded task: got %v", err)
+	}
+	if _, err := q.requeue(bad.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(bad.ID, types.TaskDead)
+
+	// A deadline that passes before the next retry ends the task early.
+	late, _ := q.add(&types.TaskRequest{Content: "always fails", TimeoutSeconds: 1, Backoff: &types.BackoffPolicy{Initial: 5, Multiplier: 1}})
+	if got := waitFor(late.ID, types.TaskDead); got.Attempts != 1 {
+		t.Fatalf("expected one attempt before the deadline, got %+v", got)
+	}
+
+	if n := q.purge(types.TaskDead); n != 2 {
+		t.Fatalf("purged %d dead tasks, want 2", n)
+	}
+
+	// Tasks survive a restart, even with a torn journal record; one that
+	// was running is retried.
+	q.stop()
+	q.mu.Lock()
+	q.tasks[ok.ID].Status = types.TaskRunning
+	q.persistLocked([]*types.Task{q.tasks[ok.ID]}, nil)
+	q.mu.Unlock()
+	if store.JournalLen() == 0 {
+		t.Fatal("expected changes to be journaled")
+	}
+	f, err := os.OpenFile(store.journalPath(), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"seq": 99999, "task": {"id": "tor`)
+	f.Close()
+	reloaded := newTaskQueue(flaky, nil, store)
+	if tasks := reloaded.list(""); len(tasks) != 1 || tasks[0].Status != types.TaskPending {
+		t.Fatalf("unexpected reloaded tasks %+v", tasks)
+	}
+	if store.JournalLen() != 0 {
+		t.Fatal("expected the journal to be compacted on load")
+	}
+}
+
+func TestValidateTaskRequest_Timeout(t *testing.T) {
+	for _, secs := range []int{-1, int(maxTaskTimeout/time.Second) + 1, math.MaxInt64 / 1000} {
+		if err := validateTaskRequest(&types.TaskRequest{Content: "hi", TimeoutSeconds: secs}); err == nil {
+			t.Errorf("expected timeout_seconds %d to be rejected", secs)
+		}
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	steep := types.BackoffPolicy{Initial: 1, Multiplier: 10}
+	if got := backoffDelay(steep, 3); got != 100*time.Second {
+		t.Fatalf("backoffDelay after 3 attempts = %s, want 100s", got)
+	}
+	// Without a max the delay grows until it is clamped, never overflowing.
+	for _, attempts := range []int{11, 40, maxTaskAttempts} {
+		for _, p := range []types.BackoffPolicy{steep, defaultTaskBackoff, {Initial: 1, Multiplier: 2}} {
+			if got := backoffDelay(p, attempts); got <= 0 || got > maxTaskTimeout {
+				t.Errorf("backoffDelay(%+v, %d) = %s", p, attempts, got)
+			}
+		}
+	}
+	if err := validateTaskRequest(&types.TaskRequest{Content: "hi", MaxAttempts: maxTaskAttempts + 1}); err == nil {
+		t.Error("expected max_attempts above the limit to be rejected")
+	}
+	for _, b := range []types.BackoffPolicy{{Initial: 0, Multiplier: 2}, {Initial: 1, Multiplier: 2, Max: 0.001}} {
+		if err := validateTaskRequest(&types.TaskRequest{Content: "hi", Backoff: &b}); err == nil {
+			t.Errorf("expected backoff %+v to be rejected", b)
+		}
+	}
+}
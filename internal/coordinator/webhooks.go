@@ -0,0 +1,635 @@
+package coordinator
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+const (
+	webhookWorkers   = 4
+	webhookQueueSize = 256
+	webhookLogSize   = 50 // deliveries kept per webhook
+	webhookTimeout   = 10 * time.Second
+)
+
+// webhookBackoffs are the delays before each retry of a failed delivery.
+var webhookBackoffs = []time.Duration{1 * time.Second, 5 * time.Second, 30 * time.Second}
+
+// WebhookStore provides persistent storage for webhook subscriptions.
+type WebhookStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// webhookStoreData is the on-disk JSON structure.
+type webhookStoreData struct {
+	Webhooks []*types.Webhook `json:"webhooks"`
+}
+
+// NewWebhookStore creates a webhook store backed by the given file path.
+// The parent directory is created if it doesn't exist.
+func NewWebhookStore(path string) (*WebhookStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("creating store directory: %w", err)
+	}
+	return &WebhookStore{path: path}, nil
+}
+
+// LoadWebhooks reads webhook subscriptions from disk.
+// Returns an empty slice if the file doesn't exist.
+func (s *WebhookStore) LoadWebhooks() ([]*types.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading webhook store: %w", err)
+	}
+	var sd webhookStoreData
+	if err := json.Unmarshal(data, &sd); err != nil {
+		return nil, fmt.Errorf("parsing webhook store: %w", err)
+	}
+	return sd.Webhooks, nil
+}
+
+// SaveWebhooks writes webhook subscriptions to disk atomically.
+func (s *WebhookStore) SaveWebhooks(hooks []*types.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(webhookStoreData{Webhooks: hooks}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling webhook store: %w", err)
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// WebhookManager delivers mesh events from the event bus to webhook
+// subscribers, with retries, backoff and a per-webhook delivery log.
+type WebhookManager struct {
+	mu         sync.RWMutex
+	hooks      []*types.Webhook
+	deliveries map[string][]*types.WebhookDelivery // webhookID -> newest last
+
+	store  *WebhookStore
+	events *EventBus
+	client *http.Client
+	queue  chan *webhookJob
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+}
+
+// webhookJob is a single pending delivery.
+type webhookJob struct {
+	hook     types.Webhook
+	event    types.Event
+	delivery *types.WebhookDelivery
+}
+
+// NewWebhookManager creates a webhook manager fed by events.
+// If store is non-nil, subscriptions are loaded from and persisted to disk.
+// Unless allowPrivate is true, deliveries never connect to loopback or
+// private addresses, whatever a hook's host resolves or redirects to.
+func NewWebhookManager(events *EventBus, store *WebhookStore, allowPrivate bool) *WebhookManager {
+	m := &WebhookManager{
+		deliveries: make(map[string][]*types.WebhookDelivery),
+		store:      store,
+		events:     events,
+		client:     newWebhookClient(allowPrivate),
+		queue:      make(chan *webhookJob, webhookQueueSize),
+		stopCh:     make(chan struct{}),
+	}
+	if store != nil {
+		if hooks, err := store.LoadWebhooks(); err != nil {
+			log.Printf("WARN: failed to load persisted webhooks: %v", err)
+		} else if len(hooks) > 0 {
+			m.hooks = hooks
+			log.Printf("loaded %d persisted webhooks", len(hooks))
+		}
+	}
+	return m
+}
+
+// newWebhookClient returns the HTTP client for deliveries. Hooks are
+// validated when added, but a host may later resolve to, or redirect to,
+// a private address, so unless allowPrivate is true the client checks
+// redirects and every address it dials.
+func newWebhookClient(allowPrivate bool) *http.Client {
+	client := &http.Client{Timeout: webhookTimeout}
+	if allowPrivate {
+		return client
+	}
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip != nil && isBlockedWebhookIP(ip) {
+				return fmt.Errorf("webhook address %s is private/loopback (set allow_private to permit)", host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// A proxy would be dialed instead of the hook's host, defeating the check.
+	transport.Proxy = nil
+	client.Transport = transport
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return fmt.Errorf("redirect to a non-http(s) URL")
+		}
+		if err := checkHost(req.URL.Hostname(), isBlockedWebhookIP); err != nil {
+			return fmt.Errorf("redirect url %w", err)
+		}
+		return nil
+	}
+	return client
+}
+
+// cgnatRange is the carrier-grade NAT range, 100.64.0.0/10.
+var cgnatRange = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isBlockedWebhookIP reports whether a webhook may not reach ip. Webhook
+// URLs come from API callers, so besides isPrivateIP this rejects every
+// address that can lead back into the coordinator's network: unspecified
+// (0.0.0.0 and [::] reach localhost), IPv6 unique local, multicast and
+// carrier-grade NAT addresses.
+func isBlockedWebhookIP(ip net.IP) bool {
+	return isPrivateIP(ip) || ip.IsUnspecified() || ip.IsPrivate() || ip.IsMulticast() || cgnatRange.Contains(ip)
+}
+
+// Start begins consuming events and delivering them.
+// Safe to call multiple times; only the first call starts the workers.
+func (m *WebhookManager) Start() {
+	m.startOnce.Do(func() {
+		// Subscribe before returning so no event published after Start is missed.
+		_, ch, cancel := m.events.Subscribe(nil, 0)
+		m.wg.Add(1 + webhookWorkers)
+		go m.dispatchLoop(ch, cancel)
+		for i := 0; i < webhookWorkers; i++ {
+			go m.worker()
+		}
+	})
+}
+
+// Stop terminates event consumption and waits for workers to exit.
+// Pending retries are abandoned.
+func (m *WebhookManager) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stopCh)
+	})
+	m.wg.Wait()
+}
+
+// dispatchLoop subscribes to the event bus and fans events out to matching
+// webhooks. If the subscription is dropped for falling behind, it
+// resubscribes and resumes from the last event seen.
+func (m *WebhookManager) dispatchLoop(ch <-chan types.Event, cancel func()) {
+	defer m.wg.Done()
+	var last uint64
+	for {
+	recv:
+		for {
+			select {
+			case <-m.stopCh:
+				cancel()
+				return
+			case ev, ok := <-ch:
+				if !ok {
+					break recv
+				}
+				m.dispatch(ev)
+				last = ev.ID
+			}
+		}
+		cancel()
+		select {
+		case <-m.stopCh:
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		var backlog []types.Event
+		backlog, ch, cancel = m.events.Subscribe(nil, last)
+		for _, ev := range backlog {
+			m.dispatch(ev)
+			last = ev.ID
+		}
+	}
+}
+
+// dispatch queues a delivery of ev to every enabled webhook it matches.
+func (m *WebhookManager) dispatch(ev types.Event) {
+	m.mu.RLock()
+	var targets []types.Webhook
+	for _, h := range m.hooks {
+		if !h.Disabled && parseEventFilter(h.Events).match(ev.Type) {
+			targets = append(targets, *h)
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, h := range targets {
+		m.enqueue(h, ev)
+	}
+}
+
+// enqueue records a new delivery and hands it to the workers.
+func (m *WebhookManager) enqueue(h types.Webhook, ev types.Event) *types.WebhookDelivery {
+	id, err := generatePrefixedID("dlv")
+	if err != nil {
+		log.Printf("WARN: webhook %s: %v", h.ID, err)
+		return nil
+	}
+	now := time.Now()
+	d := &types.WebhookDelivery{
+		ID:        id,
+		WebhookID: h.ID,
+		EventID:   ev.ID,
+		EventType: ev.Type,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	m.recordDelivery(d)
+
+	select {
+	case m.queue <- &webhookJob{hook: h, event: ev, delivery: d}:
+	default:
+		m.updateDelivery(d, func(d *types.WebhookDelivery) {
+			d.Error = "delivery queue full, event dropped"
+		})
+		log.Printf("WARN: webhook queue full, dropping event %d for webhook %s", ev.ID, h.ID)
+	}
+	return d
+}
+
+func (m *WebhookManager) worker() {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case job := <-m.queue:
+			m.attempt(job)
+		}
+	}
+}
+
+// attempt performs one delivery attempt and schedules a retry with backoff
+// on retryable failures.
+func (m *WebhookManager) attempt(job *webhookJob) {
+	status, err := m.deliver(&job.hook, &job.event, job.delivery.ID)
+	retryable := err != nil && (status == 0 || status == http.StatusTooManyRequests || status >= 500)
+
+	var attempts int
+	m.updateDelivery(job.delivery, func(d *types.WebhookDelivery) {
+		d.Attempts++
+		d.StatusCode = status
+		d.Success = err == nil
+		d.Error = ""
+		if err != nil {
+			d.Error = err.Error()
+		}
+		attempts = d.Attempts
+	})
+
+	if !retryable || attempts > len(webhookBackoffs) {
+		if err != nil {
+			log.Printf("webhook %s: delivery of event %d failed after %d attempts: %v", job.hook.ID, job.event.ID, attempts, err)
+		}
+		return
+	}
+	time.AfterFunc(webhookBackoffs[attempts-1], func() {
+		select {
+		case m.queue <- job:
+		case <-m.stopCh:
+		}
+	})
+}
+
+// deliver POSTs the event to the webhook URL. It returns the HTTP status
+// (0 on network errors) and a non-nil error unless the status is 2xx.
+func (m *WebhookManager) deliver(h *types.Webhook, ev *types.Event, deliveryID string) (int, error) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return 0, fmt.Errorf("marshaling event: %w", err)
+	}
+	ts := time.Now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "claw-mesh-webhook")
+	req.Header.Set("X-Claw-Mesh-Event", string(ev.Type))
+	req.Header.Set("X-Claw-Mesh-Delivery", deliveryID)
+	req.Header.Set("X-Claw-Mesh-Timestamp", strconv.FormatInt(ts, 10))
+	if h.Secret != "" {
+		req.Header.Set("X-Claw-Mesh-Signature", signWebhook(h.Secret, ts, body))
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// signWebhook computes the X-Claw-Mesh-Signature header value:
+// "sha256=" + hex(HMAC-SHA256(secret, "<timestamp>.<body>")).
+func signWebhook(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", ts)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (m *WebhookManager) recordDelivery(d *types.WebhookDelivery) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := append(m.deliveries[d.WebhookID], d)
+	if len(entries) > webhookLogSize {
+		entries = entries[len(entries)-webhookLogSize:]
+	}
+	m.deliveries[d.WebhookID] = entries
+}
+
+func (m *WebhookManager) updateDelivery(d *types.WebhookDelivery, fn func(*types.WebhookDelivery)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(d)
+	d.UpdatedAt = time.Now()
+}
+
+// Add validates and stores a new webhook, generating an ID and, if none
+// was given, a signing secret.
+func (m *WebhookManager) Add(h *types.Webhook) error {
+	id, err := generatePrefixedID("hook")
+	if err != nil {
+		return err
+	}
+	if h.Secret == "" {
+		secret, err := generateToken()
+		if err != nil {
+			return err
+		}
+		h.Secret = secret
+	}
+	h.ID = id
+	h.CreatedAt = time.Now()
+
+	m.mu.Lock()
+	m.hooks = append(m.hooks, h)
+	hooks := m.snapshotLocked()
+	m.mu.Unlock()
+	if err := m.persist(hooks); err != nil {
+		// The caller never gets the secret, so the hook must not deliver.
+		m.mu.Lock()
+		m.hooks = slices.DeleteFunc(m.hooks, func(x *types.Webhook) bool { return x.ID == h.ID })
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Remove deletes a webhook and its delivery log. Returns false if not found.
+func (m *WebhookManager) Remove(id string) (bool, error) {
+	m.mu.Lock()
+	found := false
+	for i, h := range m.hooks {
+		if h.ID == id {
+			m.hooks = append(m.hooks[:i], m.hooks[i+1:]...)
+			found = true
+			break
+		}
+	}
+	delete(m.deliveries, id)
+	hooks := m.snapshotLocked()
+	m.mu.Unlock()
+	if !found {
+		return false, nil
+	}
+	return true, m.persist(hooks)
+}
+
+// Get returns a copy of a webhook by ID, or nil if not found.
+func (m *WebhookManager) Get(id string) *types.Webhook {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, h := range m.hooks {
+		if h.ID == id {
+			cp := *h
+			return &cp
+		}
+	}
+	return nil
+}
+
+// List returns copies of all webhooks.
+func (m *WebhookManager) List() []*types.Webhook {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.snapshotLocked()
+}
+
+// Deliveries returns copies of the recent deliveries for a webhook, newest first.
+func (m *WebhookManager) Deliveries(id string) []*types.WebhookDelivery {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entries := m.deliveries[id]
+	out := make([]*types.WebhookDelivery, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		cp := *entries[i]
+		out = append(out, &cp)
+	}
+	return out
+}
+
+// Test sends a synthetic webhook.test event to a single webhook, regardless
+// of its event filter. Returns nil if the webhook doesn't exist.
+func (m *WebhookManager) Test(id string) *types.WebhookDelivery {
+	h := m.Get(id)
+	if h == nil {
+		return nil
+	}
+	d := m.enqueue(*h, types.Event{
+		Type: types.EventWebhookTest,
+		Time: time.Now(),
+		Data: map[string]any{"webhook_id": id},
+	})
+	if d == nil {
+		return nil
+	}
+	// A worker may already be updating the delivery.
+	m.mu.RLock()
+	cp := *d
+	m.mu.RUnlock()
+	return &cp
+}
+
+func (m *WebhookManager) snapshotLocked() []*types.Webhook {
+	out := make([]*types.Webhook, len(m.hooks))
+	for i, h := range m.hooks {
+		cp := *h
+		cp.Events = append([]string(nil), h.Events...)
+		out[i] = &cp
+	}
+	return out
+}
+
+func (m *WebhookManager) persist(hooks []*types.Webhook) error {
+	if m.store == nil {
+		return nil
+	}
+	return m.store.SaveWebhooks(hooks)
+}
+
+// validateWebhook checks a webhook subscription submitted through the API.
+// Unless allowPrivate is true, URLs on hosts isBlockedWebhookIP rejects
+// are refused.
+func validateWebhook(h *types.Webhook, allowPrivate bool) error {
+	u, err := url.Parse(h.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("url must be an absolute http(s) URL")
+	}
+	if !allowPrivate {
+		if err := checkHost(u.Hostname(), isBlockedWebhookIP); err != nil {
+			return fmt.Errorf("url %w", err)
+		}
+	}
+	for _, p := range h.Events {
+		if strings.TrimSpace(p) == "" {
+			return fmt.Errorf("event patterns must not be empty")
+		}
+		if strings.Contains(strings.TrimSuffix(p, "*"), "*") {
+			return fmt.Errorf("invalid event pattern %q: '*' is only allowed at the end", p)
+		}
+	}
+	return nil
+}
+
+// redactWebhook hides the signing secret in API responses.
+func redactWebhook(h *types.Webhook) *types.Webhook {
+	cp := *h
+	cp.Secret = ""
+	return &cp
+}
+
+// handleListWebhooks handles GET /api/v1/webhooks.
+func (s *Server) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks := s.webhooks.List()
+	for i, h := range hooks {
+		hooks[i] = redactWebhook(h)
+	}
+	writeJSON(w, http.StatusOK, hooks)
+}
+
+// handleAddWebhook handles POST /api/v1/webhooks.
+// The response is the only place the signing secret is returned.
+func (s *Server) handleAddWebhook(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL      string   `json:"url"`
+		Events   []string `json:"events"`
+		Secret   string   `json:"secret"`
+		Disabled bool     `json:"disabled"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	hook := &types.Webhook{URL: req.URL, Events: req.Events, Secret: req.Secret, Disabled: req.Disabled}
+	if err := validateWebhook(hook, s.cfg.AllowPrivate); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := s.webhooks.Add(hook); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("adding webhook: %v", err)})
+		return
+	}
+	log.Printf("webhook added: %s -> %s", hook.ID, hook.URL)
+	writeJSON(w, http.StatusCreated, hook)
+}
+
+// handleGetWebhook handles GET /api/v1/webhooks/{id}.
+func (s *Server) handleGetWebhook(w http.ResponseWriter, r *http.Request) {
+	h := s.webhooks.Get(r.PathValue("id"))
+	if h == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "webhook not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, redactWebhook(h))
+}
+
+// handleDeleteWebhook handles DELETE /api/v1/webhooks/{id}.
+func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	found, err := s.webhooks.Remove(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("persisting webhook deletion: %v", err)})
+		return
+	}
+	if !found {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "webhook not found"})
+		return
+	}
+	log.Printf("webhook deleted: %s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleWebhookDeliveries handles GET /api/v1/webhooks/{id}/deliveries.
+func (s *Server) handleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if s.webhooks.Get(id) == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "webhook not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.webhooks.Deliveries(id))
+}
+
+// handleTestWebhook handles POST /api/v1/webhooks/{id}/test.
+func (s *Server) handleTestWebhook(w http.ResponseWriter, r *http.Request) {
+	d := s.webhooks.Test(r.PathValue("id"))
+	if d == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "webhook not found"})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, d)
+}
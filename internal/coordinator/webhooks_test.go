@@ -0,0 +1,130 @@
+package coordinator
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+)
+
+func TestWebhookManager_SignsAndRetries(t *testing.T) {
+	orig := webhookBackoffs
+	webhookBackoffs = []time.Duration{10 * time.Millisecond}
+	defer func() { webhookBackoffs = orig }()
+
+	var calls atomic.Int32
+	badSig := make(chan string, 4)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		stamp, _ := strconv.ParseInt(r.Header.Get("X-Claw-Mesh-Timestamp"), 10, 64)
+		if got, want := r.Header.Get("X-Claw-Mesh-Signature"), signWebhook("s3cret", stamp, body); got != want {
+			badSig <- got
+		}
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	bus := NewEventBus(0)
+	m := NewWebhookManager(bus, nil, true)
+	hook := &types.Webhook{URL: ts.URL, Events: []string{"node.offline"}, Secret: "s3cret"}
+	if err := m.Add(hook); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	m.Start()
+	defer m.Stop()
+
+	bus.Publish(types.Event{Type: types.EventRuleAdded})
+	bus.Publish(types.Event{Type: types.EventNodeOffline, NodeID: "node-1"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ds := m.Deliveries(hook.ID)
+		if len(ds) == 1 && ds[0].Success {
+			if ds[0].Attempts != 2 || ds[0].EventType != types.EventNodeOffline {
+				t.Fatalf("unexpected delivery: %+v", ds[0])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery did not succeed: %+v", ds)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case sig := <-badSig:
+		t.Fatalf("signature mismatch: %q", sig)
+	default:
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	tests := []struct {
+		hook         types.Webhook
+		allowPrivate bool
+		ok           bool
+	}{
+		{types.Webhook{URL: "https://hooks.example.com/x"}, false, true},
+		{types.Webhook{URL: "http://10.0.0.5:8080/alert", Events: []string{"node.*"}}, true, true},
+		{types.Webhook{URL: "http://10.0.0.5:8080/alert"}, false, false},
+		{types.Webhook{URL: "http://127.0.0.1/x"}, false, false},
+		{types.Webhook{URL: "http://169.254.169.254/latest/meta-data"}, false, false},
+		{types.Webhook{URL: "http://0.0.0.0:9180/x"}, false, false},
+		{types.Webhook{URL: "http://[::]:9180/x"}, false, false},
+		{types.Webhook{URL: "http://[fd00::1]/x"}, false, false},
+		{types.Webhook{URL: "http://100.64.0.1/x"}, false, false},
+		{types.Webhook{URL: "http://224.0.0.1/x"}, false, false},
+		{types.Webhook{URL: "http://[2001:db8::1]/x"}, false, true},
+		{types.Webhook{URL: "ftp://example.com"}, false, false},
+		{types.Webhook{URL: "/relative"}, false, false},
+		{types.Webhook{URL: "https://example.com", Events: []string{"no*de"}}, false, false},
+	}
+	for _, tt := range tests {
+		if err := validateWebhook(&tt.hook, tt.allowPrivate); (err == nil) != tt.ok {
+			t.Errorf("validateWebhook(%+v, %v) = %v, want ok=%v", tt.hook, tt.allowPrivate, err, tt.ok)
+		}
+	}
+}
+
+func TestWebhookClient_RejectsPrivateAddresses(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	if _, err := newWebhookClient(false).Get(ts.URL); err == nil || !strings.Contains(err.Error(), "private/loopback") {
+		t.Fatalf("expected a loopback dial to be refused, got %v", err)
+	}
+	resp, err := newWebhookClient(true).Get(ts.URL)
+	if err != nil {
+		t.Fatalf("allow_private client: %v", err)
+	}
+	resp.Body.Close()
+
+	client := newWebhookClient(false)
+	req := httptest.NewRequest(http.MethodGet, "https://203.0.113.10/x", nil)
+	if err := client.CheckRedirect(req, nil); err != nil {
+		t.Fatalf("public redirect refused: %v", err)
+	}
+	for _, target := range []string{"http://169.254.169.254/latest/meta-data", "http://0.0.0.0/x", "http://[fc00::1]/x", "http://100.100.1.1/x"} {
+		req = httptest.NewRequest(http.MethodGet, target, nil)
+		if err := client.CheckRedirect(req, nil); err == nil {
+			t.Errorf("expected a redirect to %s to be refused", target)
+		}
+	}
+
+	// 0.0.0.0 reaches the local host on Linux; the dial must refuse it.
+	port := ts.URL[strings.LastIndex(ts.URL, ":"):]
+	if _, err := client.Get("http://0.0.0.0" + port); err == nil || !strings.Contains(err.Error(), "private/loopback") {
+		t.Fatalf("expected a dial to 0.0.0.0 to be refused, got %v", err)
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"runtime"
 	"sync"
 	"time"
 
@@ -26,16 +28,21 @@ type Agent struct {
 	name           string
 	endpoint       string
 	capabilities   types.Capabilities
+	labels         map[string]string
+	maxConcurrency int
 
 	gatewayEndpoint string
 	gatewayToken    string
 	gatewayTimeout  int
 
-	nodeID string
-	client *http.Client
+	nodeID         string
+	nodeSecret     string
+	credentialPath string
+	client         *http.Client
 
 	listenAddr string
 	httpServer *http.Server
+	handler    *Handler
 
 	startOnce sync.Once
 	stopOnce  sync.Once
@@ -51,10 +58,13 @@ type AgentConfig struct {
 	Name            string
 	Endpoint        string
 	Tags            []string
-	ListenAddr      string // address for the local message handler (default: :9121)
-	GatewayEndpoint string // OpenClaw Gateway endpoint (default: auto-discover)
-	GatewayToken    string // OpenClaw Gateway auth token
-	GatewayTimeout  int    // Gateway request timeout in seconds (default: 120)
+	Labels          map[string]string // key/value labels for label-selector routing
+	MaxConcurrency  int               // concurrent messages the node accepts (0: unlimited)
+	ListenAddr      string            // address for the local message handler (default: :9121)
+	GatewayEndpoint string            // OpenClaw Gateway endpoint (default: auto-discover)
+	GatewayToken    string            // OpenClaw Gateway auth token
+	GatewayTimeout  int               // Gateway request timeout in seconds (default: 120)
+	CredentialPath  string            // file storing the node identity (empty: don't persist)
 }
 
 // NewAgent creates a node agent with the given configuration.
@@ -64,29 +74,89 @@ func NewAgent(cfg AgentConfig) *Agent {
 	if listenAddr == "" {
 		listenAddr = ":9121"
 	}
-	return &Agent{
+	a := &Agent{
 		coordinatorURL:  cfg.CoordinatorURL,
 		token:           cfg.Token,
 		adminToken:      cfg.Token,
 		name:            cfg.Name,
 		endpoint:        cfg.Endpoint,
 		capabilities:    caps,
+		labels:          cfg.Labels,
+		maxConcurrency:  cfg.MaxConcurrency,
 		gatewayEndpoint: cfg.GatewayEndpoint,
 		gatewayToken:    cfg.GatewayToken,
 		gatewayTimeout:  cfg.GatewayTimeout,
 		client:          &http.Client{Timeout: 10 * time.Second},
 		listenAddr:      listenAddr,
+		credentialPath:  cfg.CredentialPath,
 		stopCh:          make(chan struct{}),
 		done:            make(chan struct{}),
 	}
+	a.loadCredential()
+	return a
 }
 
-// Register sends a registration request to the coordinator.
+// loadCredential restores a previously issued node identity, if one was
+// stored for the same coordinator.
+func (a *Agent) loadCredential() {
+	if a.credentialPath == "" {
+		return
+	}
+	cred, err := LoadCredential(a.credentialPath)
+	if err != nil {
+		log.Printf("WARN: ignoring node credential: %v", err)
+		return
+	}
+	if cred == nil || cred.Coordinator != a.coordinatorURL {
+		return
+	}
+	a.nodeID = cred.NodeID
+	a.nodeSecret = cred.NodeSecret
+	log.Printf("loaded node identity %s from %s", a.nodeID, a.credentialPath)
+}
+
+// saveCredential stores the current node identity, if persistence is enabled.
+func (a *Agent) saveCredential() {
+	if a.credentialPath == "" || a.nodeID == "" || a.nodeSecret == "" {
+		return
+	}
+	err := SaveCredential(a.credentialPath, &Credential{
+		Coordinator: a.coordinatorURL,
+		NodeID:      a.nodeID,
+		NodeSecret:  a.nodeSecret,
+	})
+	if err != nil {
+		log.Printf("WARN: failed to save node credential: %v", err)
+	}
+}
+
+// errIdentityRejected is returned when the coordinator refuses a stored
+// node identity.
+var errIdentityRejected = errors.New("node identity rejected by coordinator")
+
+// Register sends a registration request to the coordinator. If the node
+// holds a stored identity it reclaims the same node ID; if the coordinator
+// rejects that identity, the node registers as a new node instead.
 func (a *Agent) Register() error {
+	err := a.register()
+	if errors.Is(err, errIdentityRejected) {
+		log.Printf("stored identity for node %s rejected, registering as a new node", a.nodeID)
+		a.nodeID = ""
+		a.nodeSecret = ""
+		return a.register()
+	}
+	return err
+}
+
+func (a *Agent) register() error {
 	req := types.RegisterRequest{
-		Name:         a.name,
-		Endpoint:     a.endpoint,
-		Capabilities: a.capabilities,
+		Name:           a.name,
+		Endpoint:       a.endpoint,
+		Capabilities:   a.capabilities,
+		Labels:         a.labels,
+		MaxConcurrency: a.maxConcurrency,
+		NodeID:         a.nodeID,
+		NodeSecret:     a.nodeSecret,
 	}
 
 	body, err := json.Marshal(req)
@@ -110,7 +180,10 @@ func (a *Agent) Register() error {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
+	if resp.StatusCode == http.StatusForbidden && req.NodeID != "" {
+		return errIdentityRejected
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
 		var errResp struct {
 			Error string `json:"error"`
 		}
@@ -127,8 +200,16 @@ func (a *Agent) Register() error {
 	if regResp.Token != "" {
 		a.token = regResp.Token
 	}
+	if regResp.NodeSecret != "" {
+		a.nodeSecret = regResp.NodeSecret
+	}
+	a.saveCredential()
 
-	log.Printf("registered as node %s", a.nodeID)
+	if regResp.Reattached {
+		log.Printf("reattached as node %s", a.nodeID)
+	} else {
+		log.Printf("registered as node %s", a.nodeID)
+	}
 	return nil
 }
 
@@ -153,10 +234,10 @@ func (a *Agent) StartHandler() error {
 	} else {
 		log.Printf("WARN: no gateway endpoint configured, messages will be echoed")
 	}
-	handler := NewHandler(&a.token, gw)
+	a.handler = NewHandler(&a.token, gw)
 	a.httpServer = &http.Server{
 		Addr:    a.listenAddr,
-		Handler: handler,
+		Handler: a.handler,
 	}
 	ln, err := net.Listen("tcp", a.listenAddr)
 	if err != nil {
@@ -215,7 +296,8 @@ func (a *Agent) heartbeatLoop() {
 }
 
 // reconnect attempts to re-register with the coordinator.
-// This is used when the coordinator restarts and loses node state.
+// This is used when the coordinator restarts or loses node state; the
+// stored identity lets the node keep its ID if the coordinator still has it.
 func (a *Agent) reconnect() error {
 	a.mu.Lock()
 	a.token = a.adminToken
@@ -223,9 +305,24 @@ func (a *Agent) reconnect() error {
 	return a.Register()
 }
 
+// currentLoad samples the node's load for a heartbeat.
+func (a *Agent) currentLoad() *types.NodeLoad {
+	load := &types.NodeLoad{CPUs: runtime.NumCPU()}
+	if a.handler != nil {
+		load.InFlight = a.handler.InFlight()
+		load.QueueDepth = a.handler.QueueDepth()
+	}
+	load.LoadAvg, load.FreeMemoryMB = systemLoad()
+	return load
+}
+
 func (a *Agent) sendHeartbeat() error {
+	// Load goes in Load rather than the status: a status change is
+	// persisted and announced, and in-flight counts change with every
+	// message.
 	req := types.HeartbeatRequest{
 		Status: types.NodeStatusOnline,
+		Load:   a.currentLoad(),
 	}
 
 	body, err := json.Marshal(req)
@@ -0,0 +1,51 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Credential is the node identity issued by a coordinator. It is stored
+// locally so the node reclaims the same ID when it registers again.
+type Credential struct {
+	Coordinator string `json:"coordinator"`
+	NodeID      string `json:"node_id"`
+	NodeSecret  string `json:"node_secret"`
+}
+
+// LoadCredential reads a stored credential from path.
+// Returns nil without error if the file doesn't exist.
+func LoadCredential(path string) (*Credential, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading credential: %w", err)
+	}
+	var c Credential
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing credential %s: %w", path, err)
+	}
+	if c.NodeID == "" || c.NodeSecret == "" {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+// SaveCredential writes a credential to path with owner-only permissions.
+func SaveCredential(path string, c *Credential) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("creating credential directory: %w", err)
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling credential: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("writing credential: %w", err)
+	}
+	return nil
+}
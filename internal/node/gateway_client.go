@@ -9,6 +9,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/SallyKAN/claw-mesh/internal/types"
@@ -29,6 +30,7 @@ type HTTPGatewayClient struct {
 	endpoint string
 	token    string
 	client   *http.Client
+	active   atomic.Int64
 }
 
 // NewHTTPGatewayClient creates a client for the OpenClaw Gateway HTTP API.
@@ -46,6 +48,9 @@ func NewHTTPGatewayClient(endpoint, token string, timeoutSec int) *HTTPGatewayCl
 // SendMessage converts a claw-mesh Message to an OpenAI ChatCompletion request,
 // sends it to the gateway, and maps the response back.
 func (c *HTTPGatewayClient) SendMessage(ctx context.Context, msg *types.Message) (*types.MessageResponse, error) {
+	c.active.Add(1)
+	defer c.active.Add(-1)
+
 	reqBody := types.ChatCompletionRequest{
 		Model: "default",
 		Messages: []types.ChatMessage{
@@ -102,6 +107,11 @@ func (c *HTTPGatewayClient) SendMessage(ctx context.Context, msg *types.Message)
 	}, nil
 }
 
+// QueueDepth returns the number of completion requests awaiting a reply.
+func (c *HTTPGatewayClient) QueueDepth() int {
+	return int(c.active.Load())
+}
+
 // HealthCheck verifies the gateway is reachable via TCP.
 func (c *HTTPGatewayClient) HealthCheck(_ context.Context) bool {
 	conn, err := net.DialTimeout("tcp", c.endpoint, 2*time.Second)
@@ -13,6 +13,16 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+const (
+	// agentAcceptTimeout bounds the wait for the gateway to accept an agent
+	// run. It applies even when the message is cancelled meanwhile, so that
+	// the run's ID is known and the run can be aborted.
+	agentAcceptTimeout = 15 * time.Second
+
+	// chatAbortMethod is the gateway RPC that stops an agent run.
+	chatAbortMethod = "chat.abort"
+)
+
 type wsError struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
@@ -307,14 +317,23 @@ func (c *WSGatewayClient) SendMessage(ctx context.Context, msg *types.Message) (
 	run := &agentRun{done: make(chan struct{})}
 	// We don't know the runId yet; register after we get the accepted response.
 
+	sessionKey := gatewaySessionKey(msg)
 	params := map[string]interface{}{
 		"message":        msg.Content,
 		"idempotencyKey": idemKey,
 		"agentId":        "main",
-		"sessionKey":     "agent:main:claw-mesh:dashboard:" + msg.Source,
+		"sessionKey":     sessionKey,
 	}
 
-	payload, err := c.call(ctx, "agent", params)
+	// A message cancelled before the run is requested must not start one.
+	// Once the request is sent, wait for the accept even if ctx ends, so
+	// the run can be aborted.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	acceptCtx, acceptCancel := context.WithTimeout(context.WithoutCancel(ctx), agentAcceptTimeout)
+	payload, err := c.call(acceptCtx, "agent", params)
+	acceptCancel()
 	if err != nil {
 		return nil, err
 	}
@@ -333,13 +352,15 @@ func (c *WSGatewayClient) SendMessage(ctx context.Context, msg *types.Message) (
 	c.runs[accepted.RunID] = run
 	c.mu.Unlock()
 
-	// Wait for the agent run to complete (lifecycle end event).
+	// Wait for the agent run to complete (lifecycle end event). If the
+	// message is cancelled or times out first, stop the run on the gateway.
 	select {
 	case <-run.done:
 	case <-ctx.Done():
 		c.mu.Lock()
 		delete(c.runs, accepted.RunID)
 		c.mu.Unlock()
+		c.abortRun(accepted.RunID, sessionKey)
 		return nil, ctx.Err()
 	}
 
@@ -362,6 +383,28 @@ func (c *WSGatewayClient) SendMessage(ctx context.Context, msg *types.Message) (
 	}, nil
 }
 
+// abortRun asks the gateway to stop an agent run.
+func (c *WSGatewayClient) abortRun(runID, sessionKey string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	params := map[string]interface{}{
+		"sessionKey": sessionKey,
+		"runId":      runID,
+	}
+	if _, err := c.call(ctx, chatAbortMethod, params); err != nil {
+		log.Printf("WARN: aborting gateway run %s: %v", runID, err)
+		return
+	}
+	log.Printf("aborted gateway run %s", runID)
+}
+
+// QueueDepth returns the number of agent runs and RPCs awaiting a reply.
+func (c *WSGatewayClient) QueueDepth() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.runs) + len(c.pending)
+}
+
 // HealthCheck verifies the gateway is reachable via TCP.
 func (c *WSGatewayClient) HealthCheck(_ context.Context) bool {
 	conn, err := net.DialTimeout("tcp", c.endpoint, 2*time.Second)
@@ -392,3 +435,12 @@ func (c *WSGatewayClient) ConnectAndLog() {
 		log.Printf("gateway ws connected to %s", c.endpoint)
 	}
 }
+
+// gatewaySessionKey maps a message to a Gateway session. Messages that
+// carry a session ID share that session; others share one per source.
+func gatewaySessionKey(msg *types.Message) string {
+	if msg.SessionID != "" {
+		return "agent:main:claw-mesh:session:" + msg.SessionID
+	}
+	return "agent:main:claw-mesh:dashboard:" + msg.Source
+}
@@ -0,0 +1,122 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SallyKAN/claw-mesh/internal/types"
+	"github.com/gorilla/websocket"
+)
+
+func TestWSGatewayClient_SendMessage_Cancel(t *testing.T) {
+	accepted := make(chan struct{})
+	aborted := make(chan map[string]string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+		conn.WriteJSON(map[string]interface{}{"type": "event", "event": "connect.challenge", "payload": map[string]string{"nonce": "n"}})
+		for {
+			var req struct {
+				ID     string          `json:"id"`
+				Method string          `json:"method"`
+				Params json.RawMessage `json:"params"`
+			}
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			var payload interface{}
+			switch req.Method {
+			case "connect":
+				payload = map[string]string{"type": "hello-ok"}
+			case "agent":
+				payload = map[string]string{"runId": "run-1", "status": "accepted"}
+				close(accepted)
+			case chatAbortMethod:
+				var params map[string]string
+				json.Unmarshal(req.Params, &params)
+				aborted <- params
+			}
+			conn.WriteJSON(map[string]interface{}{"type": "res", "id": req.ID, "ok": true, "payload": payload})
+		}
+	}))
+	defer srv.Close()
+
+	client := NewWSGatewayClient(strings.TrimPrefix(srv.URL, "http://"), "", 30)
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-accepted
+		cancel()
+	}()
+	_, err := client.SendMessage(ctx, &types.Message{ID: "m1", Content: "hello", Source: "test"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	select {
+	case params := <-aborted:
+		if params["runId"] != "run-1" || params["sessionKey"] != "agent:main:claw-mesh:dashboard:test" {
+			t.Errorf("unexpected abort params: %v", params)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the run to be aborted on the gateway")
+	}
+	if depth := client.QueueDepth(); depth != 0 {
+		t.Errorf("expected no runs left, got %d", depth)
+	}
+}
+
+func TestWSGatewayClient_SendMessage_CancelledBeforeSend(t *testing.T) {
+	methods := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+		conn.WriteJSON(map[string]interface{}{"type": "event", "event": "connect.challenge", "payload": map[string]string{"nonce": "n"}})
+		for {
+			var req struct {
+				ID     string `json:"id"`
+				Method string `json:"method"`
+			}
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			methods <- req.Method
+			conn.WriteJSON(map[string]interface{}{"type": "res", "id": req.ID, "ok": true, "payload": map[string]string{"type": "hello-ok"}})
+		}
+	}))
+	defer srv.Close()
+
+	client := NewWSGatewayClient(strings.TrimPrefix(srv.URL, "http://"), "", 30)
+	defer client.Close()
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.SendMessage(ctx, &types.Message{ID: "m1", Content: "hello", Source: "test"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	for len(methods) > 0 {
+		if m := <-methods; m == "agent" {
+			t.Fatal("a cancelled message should not start an agent run")
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package node
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"sync"
+	"sync/atomic"
 
 	"github.com/SallyKAN/claw-mesh/internal/types"
 )
@@ -16,6 +19,10 @@ type Handler struct {
 	token         *string
 	gatewayClient GatewayClient
 	mux           *http.ServeMux
+	inFlight      atomic.Int64
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc // message ID -> cancel
 }
 
 // NewHandler creates a node message handler.
@@ -26,8 +33,10 @@ func NewHandler(token *string, gw GatewayClient) *Handler {
 		token:         token,
 		gatewayClient: gw,
 		mux:           http.NewServeMux(),
+		running:       make(map[string]context.CancelFunc),
 	}
 	h.mux.HandleFunc("POST /api/v1/messages", h.requireAuth(h.handleMessage))
+	h.mux.HandleFunc("DELETE /api/v1/messages/{id}", h.requireAuth(h.handleCancel))
 	h.mux.HandleFunc("GET /healthz", h.handleHealthz)
 	return h
 }
@@ -78,6 +87,8 @@ func (h *Handler) handleMessage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("received message %s: %s", msg.ID, msg.Content)
+	h.inFlight.Add(1)
+	defer h.inFlight.Add(-1)
 
 	if h.gatewayClient == nil {
 		// Echo fallback — no gateway configured.
@@ -91,14 +102,39 @@ func (h *Handler) handleMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The run stops when the coordinator cancels the message, either by
+	// closing the request or through handleCancel. A message ID is only
+	// handled once at a time, so each run can be cancelled.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	h.mu.Lock()
+	if _, dup := h.running[msg.ID]; dup {
+		h.mu.Unlock()
+		writeNodeJSON(w, http.StatusConflict, map[string]string{"error": "message already in progress"})
+		return
+	}
+	h.running[msg.ID] = cancel
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.running, msg.ID)
+		h.mu.Unlock()
+	}()
+
 	// Forward to OpenClaw Gateway; fall back to echo on failure.
-	gwResp, err := h.gatewayClient.SendMessage(r.Context(), &msg)
+	gwResp, err := h.gatewayClient.SendMessage(ctx, &msg)
+	if err != nil && ctx.Err() != nil {
+		log.Printf("message %s cancelled: %v", msg.ID, err)
+		writeNodeJSON(w, http.StatusConflict, map[string]string{"error": "message cancelled"})
+		return
+	}
 	if err != nil {
 		log.Printf("gateway forwarding failed for message %s, falling back to echo: %v", msg.ID, err)
 		resp := types.MessageResponse{
-			MessageID: msg.ID,
-			NodeID:    "",
-			Response:  "[claw-mesh] Gateway error (echo fallback). Message: " + msg.Content,
+			MessageID:    msg.ID,
+			NodeID:       "",
+			Response:     "[claw-mesh] Gateway error (echo fallback). Message: " + msg.Content,
+			GatewayError: true,
 		}
 		writeNodeJSON(w, http.StatusOK, resp)
 		return
@@ -107,6 +143,34 @@ func (h *Handler) handleMessage(w http.ResponseWriter, r *http.Request) {
 	writeNodeJSON(w, http.StatusOK, gwResp)
 }
 
+// handleCancel stops the gateway run for a message being handled.
+func (h *Handler) handleCancel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	h.mu.Lock()
+	cancel, ok := h.running[id]
+	h.mu.Unlock()
+	if !ok {
+		writeNodeJSON(w, http.StatusNotFound, map[string]string{"error": "message not in flight"})
+		return
+	}
+	cancel()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// InFlight returns the number of messages currently being handled.
+func (h *Handler) InFlight() int {
+	return int(h.inFlight.Load())
+}
+
+// QueueDepth returns how many requests the gateway is working on, if the
+// gateway client can tell; otherwise 0.
+func (h *Handler) QueueDepth() int {
+	if q, ok := h.gatewayClient.(interface{ QueueDepth() int }); ok {
+		return q.QueueDepth()
+	}
+	return 0
+}
+
 // handleHealthz responds to active health probes from the coordinator.
 func (h *Handler) handleHealthz(w http.ResponseWriter, r *http.Request) {
 	writeNodeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
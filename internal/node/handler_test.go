@@ -30,7 +30,7 @@ func (m *mockGatewayClient) SendMessage(_ context.Context, msg *types.Message) (
 }
 
 func (m *mockGatewayClient) HealthCheck(_ context.Context) bool { return m.healthy }
-func (m *mockGatewayClient) Close() error                      { return nil }
+func (m *mockGatewayClient) Close() error                       { return nil }
 
 func postMessage(handler http.Handler, msg types.Message) *httptest.ResponseRecorder {
 	body, _ := json.Marshal(msg)
@@ -112,6 +112,9 @@ func TestHandler_GatewayError(t *testing.T) {
 	if !strings.Contains(resp.Response, "Gateway error (echo fallback)") {
 		t.Errorf("expected fallback response, got: %s", resp.Response)
 	}
+	if !resp.GatewayError {
+		t.Error("expected gateway_error to be set so the coordinator can fail over")
+	}
 }
 
 func TestHandler_InvalidBody(t *testing.T) {
@@ -140,3 +143,46 @@ func TestHandler_MissingFields(t *testing.T) {
 		t.Fatalf("expected 400 for missing content, got %d", rr.Code)
 	}
 }
+
+// blockingGatewayClient blocks each message until it is cancelled.
+type blockingGatewayClient struct {
+	started chan struct{}
+}
+
+func (b *blockingGatewayClient) SendMessage(ctx context.Context, _ *types.Message) (*types.MessageResponse, error) {
+	close(b.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (b *blockingGatewayClient) HealthCheck(_ context.Context) bool { return true }
+func (b *blockingGatewayClient) Close() error                       { return nil }
+
+func TestHandler_Cancel(t *testing.T) {
+	gw := &blockingGatewayClient{started: make(chan struct{})}
+	h := NewHandler(nil, gw)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- postMessage(h, types.Message{ID: "msg-1", Content: "hello"})
+	}()
+	<-gw.started
+
+	cancel := func() int {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/v1/messages/msg-1", nil))
+		return rr.Code
+	}
+	if rr := postMessage(h, types.Message{ID: "msg-1", Content: "hello"}); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a message already in progress, got %d", rr.Code)
+	}
+	if code := cancel(); code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", code)
+	}
+	if rr := <-done; rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a cancelled message, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if code := cancel(); code != http.StatusNotFound {
+		t.Fatalf("expected 404 once the message is done, got %d", code)
+	}
+}
@@ -0,0 +1,84 @@
+package node
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// systemLoad returns the 1-minute load average and free memory in MB.
+// Either value is 0 if it can't be determined on this platform.
+func systemLoad() (loadAvg float64, freeMemoryMB int) {
+	switch runtime.GOOS {
+	case "linux":
+		if data, err := os.ReadFile("/proc/loadavg"); err == nil {
+			if fields := strings.Fields(string(data)); len(fields) > 0 {
+				loadAvg, _ = strconv.ParseFloat(fields[0], 64)
+			}
+		}
+		if data, err := os.ReadFile("/proc/meminfo"); err == nil {
+			freeMemoryMB = parseMeminfoAvailable(string(data))
+		}
+	case "darwin":
+		// vm.loadavg looks like "{ 1.52 1.61 1.70 }".
+		if out, err := exec.Command("sysctl", "-n", "vm.loadavg").Output(); err == nil {
+			fields := strings.Fields(strings.Trim(strings.TrimSpace(string(out)), "{}"))
+			if len(fields) > 0 {
+				loadAvg, _ = strconv.ParseFloat(fields[0], 64)
+			}
+		}
+		if out, err := exec.Command("vm_stat").Output(); err == nil {
+			freeMemoryMB = parseVMStatFree(string(out))
+		}
+	}
+	return loadAvg, freeMemoryMB
+}
+
+// parseMeminfoAvailable extracts MemAvailable from /proc/meminfo in MB.
+func parseMeminfoAvailable(meminfo string) int {
+	for _, line := range strings.Split(meminfo, "\n") {
+		if strings.HasPrefix(line, "MemAvailable:") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 {
+				if kb, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+					return int(kb / 1024)
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// parseVMStatFree sums free and inactive pages from vm_stat output in MB.
+// The first line carries the page size: "(page size of 16384 bytes)".
+func parseVMStatFree(out string) int {
+	lines := strings.Split(out, "\n")
+	if len(lines) == 0 {
+		return 0
+	}
+	pageSize := int64(4096)
+	if i := strings.Index(lines[0], "page size of "); i >= 0 {
+		fields := strings.Fields(lines[0][i+len("page size of "):])
+		if len(fields) > 0 {
+			if v, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+				pageSize = v
+			}
+		}
+	}
+	var pages int64
+	for _, line := range lines[1:] {
+		if !strings.HasPrefix(line, "Pages free:") && !strings.HasPrefix(line, "Pages inactive:") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if v, err := strconv.ParseInt(strings.TrimSuffix(fields[len(fields)-1], "."), 10, 64); err == nil {
+			pages += v
+		}
+	}
+	return int(pages * pageSize / (1 << 20))
+}
@@ -0,0 +1,72 @@
+package node
+
+import "testing"
+
+func TestParseMeminfoAvailable(t *testing.T) {
+	tests := []struct {
+		name    string
+		meminfo string
+		want    int
+	}{
+		{
+			name: "linux",
+			meminfo: "MemTotal:       16303340 kB\n" +
+				"MemFree:         1204816 kB\n" +
+				"MemAvailable:    9437184 kB\n" +
+				"Buffers:          412300 kB\n",
+			want: 9216,
+		},
+		{
+			name:    "no MemAvailable",
+			meminfo: "MemTotal:       16303340 kB\nMemFree:         1204816 kB\n",
+			want:    0,
+		},
+		{
+			name:    "malformed",
+			meminfo: "MemAvailable: lots kB\n",
+			want:    0,
+		},
+		{name: "empty", meminfo: "", want: 0},
+	}
+	for _, tt := range tests {
+		if got := parseMeminfoAvailable(tt.meminfo); got != tt.want {
+			t.Errorf("%s: got %d MB, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseVMStatFree(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want int
+	}{
+		{
+			name: "apple silicon",
+			out: "Mach Virtual Memory Statistics: (page size of 16384 bytes)\n" +
+				"Pages free:                               12800.\n" +
+				"Pages active:                            301234.\n" +
+				"Pages inactive:                           51200.\n" +
+				"Pages speculative:                         1024.\n",
+			want: 1000, // (12800 + 51200) * 16 KB
+		},
+		{
+			name: "intel",
+			out: "Mach Virtual Memory Statistics: (page size of 4096 bytes)\n" +
+				"Pages free:                               25600.\n" +
+				"Pages inactive:                           51200.\n",
+			want: 300,
+		},
+		{
+			name: "no page size header",
+			out:  "Pages free:                               25600.\n",
+			want: 0, // the first line is the header, so it isn't counted
+		},
+		{name: "empty", out: "", want: 0},
+	}
+	for _, tt := range tests {
+		if got := parseVMStatFree(tt.out); got != tt.want {
+			t.Errorf("%s: got %d MB, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -11,6 +11,19 @@ const (
 	NodeStatusBusy    NodeStatus = "busy"
 )
 
+// MaintenanceState is an operator-set mode that takes a node out of
+// auto-routing independently of its reported status. Empty means in rotation.
+type MaintenanceState string
+
+const (
+	// MaintenanceCordoned nodes get no new auto-routed messages.
+	MaintenanceCordoned MaintenanceState = "cordoned"
+	// MaintenanceDraining nodes are cordoned and still finishing in-flight forwards.
+	MaintenanceDraining MaintenanceState = "draining"
+	// MaintenanceDrained nodes are cordoned with no forwards in flight.
+	MaintenanceDrained MaintenanceState = "drained"
+)
+
 // Capabilities describes what a node can do.
 type Capabilities struct {
 	OS       string   `json:"os" yaml:"os"`
@@ -22,63 +35,565 @@ type Capabilities struct {
 }
 
 // Node represents a single machine running an OpenClaw Gateway.
+// InFlight counts messages the coordinator is currently forwarding to it.
 type Node struct {
-	ID            string       `json:"id" yaml:"id"`
-	Name          string       `json:"name" yaml:"name"`
-	Endpoint      string       `json:"endpoint" yaml:"endpoint"`
-	Capabilities  Capabilities `json:"capabilities" yaml:"capabilities"`
-	Status        NodeStatus   `json:"status" yaml:"status"`
-	LastHeartbeat time.Time    `json:"last_heartbeat" yaml:"last_heartbeat"`
+	ID            string            `json:"id" yaml:"id"`
+	Name          string            `json:"name" yaml:"name"`
+	Endpoint      string            `json:"endpoint" yaml:"endpoint"`
+	Capabilities  Capabilities      `json:"capabilities" yaml:"capabilities"`
+	Status        NodeStatus        `json:"status" yaml:"status"`
+	LastHeartbeat time.Time         `json:"last_heartbeat" yaml:"last_heartbeat"`
+	Maintenance   MaintenanceState  `json:"maintenance,omitempty" yaml:"maintenance,omitempty"`
+	InFlight      int               `json:"in_flight" yaml:"in_flight"`
+	Labels        map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Load          *NodeLoad         `json:"load,omitempty" yaml:"load,omitempty"`
+	Circuit       *CircuitState     `json:"circuit,omitempty" yaml:"-"`
+	// MaxConcurrency is how many forwards the node accepts at once, as
+	// advertised at registration; 0 means unlimited. ConcurrencyOverride,
+	// set by an admin, takes precedence.
+	MaxConcurrency      int  `json:"max_concurrency,omitempty" yaml:"max_concurrency,omitempty"`
+	ConcurrencyOverride *int `json:"concurrency_override,omitempty" yaml:"concurrency_override,omitempty"`
+}
+
+// ConcurrencyLimit returns the node's effective concurrency limit; 0 means
+// unlimited.
+func (n *Node) ConcurrencyLimit() int {
+	if n.ConcurrencyOverride != nil {
+		return *n.ConcurrencyOverride
+	}
+	return n.MaxConcurrency
+}
+
+// CircuitState describes the coordinator's circuit breaker for a node.
+// Requests and Failures cover the breaker's recent window of forwards.
+type CircuitState struct {
+	State       string     `json:"state"` // closed, open or half-open
+	Requests    int        `json:"requests"`
+	Failures    int        `json:"failures"`
+	FailureRate float64    `json:"failure_rate"`
+	OpenedAt    *time.Time `json:"opened_at,omitempty"`
+}
+
+// NodeLoad is the load a node reports with each heartbeat. LoadAvg is the
+// 1-minute load average; zero values mean "unknown".
+type NodeLoad struct {
+	InFlight     int     `json:"in_flight" yaml:"in_flight"`
+	QueueDepth   int     `json:"queue_depth" yaml:"queue_depth"`
+	LoadAvg      float64 `json:"load_avg" yaml:"load_avg"`
+	CPUs         int     `json:"cpus" yaml:"cpus"`
+	FreeMemoryMB int     `json:"free_memory_mb" yaml:"free_memory_mb"`
 }
 
 // MatchCriteria defines what a routing rule matches against.
+// LabelSelector is a Kubernetes-style selector over node labels,
+// e.g. "zone=home,tier in (fast,gpu),!spot".
 type MatchCriteria struct {
 	RequiresGPU   *bool  `json:"requires_gpu,omitempty" yaml:"requires_gpu,omitempty"`
 	RequiresOS    string `json:"requires_os,omitempty" yaml:"requires_os,omitempty"`
 	RequiresSkill string `json:"requires_skill,omitempty" yaml:"requires_skill,omitempty"`
 	Wildcard      *bool  `json:"wildcard,omitempty" yaml:"wildcard,omitempty"`
+	LabelSelector string `json:"label_selector,omitempty" yaml:"label_selector,omitempty"`
+
+	// Message predicates. A rule only applies to messages that satisfy
+	// all of the predicates that are set.
+	Keywords     []string `json:"keywords,omitempty" yaml:"keywords,omitempty"`           // any keyword, case-insensitive
+	ContentRegex string   `json:"content_regex,omitempty" yaml:"content_regex,omitempty"` // RE2 syntax
+	Command      string   `json:"command,omitempty" yaml:"command,omitempty"`             // leading word, e.g. "/gpu"
+	Source       string   `json:"source,omitempty" yaml:"source,omitempty"`
+	MinLength    int      `json:"min_length,omitempty" yaml:"min_length,omitempty"` // in characters
+	MaxLength    int      `json:"max_length,omitempty" yaml:"max_length,omitempty"`
 }
 
 // RoutingRule defines how messages are routed to nodes.
+// A rule targets either a single node (Target) or a node group
+// (TargetGroup); with a group, Strategy picks among its healthy members.
 type RoutingRule struct {
-	ID       string        `json:"id" yaml:"id"`
-	Match    MatchCriteria `json:"match" yaml:"match"`
-	Target   string        `json:"target,omitempty" yaml:"target,omitempty"`
-	Strategy string        `json:"strategy,omitempty" yaml:"strategy,omitempty"`
+	ID          string        `json:"id" yaml:"id,omitempty"`
+	Match       MatchCriteria `json:"match" yaml:"match"`
+	Target      string        `json:"target,omitempty" yaml:"target,omitempty"`
+	TargetGroup string        `json:"target_group,omitempty" yaml:"target_group,omitempty"`
+	Strategy    string        `json:"strategy,omitempty" yaml:"strategy,omitempty"`
+	// Expr is an optional boolean expression over node fields, e.g.
+	// "(skill:docker OR skill:kubernetes) AND NOT os:darwin". It is ANDed
+	// with Match.
+	Expr string `json:"expr,omitempty" yaml:"expr,omitempty"`
+	// Affinity pins messages with the same "source" or "session" ID to
+	// the same node. Empty disables affinity.
+	Affinity string `json:"affinity,omitempty" yaml:"affinity,omitempty"`
+	// MaxNodes caps how many candidates are tried when forwarding fails
+	// with a transient or Gateway error. 0 means the default; 1 disables
+	// failover.
+	MaxNodes int `json:"max_nodes,omitempty" yaml:"max_nodes,omitempty"`
+	// Disabled rules are kept in place but skipped when routing.
+	Disabled bool `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+}
+
+// RulesFileStatus reports the state of a coordinator whose routing rules
+// are declared in a file.
+type RulesFileStatus struct {
+	Path      string     `json:"path"`
+	Rules     int        `json:"rules"`
+	LoadedAt  *time.Time `json:"loaded_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"` // why the latest version was rejected
+	FailedAt  *time.Time `json:"failed_at,omitempty"`
+}
+
+// AffinityEntry records which node a source or session is pinned to.
+type AffinityEntry struct {
+	RuleID        string    `json:"rule_id"`
+	Mode          string    `json:"mode"`
+	Key           string    `json:"key"`
+	NodeID        string    `json:"node_id"`
+	CreatedAt     time.Time `json:"created_at"`
+	LastUsed      time.Time `json:"last_used"`
+	Hits          int64     `json:"hits"`
+	Reassignments int       `json:"reassignments"`
+}
+
+// RouteTrace explains a routing decision without forwarding anything.
+// Rules lists the rules in evaluation order up to the one that chose the
+// node; Fallback is set when none did and least-busy was used.
+type RouteTrace struct {
+	NodeID      string          `json:"node_id,omitempty"`
+	NodeName    string          `json:"node_name,omitempty"`
+	RuleID      string          `json:"rule_id,omitempty"`
+	ChosenBy    string          `json:"chosen_by,omitempty"` // e.g. "strategy:round-robin", "affinity:session", "target"
+	Fallback    bool            `json:"fallback"`
+	Error       string          `json:"error,omitempty"`
+	Unavailable []NodeExclusion `json:"unavailable,omitempty"` // nodes not eligible for auto-routing
+	Rules       []RuleTrace     `json:"rules"`
+}
+
+// RuleTrace records how one rule was evaluated.
+type RuleTrace struct {
+	RuleID     string          `json:"rule_id"`
+	Matched    bool            `json:"matched"`
+	Reason     string          `json:"reason,omitempty"` // why the rule was skipped
+	Candidates []string        `json:"candidates,omitempty"`
+	Excluded   []NodeExclusion `json:"excluded,omitempty"`
+}
+
+// NodeExclusion says why a node was not a candidate.
+type NodeExclusion struct {
+	NodeID string `json:"node_id"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Group is a named set of nodes, defined either by a static member list
+// (node names or IDs) or by a label selector. Nodes is filled in by the
+// coordinator with the IDs of the current members and is not stored.
+type Group struct {
+	Name      string    `json:"name" yaml:"name"`
+	Members   []string  `json:"members,omitempty" yaml:"members,omitempty"`
+	Selector  string    `json:"selector,omitempty" yaml:"selector,omitempty"`
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+	Nodes     []string  `json:"nodes,omitempty" yaml:"-"`
+}
+
+// StrategyInfo describes a node selection strategy available to rules.
+type StrategyInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     bool   `json:"default,omitempty"`
 }
 
 // Message represents a message flowing through the mesh.
 type Message struct {
-	ID        string    `json:"id"`
-	Content   string    `json:"content"`
-	Source    string    `json:"source"`
-	TargetNode string   `json:"target_node,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         string    `json:"id"`
+	Content    string    `json:"content"`
+	Source     string    `json:"source"`
+	SessionID  string    `json:"session_id,omitempty"`
+	TargetNode string    `json:"target_node,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	// TargetRule routes the message with this rule only, whatever its
+	// message predicates. It stays within the coordinator.
+	TargetRule string `json:"-"`
 }
 
 // MessageResponse is the response returned after routing a message.
+// GatewayError is set by a node that couldn't reach its Gateway and
+// answered with an echo instead; the coordinator then tries another node.
+// Attempts lists the nodes tried, in order, for auto-routed messages.
 type MessageResponse struct {
-	MessageID string `json:"message_id"`
-	NodeID    string `json:"node_id"`
-	Response  string `json:"response"`
+	MessageID    string           `json:"message_id"`
+	NodeID       string           `json:"node_id"`
+	Response     string           `json:"response"`
+	GatewayError bool             `json:"gateway_error,omitempty"`
+	Attempts     []ForwardAttempt `json:"attempts,omitempty"`
+}
+
+// ForwardAttempt records one node tried while delivering a message.
+type ForwardAttempt struct {
+	NodeID       string `json:"node_id"`
+	NodeName     string `json:"node_name"`
+	Error        string `json:"error,omitempty"`
+	GatewayError bool   `json:"gateway_error,omitempty"`
+	DurationMs   int64  `json:"duration_ms"`
+}
+
+// JobStatus is the state of an asynchronously submitted message.
+type JobStatus string
+
+const (
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// Job tracks a message submitted with ?async=true or ?plan=true. Result is
+// set once the job succeeded, Error once it failed. PlanID is set instead
+// of Result if the planner turned the message into a task plan.
+type Job struct {
+	ID         string           `json:"id"`
+	Status     JobStatus        `json:"status"`
+	MessageID  string           `json:"message_id"`
+	TargetNode string           `json:"target_node,omitempty"`
+	Result     *MessageResponse `json:"result,omitempty"`
+	PlanID     string           `json:"plan_id,omitempty"`
+	Error      string           `json:"error,omitempty"`
+	Attempts   []ForwardAttempt `json:"attempts,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
+
+// TaskStatus is the state of a task in the coordinator's task queue.
+type TaskStatus string
+
+const (
+	TaskPending   TaskStatus = "pending" // waiting for its next attempt
+	TaskRunning   TaskStatus = "running"
+	TaskSucceeded TaskStatus = "succeeded"
+	TaskDead      TaskStatus = "dead" // out of attempts or past its deadline
+)
+
+// BackoffPolicy sets the delay before each retry of a task: Initial
+// seconds, multiplied by Multiplier after every failed attempt, up to Max.
+type BackoffPolicy struct {
+	Initial    float64 `json:"initial"`
+	Multiplier float64 `json:"multiplier"`
+	Max        float64 `json:"max"`
+}
+
+// TaskRequest submits a message to the task queue. TargetNode pins the
+// task to a node; otherwise every attempt is routed afresh. Zero values
+// take the queue's defaults.
+type TaskRequest struct {
+	Content        string         `json:"content"`
+	Source         string         `json:"source"`
+	SessionID      string         `json:"session_id,omitempty"`
+	TargetNode     string         `json:"target_node,omitempty"`
+	MaxAttempts    int            `json:"max_attempts,omitempty"`
+	TimeoutSeconds int            `json:"timeout_seconds,omitempty"` // deadline, from submission
+	Backoff        *BackoffPolicy `json:"backoff,omitempty"`
+}
+
+// Task is a message in the coordinator's durable task queue. Failed
+// attempts are retried with backoff until MaxAttempts or the Deadline is
+// reached, at which point the task is moved to the dead-letter queue.
+type Task struct {
+	ID             string           `json:"id"`
+	Status         TaskStatus       `json:"status"`
+	Content        string           `json:"content"`
+	Source         string           `json:"source,omitempty"`
+	SessionID      string           `json:"session_id,omitempty"`
+	TargetNode     string           `json:"target_node,omitempty"`
+	MaxAttempts    int              `json:"max_attempts"`
+	TimeoutSeconds int              `json:"timeout_seconds"`
+	Backoff        BackoffPolicy    `json:"backoff"`
+	Deadline       time.Time        `json:"deadline"`
+	Attempts       int              `json:"attempts"`
+	NextAttemptAt  time.Time        `json:"next_attempt_at"`
+	LastError      string           `json:"last_error,omitempty"`
+	Result         *MessageResponse `json:"result,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+	FinishedAt     *time.Time       `json:"finished_at,omitempty"`
+}
+
+// OverlapPolicy says what a schedule does when it fires while a previous
+// run is still in progress.
+type OverlapPolicy string
+
+const (
+	OverlapSkip    OverlapPolicy = "skip" // default: record a skipped run
+	OverlapAllow   OverlapPolicy = "allow"
+	OverlapReplace OverlapPolicy = "replace" // cancel the running run
+)
+
+// ScheduleSpec is the user-supplied part of a schedule. Exactly one of
+// Cron (five fields or a macro such as @daily, in Timezone) and
+// IntervalSeconds is set. Content is a text/template; see the README for
+// the fields it can use. The message goes to TargetNode, or is routed by
+// the rule TargetRule, or is auto-routed if neither is set.
+type ScheduleSpec struct {
+	Name            string        `json:"name"`
+	Cron            string        `json:"cron,omitempty"`
+	IntervalSeconds int           `json:"interval_seconds,omitempty"`
+	Timezone        string        `json:"timezone,omitempty"`
+	Content         string        `json:"content"`
+	Source          string        `json:"source,omitempty"`
+	SessionID       string        `json:"session_id,omitempty"`
+	TargetNode      string        `json:"target_node,omitempty"`
+	TargetRule      string        `json:"target_rule,omitempty"`
+	Overlap         OverlapPolicy `json:"overlap,omitempty"`
+	KeepRuns        int           `json:"keep_runs,omitempty"` // default 10
+	Disabled        bool          `json:"disabled,omitempty"`
+}
+
+// Schedule is a recurring message owned by the coordinator. Runs holds
+// the most recent runs, newest first.
+type Schedule struct {
+	ID string `json:"id"`
+	ScheduleSpec
+	RunCount  int           `json:"run_count"`
+	NextRunAt *time.Time    `json:"next_run_at,omitempty"`
+	Runs      []ScheduleRun `json:"runs"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// ScheduleRunStatus is the outcome of one run of a schedule.
+type ScheduleRunStatus string
+
+const (
+	RunRunning   ScheduleRunStatus = "running"
+	RunSucceeded ScheduleRunStatus = "succeeded"
+	RunFailed    ScheduleRunStatus = "failed"
+	RunSkipped   ScheduleRunStatus = "skipped"   // a previous run was still going
+	RunCancelled ScheduleRunStatus = "cancelled" // replaced, deleted or shut down
+)
+
+// ScheduleRun records one firing of a schedule.
+type ScheduleRun struct {
+	MessageID  string            `json:"message_id,omitempty"`
+	Status     ScheduleRunStatus `json:"status"`
+	Manual     bool              `json:"manual,omitempty"`
+	NodeID     string            `json:"node_id,omitempty"`
+	Response   string            `json:"response,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// PlanStatus is the state of a task plan.
+type PlanStatus string
+
+const (
+	PlanPending   PlanStatus = "pending"
+	PlanRunning   PlanStatus = "running"
+	PlanCompleted PlanStatus = "completed"
+	PlanFailed    PlanStatus = "failed"
+)
+
+// StepStatus is the state of one step of a task plan.
+type StepStatus string
+
+const (
+	StepPending   StepStatus = "pending"
+	StepRunning   StepStatus = "running"
+	StepCompleted StepStatus = "completed"
+	StepFailed    StepStatus = "failed"
+	StepSkipped   StepStatus = "skipped" // a step it depends on did not complete
+)
+
+// PlanStepSpec is the user-supplied part of a plan step. The step goes to
+// NodeID, or to a node matching Skill, Match and Expr, or is auto-routed
+// if none is set. Prompt is a text/template that can use the outputs of
+// the steps it depends on, e.g. {{.Steps.build.Response}}.
+type PlanStepSpec struct {
+	ID         string         `json:"id,omitempty"`
+	Skill      string         `json:"skill,omitempty"`
+	Match      *MatchCriteria `json:"match,omitempty"`
+	Expr       string         `json:"expr,omitempty"`
+	NodeID     string         `json:"node_id,omitempty"`
+	Prompt     string         `json:"prompt"`
+	DependsOn  []string       `json:"depends_on,omitempty"`
+	TimeoutSec int            `json:"timeout_sec,omitempty"` // default 120
+}
+
+// PlanStep is a step of a task plan and its progress.
+type PlanStep struct {
+	PlanStepSpec
+	Status     StepStatus `json:"status"`
+	Response   string     `json:"response,omitempty"`
+	NodeUsed   string     `json:"node_used,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// PlanRequest submits a task plan: a DAG of steps linked by depends_on.
+type PlanRequest struct {
+	UserPrompt string         `json:"user_prompt,omitempty"`
+	Steps      []PlanStepSpec `json:"steps"`
+	TimeoutSec int            `json:"timeout_sec,omitempty"` // default 600
+}
+
+// TaskPlan is a multi-step task executed across nodes. Steps run as soon
+// as the steps they depend on have completed; FinalResponse collects the
+// responses of the steps nothing depends on.
+type TaskPlan struct {
+	ID            string     `json:"id"`
+	UserPrompt    string     `json:"user_prompt,omitempty"`
+	Steps         []PlanStep `json:"steps"`
+	Status        PlanStatus `json:"status"`
+	TimeoutSec    int        `json:"timeout_sec"`
+	CreatedAt     time.Time  `json:"created_at"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+	FinalResponse string     `json:"final_response,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// BroadcastRequest fans a message out to every eligible node that matches
+// the node criteria, expression and group (all nodes if none are set).
+// Mode is "all" (default), "first-success" or "quorum".
+type BroadcastRequest struct {
+	Content        string        `json:"content"`
+	Source         string        `json:"source"`
+	Match          MatchCriteria `json:"match"`
+	Expr           string        `json:"expr,omitempty"`
+	Group          string        `json:"group,omitempty"`
+	Mode           string        `json:"mode,omitempty"`
+	Quorum         int           `json:"quorum,omitempty"` // default: a majority
+	TimeoutSeconds int           `json:"timeout_seconds,omitempty"`
+}
+
+// BroadcastResponse collects the per-node results of a broadcast. OK is
+// true if the mode was satisfied: every node answered (all), one did
+// (first-success) or at least Quorum did (quorum).
+type BroadcastResponse struct {
+	MessageID string            `json:"message_id"`
+	Mode      string            `json:"mode"`
+	Quorum    int               `json:"quorum,omitempty"`
+	OK        bool              `json:"ok"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []BroadcastResult `json:"results"`
+}
+
+// BroadcastResult is one node's answer to a broadcast. Nodes still
+// working when the mode was satisfied are reported as cancelled.
+type BroadcastResult struct {
+	NodeID       string `json:"node_id"`
+	NodeName     string `json:"node_name"`
+	Response     string `json:"response,omitempty"`
+	Error        string `json:"error,omitempty"`
+	GatewayError bool   `json:"gateway_error,omitempty"`
+	Cancelled    bool   `json:"cancelled,omitempty"`
+	DurationMs   int64  `json:"duration_ms"`
 }
 
 // RegisterRequest is sent by a node agent to register with the coordinator.
+// A node that registered before sends back its NodeID and NodeSecret to
+// reclaim the same identity.
 type RegisterRequest struct {
-	Name         string       `json:"name"`
-	Endpoint     string       `json:"endpoint"`
-	Capabilities Capabilities `json:"capabilities"`
+	Name         string            `json:"name"`
+	Endpoint     string            `json:"endpoint"`
+	Capabilities Capabilities      `json:"capabilities"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	// MaxConcurrency caps concurrent forwards to the node; 0 is unlimited.
+	MaxConcurrency int    `json:"max_concurrency,omitempty"`
+	NodeID         string `json:"node_id,omitempty"`
+	NodeSecret     string `json:"node_secret,omitempty"`
 }
 
 // RegisterResponse is returned after successful registration.
+// NodeSecret is only set when a new identity is issued.
 type RegisterResponse struct {
-	NodeID string `json:"node_id"`
-	Token  string `json:"token,omitempty"`
+	NodeID     string `json:"node_id"`
+	Token      string `json:"token,omitempty"`
+	NodeSecret string `json:"node_secret,omitempty"`
+	Reattached bool   `json:"reattached,omitempty"`
 }
 
 // HeartbeatRequest is sent periodically by node agents.
 type HeartbeatRequest struct {
 	Status NodeStatus `json:"status"`
+	Load   *NodeLoad  `json:"load,omitempty"`
+}
+
+// EventType identifies a kind of mesh event.
+type EventType string
+
+const (
+	EventNodeRegistered    EventType = "node.registered"
+	EventNodeReattached    EventType = "node.reattached"
+	EventNodeDeregistered  EventType = "node.deregistered"
+	EventNodeOnline        EventType = "node.online"
+	EventNodeOffline       EventType = "node.offline"
+	EventNodeStatus        EventType = "node.status"
+	EventNodeCordoned      EventType = "node.cordoned"
+	EventNodeDraining      EventType = "node.draining"
+	EventNodeDrained       EventType = "node.drained"
+	EventNodeUncordoned    EventType = "node.uncordoned"
+	EventNodeLabeled       EventType = "node.labeled"
+	EventNodeCircuitOpen   EventType = "node.circuit_open"
+	EventNodeCircuitClosed EventType = "node.circuit_closed"
+	EventGroupAdded        EventType = "group.added"
+	EventGroupUpdated      EventType = "group.updated"
+	EventGroupDeleted      EventType = "group.deleted"
+	EventRuleAdded         EventType = "rule.added"
+	EventRuleDeleted       EventType = "rule.deleted"
+	EventRuleUpdated       EventType = "rule.updated"
+	EventRulesReordered    EventType = "rule.reordered"
+	EventRulesReplaced     EventType = "rule.replaced"
+	EventRulesRejected     EventType = "rule.rejected"
+	EventMessageRouted     EventType = "message.routed"
+	EventMessageForwarded  EventType = "message.forwarded"
+	EventMessageFailed     EventType = "message.failed"
+	EventMessageCancelled  EventType = "message.cancelled"
+	EventJobSucceeded      EventType = "job.succeeded"
+	EventJobFailed         EventType = "job.failed"
+	EventTaskRetrying      EventType = "task.retrying"
+	EventTaskSucceeded     EventType = "task.succeeded"
+	EventTaskDead          EventType = "task.dead"
+	EventScheduleSucceeded EventType = "schedule.succeeded"
+	EventScheduleFailed    EventType = "schedule.failed"
+	EventScheduleSkipped   EventType = "schedule.skipped"
+	EventPlanStepCompleted EventType = "plan.step_completed"
+	EventPlanStepFailed    EventType = "plan.step_failed"
+	EventPlanCompleted     EventType = "plan.completed"
+	EventPlanFailed        EventType = "plan.failed"
+	EventWebhookTest       EventType = "webhook.test"
+)
+
+// Event is a mesh lifecycle event published by the coordinator.
+// IDs increase monotonically so consumers can resume a stream.
+type Event struct {
+	ID        uint64         `json:"id"`
+	Type      EventType      `json:"type"`
+	Time      time.Time      `json:"time"`
+	NodeID    string         `json:"node_id,omitempty"`
+	RuleID    string         `json:"rule_id,omitempty"`
+	MessageID string         `json:"message_id,omitempty"`
+	Data      map[string]any `json:"data,omitempty"`
+}
+
+// Webhook is an outbound subscription that receives mesh events as
+// HMAC-signed JSON POSTs. Events holds type patterns ("node.offline",
+// "node.*"); empty means all events.
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events,omitempty"`
+	Secret    string    `json:"secret,omitempty"`
+	Disabled  bool      `json:"disabled,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDelivery records the outcome of delivering one event to a webhook.
+type WebhookDelivery struct {
+	ID         string    `json:"id"`
+	WebhookID  string    `json:"webhook_id"`
+	EventID    uint64    `json:"event_id"`
+	EventType  EventType `json:"event_type"`
+	Attempts   int       `json:"attempts"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
 }
 
 // ValidNodeStatus reports whether s is a known node status value.